@@ -0,0 +1,201 @@
+// Package acl - проверка прав на отправку сообщений клиенту (To) и публикацию в канал (Channel)
+// по правилам dto.ACLRule. Проверка выполняется до маршрутизации и сохранения сообщения:
+// Checker.Middleware ставится после middleware авторизации, отправитель - авторизованное имя соединения.
+// Запрещенные сообщения записываются в журнал аудита
+package acl
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blabu/messagesLib/dto"
+)
+
+// DefaultRefresh - как долго Checker использует прочитанные правила и группы
+const DefaultRefresh = 10 * time.Second
+
+// Any - шаблон, которому подходит любое значение
+const Any = "*"
+
+// GroupPrefix - префикс шаблона группы
+const GroupPrefix = "@"
+
+// Decision - результат проверки
+type Decision struct {
+	Allow bool
+	Rule  *dto.ACLRule // Правило, которое приняло решение (nil - политика по умолчанию)
+}
+
+type groupsEntry struct {
+	groups []string
+	loaded time.Time
+}
+
+// Checker - проверяет сообщения по правилам из Rules
+type Checker struct {
+	Rules        dto.IBgACL
+	Audit        dto.IBgACLAudit  // Журнал запретов (nil - только лог)
+	DefaultAllow bool             // Решение, если ни одно правило не подошло
+	Refresh      time.Duration    // 0 - DefaultRefresh
+	ErrorLog     *log.Logger      // Лог запретов и ошибок хранилища (nil - стандартный логгер)
+	Now          func() time.Time // nil - time.Now
+
+	mu     sync.Mutex
+	rules  []dto.ACLRule
+	loaded time.Time
+	groups map[string]groupsEntry
+	swept  time.Time // Время последнего удаления устаревших групп
+}
+
+func (c *Checker) now() time.Time {
+	if c.Now == nil {
+		return time.Now()
+	}
+	return c.Now()
+}
+
+func (c *Checker) refresh() time.Duration {
+	if c.Refresh <= 0 {
+		return DefaultRefresh
+	}
+	return c.Refresh
+}
+
+func (c *Checker) logf(format string, args ...interface{}) {
+	if c.ErrorLog != nil {
+		c.ErrorLog.Printf(format, args...)
+		return
+	}
+	log.Printf(format, args...)
+}
+
+// Invalidate - сбрасывает прочитанные правила и группы (после изменения хранилища)
+func (c *Checker) Invalidate() {
+	c.mu.Lock()
+	c.rules, c.loaded, c.groups, c.swept = nil, time.Time{}, nil, time.Time{}
+	c.mu.Unlock()
+}
+
+// sortRules - порядок проверки: по убыванию Priority, запрещающие раньше разрешающих
+func sortRules(rules []dto.ACLRule) {
+	sort.SliceStable(rules, func(i, j int) bool {
+		if rules[i].Priority != rules[j].Priority {
+			return rules[i].Priority > rules[j].Priority
+		}
+		return !rules[i].Allow && rules[j].Allow
+	})
+}
+
+func (c *Checker) loadRules(ctx context.Context, now time.Time) ([]dto.ACLRule, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.loaded.IsZero() && now.Sub(c.loaded) < c.refresh() {
+		return c.rules, nil
+	}
+	rules, err := c.Rules.GetRules(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sortRules(rules)
+	c.rules, c.loaded = rules, now
+	return rules, nil
+}
+
+func (c *Checker) loadGroups(ctx context.Context, name string, now time.Time) ([]string, error) {
+	c.mu.Lock()
+	if e, ok := c.groups[name]; ok && now.Sub(e.loaded) < c.refresh() {
+		c.mu.Unlock()
+		return e.groups, nil
+	}
+	c.mu.Unlock()
+	groups, err := c.Rules.GetGroups(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	if c.groups == nil {
+		c.groups = make(map[string]groupsEntry)
+	}
+	c.expireGroups(now)
+	c.groups[name] = groupsEntry{groups: groups, loaded: now}
+	c.mu.Unlock()
+	return groups, nil
+}
+
+// expireGroups - удаляет группы, прочитанные раньше Refresh, не чаще раза в Refresh. Вызывается под c.mu
+func (c *Checker) expireGroups(now time.Time) {
+	if now.Sub(c.swept) < c.refresh() {
+		return
+	}
+	for name, e := range c.groups {
+		if now.Sub(e.loaded) >= c.refresh() {
+			delete(c.groups, name)
+		}
+	}
+	c.swept = now
+}
+
+// match - подходит ли значение value шаблону pattern
+func (c *Checker) match(ctx context.Context, pattern, value string, now time.Time) (bool, error) {
+	switch {
+	case pattern == "" || pattern == Any:
+		return true, nil
+	case value == "":
+		return false, nil
+	case strings.HasPrefix(pattern, GroupPrefix):
+		groups, err := c.loadGroups(ctx, value, now)
+		if err != nil {
+			return false, err
+		}
+		for _, g := range groups {
+			if g == pattern[len(GroupPrefix):] {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return dto.MatchName(pattern, value), nil
+}
+
+// Check - может ли from отправить сообщение клиенту to и (или) в канал channel.
+// Ошибка хранилища возвращается вместе с запретом
+func (c *Checker) Check(ctx context.Context, from, to, channel string) (Decision, error) {
+	now := c.now()
+	rules, err := c.loadRules(ctx, now)
+	if err != nil {
+		return Decision{}, err
+	}
+next:
+	for i := range rules {
+		for _, f := range [...][2]string{{rules[i].From, from}, {rules[i].To, to}, {rules[i].Channel, channel}} {
+			ok, err := c.match(ctx, f[0], f[1], now)
+			if err != nil {
+				return Decision{}, err
+			}
+			if !ok {
+				continue next
+			}
+		}
+		rule := rules[i]
+		return Decision{Allow: rule.Allow, Rule: &rule}, nil
+	}
+	return Decision{Allow: c.DefaultAllow}, nil
+}
+
+// deny - записывает запрет в журнал
+func (c *Checker) deny(ctx context.Context, msg *dto.Message, from string, d Decision) {
+	rec := dto.ACLDenial{Time: c.now(), From: from, To: msg.To, Channel: msg.Channel, Command: msg.Command}
+	if d.Rule != nil {
+		rec.Rule = d.Rule.ID
+	}
+	c.logf("ACL denied %d from %q to %q channel %q (rule %q)", msg.Command, from, msg.To, msg.Channel, rec.Rule)
+	if c.Audit != nil {
+		if err := c.Audit.AddDenial(ctx, &rec); err != nil {
+			c.logf("ACL audit: %v", err)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package acl
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/blabu/messagesLib/dto"
+	"github.com/blabu/messagesLib/memstore"
+	"github.com/blabu/messagesLib/router"
+)
+
+// testClock - управляемое время
+type testClock struct{ t time.Time }
+
+func (c *testClock) now() time.Time          { return c.t }
+func (c *testClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newChecker(t *testing.T, rules ...dto.ACLRule) (*Checker, *memstore.ACL) {
+	t.Helper()
+	store := memstore.NewACL()
+	for i := range rules {
+		if err := store.SaveRule(context.Background(), &rules[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &Checker{Rules: store, Audit: store, ErrorLog: log.New(ioutil.Discard, "", 0)}, store
+}
+
+// TestMiddlewareIdentity - отправитель берется только из авторизованного имени соединения
+func TestMiddlewareIdentity(t *testing.T) {
+	c, store := newChecker(t, dto.ACLRule{Allow: true, From: "modem", To: "server"})
+	mux := router.NewMux()
+	mux.Use(c.Middleware())
+	mux.HandleFunc(dto.DataCOMMAND, func(ctx context.Context, r *router.Router, msg *dto.Message) error {
+		return r.Reply(ctx, msg)
+	})
+	ctx := context.Background()
+	send := func(r *router.Router, from, to string) dto.Message {
+		t.Helper()
+		msg := dto.Message{
+			MessageMetaInf: dto.MessageMetaInf{Command: dto.DataCOMMAND, From: from, To: to},
+			MessageContent: dto.MessageContent{ContentType: "text", Data: []byte("x")},
+		}
+		if err := r.Write(ctx, &msg); err != nil {
+			t.Fatal(err)
+		}
+		var resp dto.Message
+		if err := r.Read(ctx, &resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	code := func(m dto.Message) uint16 {
+		if m.Command != dto.ErrorCOMMAND {
+			return 0
+		}
+		return dto.ParseErrorInfo(m.Data).Code
+	}
+
+	r := mux.NewRouter()
+	if resp := send(r, "modem", "server"); code(resp) != dto.ErrCodeUnauthorized {
+		t.Fatalf("Before login answer %+v", resp.MessageMetaInf)
+	}
+	if resp := send(r, "modem", ""); resp.Command != dto.DataCOMMAND {
+		t.Fatalf("Message without recipient answer %+v", resp.MessageMetaInf)
+	}
+	r.SetName("modem")
+	if resp := send(r, "other", "server"); resp.Command != dto.DataCOMMAND {
+		t.Fatalf("Authenticated answer %+v", resp.MessageMetaInf)
+	}
+	r.SetName("other")
+	if resp := send(r, "modem", "server"); code(resp) != dto.ErrCodeForbidden {
+		t.Fatalf("Spoofed From answer %+v", resp.MessageMetaInf)
+	}
+	if d := store.Denials(0); len(d) != 1 || d[0].From != "other" {
+		t.Fatalf("Audit %+v", d)
+	}
+}
+
+// TestGroupsCacheExpire - группы отправителей, прочитанные раньше Refresh, удаляются из памяти
+func TestGroupsCacheExpire(t *testing.T) {
+	c, store := newChecker(t, dto.ACLRule{Allow: true, From: "@fleet", To: "server"})
+	clock := &testClock{t: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	c.Now, c.Refresh = clock.now, time.Second
+	ctx := context.Background()
+	store.AddToGroup(ctx, "fleet", "modem0")
+	for i := 0; i < 100; i++ {
+		if _, err := c.Check(ctx, fmt.Sprintf("modem%d", i), "server", ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := len(c.groups); n != 100 {
+		t.Fatalf("%d cached senders, want 100", n)
+	}
+	clock.advance(2 * time.Second)
+	store.RemoveFromGroup(ctx, "fleet", "modem0")
+	d, err := c.Check(ctx, "modem0", "server", "")
+	if err != nil || d.Allow {
+		t.Fatalf("Removed from group: %+v %v", d, err)
+	}
+	if n := len(c.groups); n != 1 {
+		t.Fatalf("%d cached senders after Refresh, want 1", n)
+	}
+}
+
+func TestSortRules(t *testing.T) {
+	rules := []dto.ACLRule{
+		{ID: "a", Allow: true},
+		{ID: "b", Allow: false},
+		{ID: "c", Allow: true, Priority: 10},
+		{ID: "d", Allow: false, Priority: -1},
+		{ID: "e", Allow: false, Priority: 10},
+		{ID: "f", Allow: true},
+	}
+	sortRules(rules)
+	var got string
+	for _, r := range rules {
+		got += r.ID
+	}
+	if got != "ecbafd" {
+		t.Fatalf("Order %s, want ecbafd", got)
+	}
+}
+
+// TestCheckPrecedence - решение принимает первое подходящее правило в порядке sortRules
+func TestCheckPrecedence(t *testing.T) {
+	cases := []struct {
+		name         string
+		rules        []dto.ACLRule
+		defaultAllow bool
+		from, to     string
+		channel      string
+		allow        bool
+		rule         string
+	}{
+		{"no_rules_deny", nil, false, "modem", "server", "", false, ""},
+		{"no_rules_allow", nil, true, "modem", "server", "", true, ""},
+		{"no_match_default", []dto.ACLRule{{ID: "r1", Allow: true, From: "bot"}}, false, "modem", "server", "", false, ""},
+		{"exact", []dto.ACLRule{{ID: "r1", Allow: true, From: "modem", To: "server"}}, false, "modem", "server", "", true, "r1"},
+		{"empty_fields_any", []dto.ACLRule{{ID: "r1", Allow: true}}, false, "modem", "server", "news", true, "r1"},
+		{"star_any", []dto.ACLRule{{ID: "r1", Allow: false, From: Any, To: Any, Channel: Any}}, true, "modem", "server", "news", false, "r1"},
+		{"prefix", []dto.ACLRule{{ID: "r1", Allow: true, From: "modem*"}}, false, "modem42", "server", "", true, "r1"},
+		{"prefix_no_match", []dto.ACLRule{{ID: "r1", Allow: true, From: "modem*"}}, false, "bot", "server", "", false, ""},
+		{"pattern_needs_value", []dto.ACLRule{{ID: "r1", Allow: true, Channel: "modem*"}}, false, "modem", "server", "", false, ""},
+		{"channel", []dto.ACLRule{{ID: "r1", Allow: true, Channel: "news"}}, false, "modem", "", "news", true, "r1"},
+		{"group", []dto.ACLRule{{ID: "r1", Allow: true, From: "@fleet", To: "@servers"}}, false, "modem", "server", "", true, "r1"},
+		{"group_no_member", []dto.ACLRule{{ID: "r1", Allow: true, From: "@fleet"}}, false, "bot", "server", "", false, ""},
+		{"deny_before_allow", []dto.ACLRule{
+			{ID: "r1", Allow: true, From: "modem"},
+			{ID: "r2", Allow: false, From: "@fleet"},
+		}, true, "modem", "server", "", false, "r2"},
+		{"priority_over_deny", []dto.ACLRule{
+			{ID: "r1", Allow: false, From: Any},
+			{ID: "r2", Allow: true, From: "modem", Priority: 1},
+		}, false, "modem", "server", "", true, "r2"},
+		{"higher_deny", []dto.ACLRule{
+			{ID: "r1", Allow: true, From: "modem", Priority: 1},
+			{ID: "r2", Allow: false, To: "server", Priority: 2},
+		}, true, "modem", "server", "", false, "r2"},
+		{"skip_not_matching_priority", []dto.ACLRule{
+			{ID: "r1", Allow: false, From: "bot", Priority: 5},
+			{ID: "r2", Allow: true, From: "modem"},
+		}, false, "modem", "server", "", true, "r2"},
+	}
+	ctx := context.Background()
+	for _, tc := range cases {
+		c, store := newChecker(t, tc.rules...)
+		c.DefaultAllow = tc.defaultAllow
+		store.AddToGroup(ctx, "fleet", "modem")
+		store.AddToGroup(ctx, "servers", "server")
+		d, err := c.Check(ctx, tc.from, tc.to, tc.channel)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		rule := ""
+		if d.Rule != nil {
+			rule = d.Rule.ID
+		}
+		if d.Allow != tc.allow || rule != tc.rule {
+			t.Errorf("%s: allow %v by %q, want %v by %q", tc.name, d.Allow, rule, tc.allow, tc.rule)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package acl
+
+import (
+	"context"
+
+	"github.com/blabu/messagesLib/dto"
+	"github.com/blabu/messagesLib/router"
+)
+
+// Middleware - отклоняет сообщения с получателем (To или Channel), которые правила не разрешают отправителю.
+// Отправитель - имя авторизованного соединения (Router.Name), From сообщения не используется.
+// Отказ отправляется клиенту с кодом dto.ErrCodeForbidden, до авторизации - с кодом dto.ErrCodeUnauthorized
+func (c *Checker) Middleware() router.Middleware {
+	return func(next router.HandlerFunc) router.HandlerFunc {
+		return func(ctx context.Context, r *router.Router, msg *dto.Message) error {
+			if msg.To == "" && msg.Channel == "" {
+				return next(ctx, r, msg)
+			}
+			from := r.Name()
+			if from == "" {
+				return &dto.ErrorInfo{Code: dto.ErrCodeUnauthorized, Command: msg.Command, Message: "Authorization required"}
+			}
+			d, err := c.Check(ctx, from, msg.To, msg.Channel)
+			if err != nil {
+				c.logf("ACL check from %q: %v", from, err)
+				return &dto.ErrorInfo{Code: dto.ErrCodeInternal, Command: msg.Command, Message: "Access check failed"}
+			}
+			if !d.Allow {
+				c.deny(ctx, msg, from, d)
+				return &dto.ErrorInfo{Code: dto.ErrCodeForbidden, Command: msg.Command, Message: "Access denied"}
+			}
+			return next(ctx, r, msg)
+		}
+	}
+}
@@ -0,0 +1,395 @@
+// Package auth - регистрация и авторизация клиентов по командам RegisterCOMMAND, GenerateCOMMAND и AuthCOMMAND
+// (описание обмена смотри в dto). Session - состояние одного соединения, до успешной авторизации
+// все остальные команды отклоняются
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blabu/messagesLib/dto"
+	"github.com/blabu/messagesLib/router"
+)
+
+// DefaultMaxAttempts - количество неудачных попыток авторизации, после которых соединение закрывается
+const DefaultMaxAttempts = 3
+
+// DefaultChallengeTimeout - время, за которое клиент должен ответить на вызов
+const DefaultChallengeTimeout = 30 * time.Second
+
+// DefaultMaxClockSkew - допустимое расхождение времени клиента (dto.SignatureV2) с временем сервера
+const DefaultMaxClockSkew = 5 * time.Minute
+
+// ErrTooManyAttempts - соединение нужно закрыть после слишком большого количества неудачных попыток
+var ErrTooManyAttempts = errors.New("Too many failed authorization attempts")
+
+// ErrNoHashedGenerate - с HashTokens хранилище клиентов не реализует dto.IBgClientGenerator
+var ErrNoHashedGenerate = errors.New("Client store cannot generate clients with hashed tokens")
+
+// State - состояние авторизации соединения
+type State int
+
+const (
+	StateNew           State = iota // Вызов не выдан
+	StateChallenged                 // Выдан вызов, ожидается подпись
+	StateAuthenticated              // Клиент авторизован
+)
+
+func (s State) String() string {
+	switch s {
+	case StateNew:
+		return "new"
+	case StateChallenged:
+		return "challenged"
+	case StateAuthenticated:
+		return "authenticated"
+	}
+	return "unknown"
+}
+
+// Service - общие для всех соединений настройки авторизации
+type Service struct {
+	Clients       dto.IBgClientSaver
+	Salts         dto.IBgSalt // Проверка уникальности соли, Check должен вернуть 1 при первом использовании соли, например SaltCache (nil - без проверки)
+	AllowRegister bool        // Разрешить RegisterCOMMAND
+	AllowGenerate bool        // Разрешить GenerateCOMMAND
+	MaxAttempts   int         // 0 - DefaultMaxAttempts
+	MinSignature  int         // Минимальная принимаемая версия подписи (0 - dto.SignatureV1), для перехода парка на dto.SignatureV2
+
+	// HashTokens - хранить токены в виде хеша (dto.HashToken): новые клиенты RegisterCOMMAND и GenerateCOMMAND сохраняются с хешем
+	// (для GenerateCOMMAND Clients должен реализовать dto.IBgClientGenerator),
+	// открытые токены заменяются хешем после первой успешной авторизации по dto.SignatureV3.
+	// Клиенты с хешем токена могут авторизоваться только по dto.SignatureV3
+	HashTokens     bool
+	HashIterations int // 0 - dto.DefaultTokenIterations
+
+	ChallengeTimeout time.Duration    // 0 - DefaultChallengeTimeout
+	MaxClockSkew     time.Duration    // 0 - DefaultMaxClockSkew, отрицательное значение - без проверки времени клиента
+	Now              func() time.Time // nil - time.Now
+
+	// Admit - вызывается в Middleware после успешной проверки подписи (например sessions.Registry.Admit).
+	// Ошибка отменяет авторизацию и отправляется клиенту вместо AuthOK (*dto.ErrorInfo как есть,
+	// остальные с кодом dto.ErrCodeForbidden)
+	Admit func(ctx context.Context, r *router.Router, name string) error
+}
+
+func (s *Service) now() time.Time {
+	if s.Now == nil {
+		return time.Now()
+	}
+	return s.Now()
+}
+
+func (s *Service) challengeTimeout() time.Duration {
+	if s.ChallengeTimeout <= 0 {
+		return DefaultChallengeTimeout
+	}
+	return s.ChallengeTimeout
+}
+
+func (s *Service) maxClockSkew() time.Duration {
+	if s.MaxClockSkew == 0 {
+		return DefaultMaxClockSkew
+	}
+	return s.MaxClockSkew
+}
+
+// versions - версии подписи, которые принимает сервер для клиента с хранимым токеном stored (пусто - клиент не найден),
+// и параметры хеширования токена для dto.SignatureV3
+func (s *Service) versions(stored string) ([]int, *dto.TokenKDF, error) {
+	min := s.MinSignature
+	if min < dto.SignatureV1 {
+		min = dto.SignatureV1
+	}
+	if dto.IsTokenHash(stored) {
+		h, err := dto.ParseTokenHash(stored)
+		if err != nil || min > dto.SignatureV3 {
+			return nil, nil, err
+		}
+		return []int{dto.SignatureV3}, &h.TokenKDF, nil
+	}
+	var res []int
+	for v := min; v <= dto.SignatureV2; v++ {
+		res = append(res, v)
+	}
+	if !s.HashTokens || min > dto.SignatureV3 {
+		return res, nil, nil
+	}
+	kdf, err := dto.NewTokenKDF(s.HashIterations)
+	if err != nil {
+		return nil, nil, err
+	}
+	return append(res, dto.SignatureV3), &kdf, nil
+}
+
+func (s *Service) maxAttempts() int {
+	if s.MaxAttempts <= 0 {
+		return DefaultMaxAttempts
+	}
+	return s.MaxAttempts
+}
+
+// NewSession - состояние авторизации нового соединения
+func (s *Service) NewSession() *Session {
+	return &Session{svc: s}
+}
+
+// Session - конечный автомат авторизации одного соединения
+type Session struct {
+	svc *Service
+
+	mu        sync.Mutex
+	state     State
+	name      string // Имя из первого шага авторизации, после успеха - имя клиента
+	challenge string
+	versions  []int         // Версии подписи, предложенные в вызове
+	kdf       *dto.TokenKDF // Параметры хеширования токена из вызова
+	issued    time.Time     // Время выдачи вызова
+	attempts  int
+	version   int // Версия подписи успешной авторизации
+}
+
+// State - текущее состояние и имя авторизованного клиента
+func (ss *Session) State() (State, string) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	if ss.state != StateAuthenticated {
+		return ss.state, ""
+	}
+	return ss.state, ss.name
+}
+
+// Logout - сбрасывает авторизацию соединения
+func (ss *Session) Logout() {
+	ss.mu.Lock()
+	ss.state, ss.challenge, ss.version = StateNew, "", 0
+	ss.mu.Unlock()
+}
+
+// SignatureVersion - версия подписи, с которой клиент авторизовался (0 до авторизации)
+func (ss *Session) SignatureVersion() int {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	if ss.state != StateAuthenticated {
+		return 0
+	}
+	return ss.version
+}
+
+// IsAuthCommand - команда обрабатывается Session, а не бизнес логикой
+func IsAuthCommand(cmd uint16) bool {
+	return cmd == dto.RegisterCOMMAND || cmd == dto.GenerateCOMMAND || cmd == dto.AuthCOMMAND
+}
+
+// Handle - обрабатывает сообщение клиента. Для команд авторизации возвращает ответ,
+// для остальных команд до авторизации - ErrorCOMMAND с кодом ErrCodeUnauthorized, после авторизации - nil
+// (сообщение нужно передать бизнес логике). ErrTooManyAttempts означает, что соединение нужно закрыть
+// после отправки ответа
+func (ss *Session) Handle(ctx context.Context, msg *dto.Message) (*dto.Message, error) {
+	switch msg.Command {
+	case dto.RegisterCOMMAND:
+		return ss.register(ctx, msg), nil
+	case dto.GenerateCOMMAND:
+		return ss.generate(ctx, msg), nil
+	case dto.AuthCOMMAND:
+		return ss.auth(ctx, msg)
+	}
+	if state, _ := ss.State(); state != StateAuthenticated {
+		return dto.NewErrorMessage(msg, dto.ErrCodeUnauthorized, "Authorization required"), nil
+	}
+	return nil, nil
+}
+
+func reply(req *dto.Message, to string, data string) *dto.Message {
+	return &dto.Message{
+		MessageMetaInf: dto.MessageMetaInf{
+			Command: req.Command,
+			Proto:   req.Proto,
+			ID:      req.ID,
+			To:      to,
+		},
+		MessageContent: dto.MessageContent{ContentType: "text", Data: []byte(data)},
+	}
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// register - регистрирует клиента с именем From и новым токеном. Отозванного клиента можно зарегистрировать заново.
+// Если Clients реализует dto.IBgClientCreator, одновременная регистрация одного имени успешна только один раз
+func (ss *Session) register(ctx context.Context, msg *dto.Message) *dto.Message {
+	if !ss.svc.AllowRegister {
+		return dto.NewErrorMessage(msg, dto.ErrCodeForbidden, "Registration is disabled")
+	}
+	if msg.From == "" || strings.Contains(msg.From, dto.DataDelimiter) {
+		return dto.NewErrorMessage(msg, dto.ErrCodeBadRequest, "Incorrect client name")
+	}
+	creator, atomic := ss.svc.Clients.(dto.IBgClientCreator)
+	if !atomic {
+		cl, err := ss.svc.Clients.GetClient(ctx, msg.From)
+		if err == nil && !cl.Revoked {
+			return dto.NewErrorMessage(msg, dto.ErrCodeForbidden, dto.ErrClientExists.Error())
+		}
+		if err != nil && !errors.Is(err, dto.ErrNotFound) {
+			return dto.NewErrorMessage(msg, dto.ErrCodeInternal, err.Error())
+		}
+	}
+	token, err := randomHex(16)
+	if err != nil {
+		return dto.NewErrorMessage(msg, dto.ErrCodeInternal, err.Error())
+	}
+	stored := token
+	if ss.svc.HashTokens {
+		if stored, err = dto.HashToken(token, ss.svc.HashIterations); err != nil {
+			return dto.NewErrorMessage(msg, dto.ErrCodeInternal, err.Error())
+		}
+	}
+	cl := dto.ClientDescriptor{Name: msg.From, Token: stored}
+	if atomic {
+		err = creator.CreateClient(ctx, &cl)
+	} else {
+		err = ss.svc.Clients.SaveClient(ctx, &cl)
+	}
+	if errors.Is(err, dto.ErrClientExists) {
+		return dto.NewErrorMessage(msg, dto.ErrCodeForbidden, err.Error())
+	} else if err != nil {
+		return dto.NewErrorMessage(msg, dto.ErrCodeInternal, err.Error())
+	}
+	return reply(msg, msg.From, token)
+}
+
+// generate - создает нового клиента, From может содержать желаемое имя.
+// С HashTokens хранилище должно реализовать dto.IBgClientGenerator, чтобы открытый токен не сохранялся
+func (ss *Session) generate(ctx context.Context, msg *dto.Message) *dto.Message {
+	if !ss.svc.AllowGenerate {
+		return dto.NewErrorMessage(msg, dto.ErrCodeForbidden, "Client generation is disabled")
+	}
+	var cl dto.ClientDescriptor
+	var err error
+	if ss.svc.HashTokens {
+		gen, ok := ss.svc.Clients.(dto.IBgClientGenerator)
+		if !ok {
+			return dto.NewErrorMessage(msg, dto.ErrCodeInternal, ErrNoHashedGenerate.Error())
+		}
+		cl, err = gen.GenerateHashedClient(ctx, msg.From, func(token string) (string, error) {
+			return dto.HashToken(token, ss.svc.HashIterations)
+		})
+	} else {
+		cl, err = ss.svc.Clients.GenerateClient(ctx, msg.From)
+	}
+	if err != nil {
+		return dto.NewErrorMessage(msg, dto.ErrCodeInternal, err.Error())
+	}
+	return reply(msg, cl.Name, cl.Name+dto.DataDelimiter+cl.Token)
+}
+
+// auth - первый шаг (пустые данные) выдает вызов, второй проверяет подпись
+func (ss *Session) auth(ctx context.Context, msg *dto.Message) (*dto.Message, error) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	if len(msg.Data) == 0 {
+		if msg.From == "" {
+			return dto.NewErrorMessage(msg, dto.ErrCodeBadRequest, "Client name is empty"), nil
+		}
+		nonce, err := randomHex(16)
+		if err != nil {
+			return dto.NewErrorMessage(msg, dto.ErrCodeInternal, err.Error()), nil
+		}
+		cl, _ := ss.svc.Clients.GetClient(ctx, msg.From) // Для неизвестного клиента вызов такой же, как для клиента с открытым токеном
+		versions, kdf, err := ss.svc.versions(cl.Token)
+		if err != nil {
+			return dto.NewErrorMessage(msg, dto.ErrCodeInternal, err.Error()), nil
+		}
+		challenge := dto.FormChallenge(nonce, versions...)
+		if kdf != nil {
+			challenge = dto.ChallengeWithKDF(challenge, *kdf)
+		}
+		ss.state, ss.name, ss.challenge, ss.issued = StateChallenged, msg.From, challenge, ss.svc.now()
+		ss.versions, ss.kdf = versions, kdf
+		return reply(msg, msg.From, challenge), nil
+	}
+	if ss.state != StateChallenged || msg.From != ss.name {
+		return ss.fail(msg, dto.ErrCodeReplay, "Authorization must start with a new challenge request")
+	}
+	challenge := ss.challenge
+	ss.state, ss.challenge = StateNew, "" // Вызов одноразовый
+	now := ss.svc.now()
+	if now.Sub(ss.issued) > ss.svc.challengeTimeout() {
+		return ss.fail(msg, dto.ErrCodeStale, "Challenge expired")
+	}
+	data, err := dto.DecodeAuthData(msg.Data)
+	if err != nil {
+		return ss.fail(msg, dto.ErrCodeUnauthorized, err.Error())
+	}
+	if !offered(ss.versions, data.Version) {
+		return ss.fail(msg, dto.ErrCodeUnauthorized, "Signature version is not allowed")
+	}
+	cl, err := ss.svc.Clients.GetClient(ctx, ss.name)
+	if err != nil || !verifyAny(&data, ss.name, challenge, cl.ActiveTokens(now)) {
+		return ss.fail(msg, dto.ErrCodeUnauthorized, "Incorrect name or signature")
+	}
+	if skew := ss.svc.maxClockSkew(); skew > 0 && data.Version >= dto.SignatureV2 {
+		if d := now.Sub(time.Unix(data.Timestamp, 0)); d > skew || d < -skew {
+			return ss.fail(msg, dto.ErrCodeStale, "Client time is out of the allowed window")
+		}
+	}
+	if ss.svc.Salts != nil && ss.svc.Salts.Check(ctx, ss.name, dto.Salt(data.Salt)) != 1 {
+		return ss.fail(msg, dto.ErrCodeReplay, "Salt was already used")
+	}
+	if data.Version == dto.SignatureV3 && !dto.IsTokenHash(cl.Token) {
+		// Клиент поддерживает dto.SignatureV3, дальше ему не нужен открытый токен на сервере.
+		// Ошибка сохранения не мешает авторизации, замена повторится при следующей авторизации
+		ss.svc.Clients.SaveClient(ctx, hashTokens(cl, *ss.kdf))
+	}
+	ss.state, ss.attempts, ss.version = StateAuthenticated, 0, data.Version
+	return reply(msg, ss.name, dto.AuthOK), nil
+}
+
+func offered(versions []int, version int) bool {
+	for _, v := range versions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+// hashTokens - клиент с открытыми токенами, замененными хешами с параметрами kdf
+func hashTokens(cl dto.ClientDescriptor, kdf dto.TokenKDF) *dto.ClientDescriptor {
+	cl.Token = kdf.Hash(cl.Token)
+	if cl.OldToken != "" && !dto.IsTokenHash(cl.OldToken) {
+		cl.OldToken = kdf.Hash(cl.OldToken)
+	}
+	return &cl
+}
+
+// verifyAny - подпись сделана одним из токенов (текущим или старым в период перекрытия после ротации)
+func verifyAny(data *dto.AuthData, name, challenge string, tokens []string) bool {
+	for _, token := range tokens {
+		if data.Verify(name, challenge, token) {
+			return true
+		}
+	}
+	return false
+}
+
+// fail - неудачная попытка авторизации, вызывается под ss.mu
+func (ss *Session) fail(msg *dto.Message, code uint16, text string) (*dto.Message, error) {
+	ss.state, ss.challenge = StateNew, ""
+	ss.attempts++
+	res := dto.NewErrorMessage(msg, code, text)
+	if ss.attempts >= ss.svc.maxAttempts() {
+		return res, ErrTooManyAttempts
+	}
+	return res, nil
+}
@@ -0,0 +1,322 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blabu/messagesLib/dto"
+	"github.com/blabu/messagesLib/memstore"
+)
+
+var testNow = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func authMsg(from, data string) *dto.Message {
+	return &dto.Message{
+		MessageMetaInf: dto.MessageMetaInf{Command: dto.AuthCOMMAND, From: from},
+		MessageContent: dto.MessageContent{ContentType: "text", Data: []byte(data)},
+	}
+}
+
+// login - двухшаговая авторизация в ss самой новой версией подписи из вызова
+func login(t *testing.T, ss *Session, name, token string) (*dto.Message, error) {
+	t.Helper()
+	ctx := context.Background()
+	resp, err := ss.Handle(ctx, authMsg(name, ""))
+	if err != nil || resp.Command != dto.AuthCOMMAND {
+		t.Fatalf("Challenge %+v %v", resp, err)
+	}
+	challenge := string(resp.Data)
+	version := 0
+	for _, v := range dto.ParseChallenge(challenge) {
+		if v > version {
+			version = v
+		}
+	}
+	data, err := dto.SignAuthData(version, name, challenge, "salt"+challenge[:8], token, ss.svc.now().Unix())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ss.Handle(ctx, authMsg(name, string(data.Encode())))
+}
+
+// TestGenerateHashesToken - с HashTokens сгенерированный токен сохраняется хешем сразу, а не после первой авторизации
+func TestGenerateHashesToken(t *testing.T) {
+	for _, storeHash := range []bool{false, true} {
+		store := memstore.New()
+		store.HashTokens, store.HashIterations = storeHash, 1000
+		svc := &Service{Clients: store, AllowGenerate: true, HashTokens: true, HashIterations: 1000, Now: func() time.Time { return testNow }}
+		ctx := context.Background()
+		resp, err := svc.NewSession().Handle(ctx, &dto.Message{MessageMetaInf: dto.MessageMetaInf{Command: dto.GenerateCOMMAND, From: "modem"}})
+		if err != nil || resp.Command != dto.GenerateCOMMAND {
+			t.Fatalf("Store hash %v: generate answer %+v %v", storeHash, resp, err)
+		}
+		parts := strings.Split(string(resp.Data), dto.DataDelimiter)
+		if len(parts) != 2 || parts[0] != "modem" {
+			t.Fatalf("Store hash %v: generate answer %q", storeHash, resp.Data)
+		}
+		cl, _ := store.GetClient(ctx, "modem")
+		if !dto.IsTokenHash(cl.Token) || !dto.VerifyToken(cl.Token, parts[1]) {
+			t.Fatalf("Store hash %v: stored token %q", storeHash, cl.Token)
+		}
+		if resp, err = login(t, svc.NewSession(), "modem", parts[1]); err != nil || string(resp.Data) != dto.AuthOK {
+			t.Fatalf("Store hash %v: login %q %v", storeHash, resp.Data, err)
+		}
+	}
+
+	// Хранилище, которое не умеет сохранять хеш сразу, не получает открытый токен
+	store := memstore.New()
+	svc := &Service{Clients: plainStore{IBgClientSaver: store}, AllowGenerate: true, HashTokens: true, HashIterations: 1000}
+	resp, err := svc.NewSession().Handle(context.Background(), command(dto.GenerateCOMMAND, "modem", "")(""))
+	if err != nil || resp.Command != dto.ErrorCOMMAND || dto.ParseErrorInfo(resp.Data).Code != dto.ErrCodeInternal {
+		t.Fatalf("Generate without dto.IBgClientGenerator: %+v %q %v", resp.MessageMetaInf, resp.Data, err)
+	}
+	if len(store.Clients()) != 0 {
+		t.Fatalf("Clients saved %+v", store.Clients())
+	}
+}
+
+// step - одно сообщение клиента в TestSessionHandle и ожидаемый результат
+type step struct {
+	advance time.Duration                       // Сдвиг времени перед сообщением
+	msg     func(challenge string) *dto.Message // challenge - последний выданный вызов
+	code    uint16                              // Код ErrorCOMMAND в ответе (0 - ответ без ошибки или nil)
+	pass    bool                                // Handle возвращает nil (сообщение для бизнес логики)
+	state   State
+	err     error
+}
+
+func challengeReq(from string) func(string) *dto.Message {
+	return func(string) *dto.Message { return authMsg(from, "") }
+}
+
+// signed - второй шаг авторизации версией version, время клиента сдвинуто на skew
+func signed(version int, name, token, salt string, skew time.Duration) func(string) *dto.Message {
+	return func(challenge string) *dto.Message {
+		data, _ := dto.SignAuthData(version, name, challenge, salt, token, testNow.Add(skew).Unix())
+		return authMsg(name, string(data.Encode()))
+	}
+}
+
+func command(cmd uint16, from, data string) func(string) *dto.Message {
+	return func(string) *dto.Message {
+		return &dto.Message{
+			MessageMetaInf: dto.MessageMetaInf{Command: cmd, From: from},
+			MessageContent: dto.MessageContent{ContentType: "text", Data: []byte(data)},
+		}
+	}
+}
+
+// TestSessionHandle - переходы конечного автомата авторизации
+func TestSessionHandle(t *testing.T) {
+	data := command(dto.DataCOMMAND, "modem", "hello")
+	cases := []struct {
+		name  string
+		setup func(s *Service)
+		steps []step
+	}{
+		{"data_before_auth", nil, []step{
+			{msg: data, code: dto.ErrCodeUnauthorized, state: StateNew},
+		}},
+		{"v1_login", nil, []step{
+			{msg: challengeReq("modem"), state: StateChallenged},
+			{msg: signed(dto.SignatureV1, "modem", "secret", "s1", 0), state: StateAuthenticated},
+			{msg: data, pass: true, state: StateAuthenticated},
+		}},
+		{"v2_login", nil, []step{
+			{msg: challengeReq("modem"), state: StateChallenged},
+			{msg: signed(dto.SignatureV2, "modem", "secret", "s1", 0), state: StateAuthenticated},
+		}},
+		{"v3_hashed_token", nil, []step{
+			{msg: challengeReq("hashed"), state: StateChallenged},
+			{msg: signed(dto.SignatureV3, "hashed", "secret", "s1", 0), state: StateAuthenticated},
+		}},
+		{"v2_hashed_token", nil, []step{
+			{msg: challengeReq("hashed"), state: StateChallenged},
+			{msg: signed(dto.SignatureV2, "hashed", "secret", "s1", 0), code: dto.ErrCodeUnauthorized, state: StateNew},
+		}},
+		{"v3_not_offered", nil, []step{
+			{msg: challengeReq("modem"), state: StateChallenged},
+			{msg: signed(dto.SignatureV3, "modem", "secret", "s1", 0), code: dto.ErrCodeUnauthorized, state: StateNew},
+		}},
+		{"min_signature", func(s *Service) { s.MinSignature = dto.SignatureV2 }, []step{
+			{msg: challengeReq("modem"), state: StateChallenged},
+			{msg: signed(dto.SignatureV1, "modem", "secret", "s1", 0), code: dto.ErrCodeUnauthorized, state: StateNew},
+			{msg: challengeReq("modem"), state: StateChallenged},
+			{msg: signed(dto.SignatureV2, "modem", "secret", "s2", 0), state: StateAuthenticated},
+		}},
+		{"wrong_token", nil, []step{
+			{msg: challengeReq("modem"), state: StateChallenged},
+			{msg: signed(dto.SignatureV2, "modem", "wrong", "s1", 0), code: dto.ErrCodeUnauthorized, state: StateNew},
+		}},
+		{"unknown_client", nil, []step{
+			{msg: challengeReq("ghost"), state: StateChallenged},
+			{msg: signed(dto.SignatureV2, "ghost", "secret", "s1", 0), code: dto.ErrCodeUnauthorized, state: StateNew},
+		}},
+		{"signature_without_challenge", nil, []step{
+			{msg: signed(dto.SignatureV2, "modem", "secret", "s1", 0), code: dto.ErrCodeReplay, state: StateNew},
+		}},
+		{"challenge_for_other_name", nil, []step{
+			{msg: challengeReq("other"), state: StateChallenged},
+			{msg: signed(dto.SignatureV2, "modem", "secret", "s1", 0), code: dto.ErrCodeReplay, state: StateNew},
+		}},
+		{"challenge_is_single_use", nil, []step{
+			{msg: challengeReq("modem"), state: StateChallenged},
+			{msg: signed(dto.SignatureV2, "modem", "wrong", "s1", 0), code: dto.ErrCodeUnauthorized, state: StateNew},
+			{msg: signed(dto.SignatureV2, "modem", "secret", "s2", 0), code: dto.ErrCodeReplay, state: StateNew},
+		}},
+		{"challenge_expired", nil, []step{
+			{msg: challengeReq("modem"), state: StateChallenged},
+			{advance: DefaultChallengeTimeout + time.Second, msg: signed(dto.SignatureV2, "modem", "secret", "s1", DefaultChallengeTimeout+time.Second), code: dto.ErrCodeStale, state: StateNew},
+		}},
+		{"client_clock_skew", nil, []step{
+			{msg: challengeReq("modem"), state: StateChallenged},
+			{msg: signed(dto.SignatureV2, "modem", "secret", "s1", -DefaultMaxClockSkew-time.Minute), code: dto.ErrCodeStale, state: StateNew},
+			{msg: challengeReq("modem"), state: StateChallenged},
+			{msg: signed(dto.SignatureV1, "modem", "secret", "s2", 0), state: StateAuthenticated}, // В v1 нет времени
+		}},
+		{"salt_reuse", nil, []step{
+			{msg: challengeReq("modem"), state: StateChallenged},
+			{msg: signed(dto.SignatureV2, "modem", "secret", "s1", 0), state: StateAuthenticated},
+			{msg: challengeReq("modem"), state: StateChallenged},
+			{msg: signed(dto.SignatureV2, "modem", "secret", "s1", 0), code: dto.ErrCodeReplay, state: StateNew},
+		}},
+		{"too_many_attempts", nil, []step{
+			{msg: signed(dto.SignatureV2, "modem", "secret", "s1", 0), code: dto.ErrCodeReplay, state: StateNew},
+			{msg: challengeReq("modem"), state: StateChallenged},
+			{msg: signed(dto.SignatureV2, "modem", "wrong", "s2", 0), code: dto.ErrCodeUnauthorized, state: StateNew},
+			{msg: challengeReq("modem"), state: StateChallenged},
+			{msg: signed(dto.SignatureV2, "modem", "wrong", "s3", 0), code: dto.ErrCodeUnauthorized, state: StateNew, err: ErrTooManyAttempts},
+		}},
+		{"register_disabled", nil, []step{
+			{msg: command(dto.RegisterCOMMAND, "new", ""), code: dto.ErrCodeForbidden, state: StateNew},
+		}},
+		{"register", func(s *Service) { s.AllowRegister = true }, []step{
+			{msg: command(dto.RegisterCOMMAND, "modem", ""), code: dto.ErrCodeForbidden, state: StateNew},
+			{msg: command(dto.RegisterCOMMAND, "bad;name", ""), code: dto.ErrCodeBadRequest, state: StateNew},
+			{msg: command(dto.RegisterCOMMAND, "new", ""), state: StateNew},
+		}},
+		{"generate_disabled", nil, []step{
+			{msg: command(dto.GenerateCOMMAND, "", ""), code: dto.ErrCodeForbidden, state: StateNew},
+		}},
+		{"empty_name", nil, []step{
+			{msg: challengeReq(""), code: dto.ErrCodeBadRequest, state: StateNew},
+		}},
+	}
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			store := memstore.New()
+			hashed, _ := dto.HashToken("secret", 100)
+			store.SaveClient(context.Background(), &dto.ClientDescriptor{Name: "modem", Token: "secret"})
+			store.SaveClient(context.Background(), &dto.ClientDescriptor{Name: "hashed", Token: hashed})
+			clock := testNow
+			now := func() time.Time { return clock }
+			svc := &Service{Clients: store, Salts: &SaltCache{Now: now}, Now: now}
+			if tc.setup != nil {
+				tc.setup(svc)
+			}
+			ss := svc.NewSession()
+			challenge := ""
+			for i, st := range tc.steps {
+				clock = clock.Add(st.advance)
+				resp, err := ss.Handle(context.Background(), st.msg(challenge))
+				if err != st.err {
+					t.Fatalf("Step %d: error %v, want %v", i, err, st.err)
+				}
+				if (resp == nil) != st.pass {
+					t.Fatalf("Step %d: answer %+v, pass %v", i, resp, st.pass)
+				}
+				code := uint16(0)
+				if resp != nil && resp.Command == dto.ErrorCOMMAND {
+					code = dto.ParseErrorInfo(resp.Data).Code
+				}
+				if code != st.code {
+					t.Fatalf("Step %d: code %d (%q), want %d", i, code, resp.Data, st.code)
+				}
+				if state, _ := ss.State(); state != st.state {
+					t.Fatalf("Step %d: state %v, want %v", i, state, st.state)
+				}
+				if resp != nil && resp.Command == dto.AuthCOMMAND && string(resp.Data) != dto.AuthOK {
+					challenge = string(resp.Data)
+				}
+			}
+		})
+	}
+}
+
+// TestSessionUpgradeToHash - после первой авторизации по v3 открытый токен в хранилище заменяется хешем
+func TestSessionUpgradeToHash(t *testing.T) {
+	store := memstore.New()
+	store.SaveClient(context.Background(), &dto.ClientDescriptor{Name: "modem", Token: "secret", OldToken: "old", OldExpire: testNow.Add(time.Hour)})
+	svc := &Service{Clients: store, HashTokens: true, HashIterations: 100, Now: func() time.Time { return testNow }}
+	if resp, err := login(t, svc.NewSession(), "modem", "secret"); err != nil || string(resp.Data) != dto.AuthOK {
+		t.Fatalf("Login %q %v", resp.Data, err)
+	}
+	cl, _ := store.GetClient(context.Background(), "modem")
+	if !dto.VerifyToken(cl.Token, "secret") || !dto.IsTokenHash(cl.Token) || !dto.VerifyToken(cl.OldToken, "old") || !dto.IsTokenHash(cl.OldToken) {
+		t.Fatalf("Stored tokens %q %q", cl.Token, cl.OldToken)
+	}
+	for _, token := range []string{"secret", "old"} {
+		if resp, err := login(t, svc.NewSession(), "modem", token); err != nil || string(resp.Data) != dto.AuthOK {
+			t.Fatalf("Login with %s after upgrade %q %v", token, resp.Data, err)
+		}
+	}
+}
+
+// plainStore - хранилище без атомарного создания клиента, getErr - ошибка GetClient (nil - читать из store)
+type plainStore struct {
+	dto.IBgClientSaver
+	getErr error
+}
+
+func (p plainStore) GetClient(ctx context.Context, name string) (dto.ClientDescriptor, error) {
+	if p.getErr != nil {
+		return dto.ClientDescriptor{}, p.getErr
+	}
+	return p.IBgClientSaver.GetClient(ctx, name)
+}
+
+// TestRegisterExisting - регистрация не заменяет токен существующего клиента, в том числе при ошибке хранилища
+func TestRegisterExisting(t *testing.T) {
+	cases := []struct {
+		name   string
+		store  func(s *memstore.Store) dto.IBgClientSaver
+		client string
+		code   uint16
+	}{
+		{"atomic_exists", func(s *memstore.Store) dto.IBgClientSaver { return s }, "modem", dto.ErrCodeForbidden},
+		{"atomic_revoked", func(s *memstore.Store) dto.IBgClientSaver { return s }, "revoked", 0},
+		{"atomic_new", func(s *memstore.Store) dto.IBgClientSaver { return s }, "new", 0},
+		{"plain_exists", func(s *memstore.Store) dto.IBgClientSaver { return plainStore{IBgClientSaver: s} }, "modem", dto.ErrCodeForbidden},
+		{"plain_revoked", func(s *memstore.Store) dto.IBgClientSaver { return plainStore{IBgClientSaver: s} }, "revoked", 0},
+		{"plain_new", func(s *memstore.Store) dto.IBgClientSaver { return plainStore{IBgClientSaver: s} }, "new", 0},
+		{"plain_storage_error", func(s *memstore.Store) dto.IBgClientSaver {
+			return plainStore{IBgClientSaver: s, getErr: errors.New("Storage is down")}
+		}, "modem", dto.ErrCodeInternal},
+	}
+	ctx := context.Background()
+	for _, tc := range cases {
+		store := memstore.New()
+		store.SaveClient(ctx, &dto.ClientDescriptor{Name: "modem", Token: "secret"})
+		store.SaveClient(ctx, &dto.ClientDescriptor{Name: "revoked", Revoked: true})
+		svc := &Service{Clients: tc.store(store), AllowRegister: true}
+		resp, err := svc.NewSession().Handle(ctx, command(dto.RegisterCOMMAND, tc.client, "")(""))
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		code := uint16(0)
+		if resp.Command == dto.ErrorCOMMAND {
+			code = dto.ParseErrorInfo(resp.Data).Code
+		}
+		cl, _ := store.GetClient(ctx, tc.client)
+		if code != tc.code || (code == 0) != (cl.Token == string(resp.Data) && !cl.Revoked) {
+			t.Errorf("%s: code %d, stored %+v, answer %q", tc.name, code, cl, resp.Data)
+		}
+		if cl, _ = store.GetClient(ctx, "modem"); cl.Token != "secret" && tc.client == "modem" {
+			t.Errorf("%s: token of the existing client is replaced", tc.name)
+		}
+	}
+}
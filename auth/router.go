@@ -0,0 +1,57 @@
+package auth
+
+import (
+	"context"
+	"errors"
+
+	"github.com/blabu/messagesLib/dto"
+	"github.com/blabu/messagesLib/router"
+)
+
+type sessionKey struct{}
+
+// SessionOf - состояние авторизации соединения r (nil, если Middleware еще не обработал ни одного сообщения)
+func SessionOf(r *router.Router) *Session {
+	ss, _ := r.Value(sessionKey{}).(*Session)
+	return ss
+}
+
+// Middleware - обрабатывает команды авторизации в Session соединения и не пропускает остальные команды
+// до успешной авторизации. Имя авторизованного клиента сохраняется в Router.SetName (после проверки Admit).
+// После MaxAttempts неудачных попыток Router закрывается (после отправки ответа)
+func (s *Service) Middleware() router.Middleware {
+	return func(next router.HandlerFunc) router.HandlerFunc {
+		return func(ctx context.Context, r *router.Router, msg *dto.Message) error {
+			ss := SessionOf(r)
+			if ss == nil {
+				ss = s.NewSession()
+				r.SetValue(sessionKey{}, ss)
+			}
+			before, _ := ss.State()
+			res, err := ss.Handle(ctx, msg)
+			if res == nil {
+				return next(ctx, r, msg)
+			}
+			state, name := ss.State()
+			if s.Admit != nil && before != StateAuthenticated && state == StateAuthenticated {
+				if aerr := s.Admit(ctx, r, name); aerr != nil {
+					ss.Logout()
+					name = ""
+					var info *dto.ErrorInfo
+					if !errors.As(aerr, &info) {
+						info = &dto.ErrorInfo{Code: dto.ErrCodeForbidden, Message: aerr.Error()}
+					}
+					res = dto.NewErrorMessage(msg, info.Code, info.Message)
+				}
+			}
+			r.SetName(name)
+			if rerr := r.Reply(ctx, res); rerr != nil {
+				return rerr
+			}
+			if err != nil {
+				r.Close()
+			}
+			return nil
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/blabu/messagesLib/dto"
+)
+
+// DefaultSaltsPerClient - сколько последних солей одного клиента помнит SaltCache
+const DefaultSaltsPerClient = 64
+
+type seenSalt struct {
+	salt  dto.Salt
+	count uint64
+	at    time.Time
+}
+
+// SaltCache - ограниченный кэш недавно использованных солей в памяти, реализует dto.IBgSalt.
+// Для каждого клиента хранится не больше PerClient солей не старше TTL. TTL должен быть не меньше
+// окна времени клиента (Service.MaxClockSkew), тогда забытую соль уже нельзя повторить с допустимым временем
+type SaltCache struct {
+	PerClient int              // 0 - DefaultSaltsPerClient
+	TTL       time.Duration    // 0 - 2*DefaultMaxClockSkew
+	Now       func() time.Time // nil - time.Now
+
+	mu     sync.Mutex
+	salts  map[string][]seenSalt
+	checks int
+}
+
+// NewSaltCache - кэш солей с настройками по умолчанию
+func NewSaltCache() *SaltCache {
+	return &SaltCache{}
+}
+
+func (c *SaltCache) now() time.Time {
+	if c.Now == nil {
+		return time.Now()
+	}
+	return c.Now()
+}
+
+func (c *SaltCache) ttl() time.Duration {
+	if c.TTL <= 0 {
+		return 2 * DefaultMaxClockSkew
+	}
+	return c.TTL
+}
+
+func (c *SaltCache) perClient() int {
+	if c.PerClient <= 0 {
+		return DefaultSaltsPerClient
+	}
+	return c.PerClient
+}
+
+// expire - удаляет соли старше TTL, вызывается под c.mu
+func expire(list []seenSalt, deadline time.Time) []seenSalt {
+	i := 0
+	for i < len(list) && list[i].at.Before(deadline) {
+		i++
+	}
+	return list[i:]
+}
+
+// Check - сколько раз соль s использовалась клиентом name, включая этот (1 - соль новая)
+func (c *SaltCache) Check(ctx context.Context, name string, s dto.Salt) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.salts == nil {
+		c.salts = make(map[string][]seenSalt)
+	}
+	now := c.now()
+	deadline := now.Add(-c.ttl())
+	c.checks++
+	if c.checks%1024 == 0 { // Периодически чистим клиентов, которые давно не авторизовались
+		for n, list := range c.salts {
+			if list = expire(list, deadline); len(list) == 0 {
+				delete(c.salts, n)
+			} else {
+				c.salts[n] = list
+			}
+		}
+	}
+	list := expire(c.salts[name], deadline)
+	for i := range list {
+		if list[i].salt == s {
+			list[i].count++
+			c.salts[name] = list
+			return list[i].count
+		}
+	}
+	if len(list) >= c.perClient() {
+		list = list[len(list)-c.perClient()+1:]
+	}
+	c.salts[name] = append(list, seenSalt{salt: s, count: 1, at: now})
+	return 1
+}
+
+// Len - количество солей в кэше
+func (c *SaltCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for _, list := range c.salts {
+		n += len(list)
+	}
+	return n
+}
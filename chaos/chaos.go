@@ -0,0 +1,295 @@
+// Package chaos - обертка io.ReadWriter и net.Conn, которая искажает передаваемые данные
+// (инверсия битов, потеря и обрезка данных, разбиение и склейка записей, перестановка, задержки).
+// Случайные решения принимаются генераторами с заданным Seed (отдельный для чтения и для записи),
+// поэтому при одинаковой последовательности вызовов искажения повторяются
+package chaos
+
+import (
+	"io"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+// DefaultHoldTimeout - сколько запись может ждать следующую для склейки или перестановки
+const DefaultHoldTimeout = 50 * time.Millisecond
+
+// Faults - вероятности искажений (от 0 до 1) в одном направлении
+type Faults struct {
+	FlipRate     float64 // Инверсия случайного бита, для каждого байта
+	DropRate     float64 // Потеря байта, для каждого байта
+	TruncateRate float64 // Обрезка конца блока, для каждого блока (Read или Write)
+	SplitRate    float64 // Разбиение блока на части (при чтении - возврат части данных), для каждого блока
+	CoalesceRate float64 // Склейка записи со следующей, только для записи
+	ReorderRate  float64 // Перестановка записи со следующей, только для записи
+
+	Latency time.Duration // Задержка перед передачей каждого блока (или части блока)
+	Jitter  time.Duration // Случайная добавка к задержке от 0 до Jitter
+}
+
+// Config - настройки искажений
+type Config struct {
+	Seed        int64
+	Read        Faults        // Искажения данных, которые читает владелец обертки
+	Write       Faults        // Искажения данных, которые он записывает
+	HoldTimeout time.Duration // 0 - DefaultHoldTimeout
+}
+
+// Stats - количество внесенных искажений
+type Stats struct {
+	Flipped   int // Инвертированные байты
+	Dropped   int // Потерянные байты (включая обрезку)
+	Truncated int // Обрезанные блоки
+	Split     int // Разбитые блоки
+	Coalesced int // Склеенные записи
+	Reordered int // Переставленные записи
+	Delayed   time.Duration
+}
+
+// ReadWriter - искажающая обертка io.ReadWriter
+type ReadWriter struct {
+	rw   io.ReadWriter
+	conf Config
+
+	mu    sync.Mutex // Генераторы и статистика
+	rRnd  *rand.Rand
+	wRnd  *rand.Rand
+	stats Stats
+
+	rMutex sync.Mutex
+	rest   []byte // Прочитанные, но еще не отданные данные (после разбиения)
+
+	wMutex  sync.Mutex
+	held    []byte // Запись, ожидающая следующую
+	reorder bool   // held должна быть отправлена после следующей записи
+	timer   *time.Timer
+	wErr    error // Ошибка отложенной записи, возвращается следующим Write
+}
+
+// New - оборачивает rw
+func New(rw io.ReadWriter, conf Config) *ReadWriter {
+	return &ReadWriter{
+		rw:   rw,
+		conf: conf,
+		rRnd: rand.New(rand.NewSource(conf.Seed)),
+		wRnd: rand.New(rand.NewSource(conf.Seed + 1)),
+	}
+}
+
+// Stats - количество искажений на данный момент
+func (c *ReadWriter) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+func chance(rnd *rand.Rand, rate float64) bool {
+	return rate > 0 && rnd.Float64() < rate
+}
+
+// mutate - вносит побайтовые искажения и обрезку, возвращает новый блок
+func (c *ReadWriter) mutate(data []byte, f *Faults, rnd *rand.Rand) []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	res := make([]byte, 0, len(data))
+	for _, b := range data {
+		if chance(rnd, f.DropRate) {
+			c.stats.Dropped++
+			continue
+		}
+		if chance(rnd, f.FlipRate) {
+			b ^= 1 << uint(rnd.Intn(8))
+			c.stats.Flipped++
+		}
+		res = append(res, b)
+	}
+	if len(res) > 1 && chance(rnd, f.TruncateRate) {
+		n := 1 + rnd.Intn(len(res)-1)
+		c.stats.Truncated++
+		c.stats.Dropped += len(res) - n
+		res = res[:n]
+	}
+	return res
+}
+
+// split - точка разбиения блока размером n (0 - не разбивать)
+func (c *ReadWriter) split(n int, f *Faults, rnd *rand.Rand) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if n < 2 || !chance(rnd, f.SplitRate) {
+		return 0
+	}
+	c.stats.Split++
+	return 1 + rnd.Intn(n-1)
+}
+
+func (c *ReadWriter) delay(f *Faults, rnd *rand.Rand) {
+	d := f.Latency
+	c.mu.Lock()
+	if f.Jitter > 0 {
+		d += time.Duration(rnd.Int63n(int64(f.Jitter) + 1))
+	}
+	c.stats.Delayed += d
+	c.mu.Unlock()
+	if d > 0 {
+		time.Sleep(d)
+	}
+}
+
+// Read - читает данные с искажениями Config.Read
+func (c *ReadWriter) Read(p []byte) (int, error) {
+	c.rMutex.Lock()
+	defer c.rMutex.Unlock()
+	f := &c.conf.Read
+	for len(c.rest) == 0 {
+		buf := make([]byte, len(p))
+		n, err := c.rw.Read(buf)
+		if n > 0 {
+			c.rest = c.mutate(buf[:n], f, c.rRnd)
+		}
+		if err != nil && len(c.rest) == 0 {
+			return 0, err
+		}
+		if err != nil {
+			break
+		}
+	}
+	c.delay(f, c.rRnd)
+	data := c.rest
+	if at := c.split(len(data), f, c.rRnd); at > 0 {
+		data = data[:at]
+	}
+	n := copy(p, data)
+	c.rest = c.rest[n:]
+	return n, nil
+}
+
+func (c *ReadWriter) holdTimeout() time.Duration {
+	if c.conf.HoldTimeout <= 0 {
+		return DefaultHoldTimeout
+	}
+	return c.conf.HoldTimeout
+}
+
+// Write - записывает данные с искажениями Config.Write. Искажения незаметны записывающему:
+// при успехе всегда возвращается len(p), ошибка отложенной записи возвращается следующим вызовом
+func (c *ReadWriter) Write(p []byte) (int, error) {
+	c.wMutex.Lock()
+	defer c.wMutex.Unlock()
+	if c.wErr != nil {
+		err := c.wErr
+		c.wErr = nil
+		return 0, err
+	}
+	f := &c.conf.Write
+	data := c.mutate(p, f, c.wRnd)
+	if c.held != nil {
+		c.stopTimer()
+		held := c.held
+		c.held = nil
+		if c.reorder {
+			if err := c.send(data, f, c.wRnd); err != nil {
+				return 0, err
+			}
+			data = held
+		} else {
+			data = append(held, data...)
+		}
+	} else if hold, reorder := c.decideHold(f, c.wRnd); hold {
+		c.held, c.reorder = data, reorder
+		c.timer = time.AfterFunc(c.holdTimeout(), c.flushHeld)
+		return len(p), nil
+	}
+	if err := c.send(data, f, c.wRnd); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (c *ReadWriter) decideHold(f *Faults, rnd *rand.Rand) (hold bool, reorder bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if chance(rnd, f.ReorderRate) {
+		c.stats.Reordered++
+		return true, true
+	}
+	if chance(rnd, f.CoalesceRate) {
+		c.stats.Coalesced++
+		return true, false
+	}
+	return false, false
+}
+
+func (c *ReadWriter) stopTimer() {
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+}
+
+// flushHeld - отправляет отложенную запись, если следующая так и не пришла
+func (c *ReadWriter) flushHeld() {
+	c.wMutex.Lock()
+	defer c.wMutex.Unlock()
+	c.timer = nil
+	if c.held == nil {
+		return
+	}
+	held := c.held
+	c.held = nil
+	if err := c.send(held, &c.conf.Write, c.wRnd); err != nil {
+		c.wErr = err
+	}
+}
+
+// Flush - сразу отправляет отложенную запись
+func (c *ReadWriter) Flush() error {
+	c.wMutex.Lock()
+	defer c.wMutex.Unlock()
+	c.stopTimer()
+	if c.held == nil {
+		return nil
+	}
+	held := c.held
+	c.held = nil
+	return c.send(held, &c.conf.Write, c.wRnd)
+}
+
+// send - записывает блок, возможно частями, с задержкой перед каждой частью
+func (c *ReadWriter) send(data []byte, f *Faults, rnd *rand.Rand) error {
+	if at := c.split(len(data), f, rnd); at > 0 {
+		if err := c.send(data[:at], &Faults{Latency: f.Latency, Jitter: f.Jitter}, rnd); err != nil {
+			return err
+		}
+		data = data[at:]
+	}
+	c.delay(f, rnd)
+	_, err := c.rw.Write(data)
+	return err
+}
+
+// Conn - искажающая обертка net.Conn
+type Conn struct {
+	net.Conn
+	*ReadWriter
+}
+
+// WrapConn - оборачивает соединение c
+func WrapConn(c net.Conn, conf Config) *Conn {
+	return &Conn{Conn: c, ReadWriter: New(c, conf)}
+}
+
+func (c *Conn) Read(p []byte) (int, error) {
+	return c.ReadWriter.Read(p)
+}
+
+func (c *Conn) Write(p []byte) (int, error) {
+	return c.ReadWriter.Write(p)
+}
+
+// Close - отправляет отложенную запись и закрывает соединение
+func (c *Conn) Close() error {
+	c.ReadWriter.Flush()
+	return c.Conn.Close()
+}
@@ -0,0 +1,283 @@
+package client
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blabu/messagesLib/dto"
+	"github.com/blabu/messagesLib/parser"
+)
+
+// DefaultMaxPackageSize - максимальный размер принимаемого пакета по умолчанию
+const DefaultMaxPackageSize = 1 << 20
+
+// Conn - соединение клиента с сервером по протоколу клиент-клиент
+type Conn struct {
+	conn   net.Conn
+	parser parser.IParser
+	reader *parser.Reader
+	wMutex sync.Mutex
+	name   string
+	minSig int
+
+	signKey ed25519.PrivateKey  // Ключ подписи отправляемых сообщений (nil - без подписи)
+	keys    dto.IBgKeyDirectory // Ключи для проверки подписи принятых сообщений (nil - без проверки)
+	require bool                // Принимать только подписанные сообщения клиентов
+}
+
+// Dial - подключается к серверу, network может быть "tcp", "tls" или "unix".
+// Для "tls" conf может быть nil, тогда используется конфигурация по умолчанию
+func Dial(ctx context.Context, network, addr string, conf *tls.Config) (*Conn, error) {
+	var d net.Dialer
+	var c net.Conn
+	var err error
+	switch network {
+	case "tcp", "unix":
+		c, err = d.DialContext(ctx, network, addr)
+	case "tls":
+		if conf == nil {
+			conf = &tls.Config{}
+		}
+		if conf.ServerName == "" {
+			if host, _, e := net.SplitHostPort(addr); e == nil {
+				conf = conf.Clone()
+				conf.ServerName = host
+			}
+		}
+		if c, err = d.DialContext(ctx, "tcp", addr); err == nil {
+			c = tls.Client(c, conf)
+		}
+	default:
+		return nil, fmt.Errorf("Unsupported network %s", network)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewConn(c, parser.CreateEmptyParser(DefaultMaxPackageSize)), nil
+}
+
+// LoadTLSConfig - конфигурация TLS для Dial. caFile - CA сервера (пусто - системные),
+// certFile и keyFile - сертификат клиента для взаимной аутентификации (пусто - без сертификата)
+func LoadTLSConfig(caFile, certFile, keyFile string) (*tls.Config, error) {
+	conf := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile != "" {
+		pem, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		conf.RootCAs = x509.NewCertPool()
+		if !conf.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, errors.New("Can not parse CA certificate")
+		}
+	}
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		conf.Certificates = []tls.Certificate{cert}
+	}
+	return conf, nil
+}
+
+// NewConn - создает клиента поверх уже установленного соединения
+func NewConn(c net.Conn, p parser.IParser) *Conn {
+	return &Conn{conn: c, parser: p, reader: parser.NewReader(c, p)}
+}
+
+// Name - имя клиента после успешной авторизации
+func (c *Conn) Name() string {
+	return c.name
+}
+
+// SetSigningKey - закрытый ключ ed25519 для подписи сообщений другим клиентам (To или Channel).
+// Открытый ключ должен быть в ClientDescriptor.PublicKeys клиента
+func (c *Conn) SetSigningKey(key ed25519.PrivateKey) {
+	c.signKey = key
+}
+
+// SetKeyDirectory - проверять подписи принятых сообщений клиентов ключами из keys.
+// Если require - неподписанные сообщения клиентов тоже считаются ошибкой
+func (c *Conn) SetKeyDirectory(keys dto.IBgKeyDirectory, require bool) {
+	c.keys, c.require = keys, require
+}
+
+// Send - отправляет сообщение, если From не указан подставляется имя авторизованного клиента.
+// Сообщение другому клиенту подписывается, если задан ключ (смотри SetSigningKey)
+func (c *Conn) Send(ctx context.Context, msg *dto.Message) error {
+	if msg.From == "" {
+		msg.From = c.name
+	}
+	if msg.ContentType == "" {
+		msg.ContentType = "text"
+	}
+	if c.signKey != nil && msg.Signature == "" && (msg.To != "" || msg.Channel != "") {
+		dto.SignMessage(msg, c.signKey)
+	}
+	data, err := c.parser.FormMessage(msg)
+	if err != nil {
+		return err
+	}
+	c.wMutex.Lock()
+	defer c.wMutex.Unlock()
+	if d, ok := ctx.Deadline(); ok {
+		c.conn.SetWriteDeadline(d)
+		defer c.conn.SetWriteDeadline(time.Time{})
+	}
+	_, err = c.conn.Write(data)
+	return err
+}
+
+// Receive - читает следующее сообщение от сервера.
+// Если задан каталог ключей (смотри SetKeyDirectory) проверяет подпись сообщения другого клиента,
+// при неверной подписи сообщение все равно записывается в msg и возвращается ошибка проверки
+func (c *Conn) Receive(ctx context.Context, msg *dto.Message) error {
+	m, err := c.reader.ReadMessage(ctx)
+	if err != nil {
+		return err
+	}
+	*msg = m
+	if c.keys != nil && m.From != "" && (m.Signature != "" || c.require) {
+		if err := dto.VerifyMessageFrom(ctx, c.keys, &m); err != nil {
+			return fmt.Errorf("Message from %s: %w", m.From, err)
+		}
+	}
+	return nil
+}
+
+// Close - закрывает соединение
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+// call - отправляет команду и ждет ответ на нее
+func (c *Conn) call(ctx context.Context, req *dto.Message) (dto.Message, error) {
+	if err := c.Send(ctx, req); err != nil {
+		return dto.Message{}, err
+	}
+	var resp dto.Message
+	if err := c.Receive(ctx, &resp); err != nil {
+		return resp, err
+	}
+	if resp.Command == dto.ErrorCOMMAND {
+		return resp, dto.ParseErrorInfo(resp.Data)
+	}
+	if resp.Command != req.Command {
+		return resp, fmt.Errorf("Unexpected answer %d on command %d", resp.Command, req.Command)
+	}
+	return resp, nil
+}
+
+// Generate - просит сервер сгенерировать нового клиента, возвращает его имя и токен.
+// После авторизации сервер с пакетом provision вместо этого выдает новый токен текущему клиенту (ротация)
+func (c *Conn) Generate(ctx context.Context) (string, string, error) {
+	resp, err := c.call(ctx, &dto.Message{
+		MessageMetaInf: dto.MessageMetaInf{Command: dto.GenerateCOMMAND},
+		MessageContent: dto.MessageContent{ContentType: "text"},
+	})
+	if err != nil {
+		return "", "", err
+	}
+	fields := strings.Split(string(resp.Data), dto.DataDelimiter)
+	if len(fields) != 2 {
+		return "", "", errors.New("Incorrect generate answer, it must be name;token")
+	}
+	return fields[0], fields[1], nil
+}
+
+// Register - регистрирует клиента с именем name, возвращает его токен
+func (c *Conn) Register(ctx context.Context, name string) (string, error) {
+	resp, err := c.call(ctx, &dto.Message{
+		MessageMetaInf: dto.MessageMetaInf{Command: dto.RegisterCOMMAND, From: name},
+		MessageContent: dto.MessageContent{ContentType: "text"},
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(resp.Data), nil
+}
+
+// RegisterWithCode - первая регистрация устройства name по одноразовому коду (смотри пакет provision), возвращает токен
+func (c *Conn) RegisterWithCode(ctx context.Context, name, code string) (string, error) {
+	resp, err := c.call(ctx, &dto.Message{
+		MessageMetaInf: dto.MessageMetaInf{Command: dto.RegisterCOMMAND, From: name},
+		MessageContent: dto.MessageContent{ContentType: "text", Data: []byte(code)},
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(resp.Data), nil
+}
+
+// SetMinSignature - минимальная версия подписи (dto.SignatureV1, dto.SignatureV2 или dto.SignatureV3), на которую клиент согласен.
+// По умолчанию используется самая новая версия из поддерживаемых сервером, в том числе dto.SignatureV1 для старых серверов
+func (c *Conn) SetMinSignature(version int) {
+	c.minSig = version
+}
+
+// signatureVersion - самая новая версия подписи, которую поддерживают клиент и сервер
+func (c *Conn) signatureVersion(challenge string) (int, error) {
+	best := 0
+	for _, v := range dto.ParseChallenge(challenge) {
+		if v <= dto.SignatureV3 && v > best {
+			best = v
+		}
+	}
+	if best == 0 || best < c.minSig {
+		return 0, errors.New("Server does not support required signature version")
+	}
+	return best, nil
+}
+
+// Auth - авторизация клиента по имени и токену (смотри описание обмена в dto)
+func (c *Conn) Auth(ctx context.Context, name, token string) error {
+	req := dto.Message{
+		MessageMetaInf: dto.MessageMetaInf{Command: dto.AuthCOMMAND, From: name},
+		MessageContent: dto.MessageContent{ContentType: "text"},
+	}
+	resp, err := c.call(ctx, &req)
+	if err != nil {
+		return err
+	}
+	challenge := string(resp.Data)
+	version, err := c.signatureVersion(challenge)
+	if err != nil {
+		return err
+	}
+	salt, err := newSalt()
+	if err != nil {
+		return err
+	}
+	data, err := dto.SignAuthData(version, name, challenge, salt, token, time.Now().Unix())
+	if err != nil {
+		return err
+	}
+	req.Data = data.Encode()
+	if resp, err = c.call(ctx, &req); err != nil {
+		return err
+	}
+	if string(resp.Data) != dto.AuthOK {
+		return fmt.Errorf("Authorization failed: %s", string(resp.Data))
+	}
+	c.name = name
+	return nil
+}
+
+func newSalt() (string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(salt), nil
+}
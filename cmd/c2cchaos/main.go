@@ -0,0 +1,89 @@
+// c2cchaos - tcp прокси, который искажает трафик между клиентами и сервером (смотри пакет chaos).
+// Позволяет проверить поведение парсера, сервера и прошивок на поврежденных, обрезанных,
+// задержанных и переставленных данных. Для каждого соединения используется seed+номер соединения
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"time"
+
+	"github.com/blabu/messagesLib/chaos"
+)
+
+func main() {
+	listen := flag.String("listen", "localhost:6061", "Proxy listen address")
+	target := flag.String("target", "localhost:6060", "Server address")
+	seed := flag.Int64("seed", time.Now().UnixNano(), "Random seed")
+	dir := flag.String("dir", "both", "Distorted direction: up (client to server), down (server to client) or both")
+	var f chaos.Faults
+	flag.Float64Var(&f.FlipRate, "flip", 0, "Bit flip probability per byte")
+	flag.Float64Var(&f.DropRate, "drop", 0, "Drop probability per byte")
+	flag.Float64Var(&f.TruncateRate, "truncate", 0, "Truncate probability per chunk")
+	flag.Float64Var(&f.SplitRate, "split", 0, "Split probability per chunk")
+	flag.Float64Var(&f.CoalesceRate, "coalesce", 0, "Coalesce probability per write")
+	flag.Float64Var(&f.ReorderRate, "reorder", 0, "Reorder probability per write")
+	flag.DurationVar(&f.Latency, "latency", 0, "Latency per chunk")
+	flag.DurationVar(&f.Jitter, "jitter", 0, "Max random latency addition")
+	flag.Parse()
+
+	// Оба направления искажаются при записи, так доступны склейка и перестановка
+	var up, down chaos.Faults
+	switch *dir {
+	case "up":
+		up = f
+	case "down":
+		down = f
+	case "both":
+		up, down = f, f
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown direction %s\n", *dir)
+		os.Exit(1)
+	}
+
+	l, err := net.Listen("tcp", *listen)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	log.Printf("Proxy %s -> %s, seed %d", *listen, *target, *seed)
+	for n := int64(0); ; n++ {
+		c, err := l.Accept()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		go proxy(c, *target, chaos.Config{Seed: *seed + 2*n, Write: down}, chaos.Config{Seed: *seed + 2*n + 1, Write: up})
+	}
+}
+
+// proxy - передает данные между клиентом c и сервером, down искажает поток к клиенту, up - к серверу
+func proxy(c net.Conn, target string, down, up chaos.Config) {
+	s, err := net.Dial("tcp", target)
+	if err != nil {
+		log.Printf("Dial %s: %v", target, err)
+		c.Close()
+		return
+	}
+	client := chaos.WrapConn(c, down)
+	server := chaos.WrapConn(s, up)
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(server, c)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(client, s)
+		done <- struct{}{}
+	}()
+	<-done
+	client.Close()
+	server.Close()
+	<-done
+	st, ss := client.Stats(), server.Stats()
+	log.Printf("%s closed: to client %+v, to server %+v", c.RemoteAddr(), st, ss)
+}
@@ -0,0 +1,167 @@
+// c2cclient - консольный клиент протокола клиент-клиент.
+// Подключается к серверу (tcp, tls, unix), проходит регистрацию и авторизацию,
+// отправляет сообщение из флагов или stdin и печатает входящие сообщения в JSON
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/blabu/messagesLib/client"
+	"github.com/blabu/messagesLib/dto"
+)
+
+func main() {
+	network := flag.String("net", "tcp", "Network: tcp, tls or unix")
+	addr := flag.String("addr", "localhost:6060", "Server address (socket path for unix)")
+	name := flag.String("name", "", "Client name")
+	token := flag.String("token", "", "Client token")
+	generate := flag.Bool("generate", false, "Ask server to generate a new client before auth")
+	register := flag.Bool("register", false, "Register client name before auth")
+	to := flag.String("to", "", "Message receiver")
+	channel := flag.String("channel", "", "Message channel")
+	cmd := flag.Uint("cmd", uint(dto.DataCOMMAND), "Message command")
+	contentType := flag.String("type", "text", "Message content type: text, binary, audio, video, file")
+	data := flag.String("data", "", "Message data, - to read it from stdin")
+	listen := flag.Bool("listen", false, "Keep printing incoming messages until interrupted")
+	timeout := flag.Duration("timeout", 10*time.Second, "Timeout for connect, auth and send")
+	caFile := flag.String("ca", "", "CA certificate file for tls")
+	certFile := flag.String("cert", "", "Client certificate file for tls")
+	keyFile := flag.String("key", "", "Client key file for tls")
+	insecure := flag.Bool("insecure", false, "Skip server certificate verification for tls")
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if err := run(ctx, &options{
+		network: *network, addr: *addr, name: *name, token: *token,
+		generate: *generate, register: *register,
+		to: *to, channel: *channel, cmd: uint16(*cmd), contentType: *contentType, data: *data,
+		listen: *listen, timeout: *timeout,
+		caFile: *caFile, certFile: *certFile, keyFile: *keyFile, insecure: *insecure,
+	}); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+type options struct {
+	network, addr, name, token string
+	generate, register         bool
+	to, channel, contentType   string
+	cmd                        uint16
+	data                       string
+	listen                     bool
+	timeout                    time.Duration
+	caFile, certFile, keyFile  string
+	insecure                   bool
+}
+
+func tlsConfig(opt *options) (*tls.Config, error) {
+	conf, err := client.LoadTLSConfig(opt.caFile, opt.certFile, opt.keyFile)
+	if err != nil {
+		return nil, err
+	}
+	conf.InsecureSkipVerify = opt.insecure
+	return conf, nil
+}
+
+func run(ctx context.Context, opt *options) error {
+	var conf *tls.Config
+	var err error
+	if opt.network == "tls" {
+		if conf, err = tlsConfig(opt); err != nil {
+			return err
+		}
+	}
+	c, err := withTimeout(ctx, opt.timeout, func(ctx context.Context) (*client.Conn, error) {
+		return client.Dial(ctx, opt.network, opt.addr, conf)
+	})
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	go func() {
+		<-ctx.Done()
+		c.Close()
+	}()
+
+	tctx, cancel := context.WithTimeout(ctx, opt.timeout)
+	defer cancel()
+	if opt.generate {
+		if opt.name, opt.token, err = c.Generate(tctx); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "generated name %s token %s\n", opt.name, opt.token)
+	}
+	if opt.register {
+		if opt.token, err = c.Register(tctx, opt.name); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "registered name %s token %s\n", opt.name, opt.token)
+	}
+	if opt.name != "" {
+		if err = c.Auth(tctx, opt.name, opt.token); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "authorized as %s\n", opt.name)
+	}
+	if opt.data != "" {
+		if err = send(tctx, c, opt); err != nil {
+			return err
+		}
+	}
+	cancel()
+	if !opt.listen {
+		return nil
+	}
+	for {
+		var msg dto.Message
+		if err = c.Receive(ctx, &msg); err != nil {
+			if ctx.Err() != nil || errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		js, err := msg.MarshalJSON()
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(js))
+	}
+}
+
+func send(ctx context.Context, c *client.Conn, opt *options) error {
+	data := []byte(opt.data)
+	if opt.data == "-" {
+		var err error
+		if data, err = ioutil.ReadAll(os.Stdin); err != nil {
+			return err
+		}
+	}
+	return c.Send(ctx, &dto.Message{
+		MessageMetaInf: dto.MessageMetaInf{
+			Command: opt.cmd,
+			To:      opt.to,
+			Channel: opt.channel,
+		},
+		MessageContent: dto.MessageContent{
+			ContentType: opt.contentType,
+			Data:        data,
+		},
+	})
+}
+
+func withTimeout(ctx context.Context, t time.Duration, f func(ctx context.Context) (*client.Conn, error)) (*client.Conn, error) {
+	ctx, cancel := context.WithTimeout(ctx, t)
+	defer cancel()
+	return f(ctx)
+}
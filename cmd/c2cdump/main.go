@@ -0,0 +1,222 @@
+// c2cdump - разбирает поток пакетов протокола клиент-клиент и печатает их в читаемом виде.
+// Данные читаются из файла или stdin как есть или как hex дамп (xxd, hexdump -C или просто hex строка),
+// также можно напечатать запись сессии (смотри пакет record)
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/blabu/messagesLib/dto"
+	"github.com/blabu/messagesLib/parser"
+	"github.com/blabu/messagesLib/record"
+)
+
+func main() {
+	input := flag.String("f", "-", "Input file, - for stdin")
+	isHex := flag.Bool("hex", false, "Input is a hex dump (xxd, hexdump -C or plain hex)")
+	maxSize := flag.Uint64("max", 1<<20, "Max package size")
+	asHex := flag.Bool("x", false, "Always print payload as hex")
+	isRec := flag.Bool("rec", false, "Input is a session record")
+	flag.Parse()
+
+	var r io.Reader = os.Stdin
+	if *input != "-" {
+		f, err := os.Open(*input)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		r = f
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	if *isRec {
+		corrupted, err := dumpRecord(os.Stdout, data, *maxSize, *asHex)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		if corrupted > 0 {
+			os.Exit(2)
+		}
+		return
+	}
+	if *isHex {
+		if data, err = decodeHexDump(data); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	}
+	if corrupted := dump(os.Stdout, data, *maxSize, *asHex); corrupted > 0 {
+		os.Exit(2)
+	}
+}
+
+// dump - печатает все пакеты из data, возвращает количество поврежденных участков и пакетов
+func dump(w io.Writer, data []byte, maxSize uint64, asHex bool) int {
+	p := parser.CreateEmptyParser(maxSize).(*parser.C2cParser)
+	corrupted := 0
+	frames := 0
+	pos := 0
+	for pos < len(data) {
+		start := bytes.Index(data[pos:], []byte(parser.BeginHeader))
+		if start < 0 {
+			corrupted++
+			printCorrupt(w, pos, len(data)-pos, "no frame start")
+			break
+		}
+		if start > 0 {
+			corrupted++
+			printCorrupt(w, pos, start, "garbage before frame")
+		}
+		pos += start
+		info, err := p.Dissect(data[pos:])
+		if err != nil {
+			corrupted++
+			printCorrupt(w, pos, len(parser.BeginHeader), err.Error())
+			pos += len(parser.BeginHeader)
+			continue
+		}
+		frames++
+		info.Offset = pos
+		if !info.ChecksumOK() {
+			corrupted++
+		}
+		printFrame(w, frames, &info, asHex)
+		pos += info.Size
+	}
+	fmt.Fprintf(w, "total: %d frames, %d corrupted regions\n", frames, corrupted)
+	return corrupted
+}
+
+// dumpRecord - печатает все пакеты записи сессии с их временем и направлением
+func dumpRecord(w io.Writer, data []byte, maxSize uint64, asHex bool) (int, error) {
+	rr, err := record.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return 0, err
+	}
+	fmt.Fprintf(w, "session started %s\n", rr.Start().Format(time.RFC3339Nano))
+	corrupted := 0
+	for {
+		rec, err := rr.Next()
+		if errors.Is(err, io.EOF) {
+			return corrupted, nil
+		}
+		if err != nil {
+			return corrupted, err
+		}
+		dir := "client -> server"
+		if rec.Dir == record.Outbound {
+			dir = "server -> client"
+		}
+		fmt.Fprintf(w, "+%v %s\n", rec.Time, dir)
+		corrupted += dump(w, rec.Frame, maxSize, asHex)
+	}
+}
+
+func printCorrupt(w io.Writer, offset, size int, reason string) {
+	fmt.Fprintf(w, "CORRUPT offset 0x%04X (%d) length %d: %s\n", offset, offset, size, reason)
+}
+
+func printFrame(w io.Writer, n int, info *parser.FrameInfo, asHex bool) {
+	fmt.Fprintf(w, "frame #%d offset 0x%04X (%d) size %d\n", n, info.Offset, info.Offset, info.Size)
+	fmt.Fprintf(w, "  header:   %q\n", info.Header)
+	fmt.Fprintf(w, "  proto:    %d\n", info.Proto)
+	fmt.Fprintf(w, "  from:     %q\n", info.From)
+	fmt.Fprintf(w, "  to:       %q\n", info.To)
+	name := dto.CommandName(info.Command)
+	if name == "" {
+		name = "unknown"
+	}
+	fmt.Fprintf(w, "  command:  %d (%s)\n", info.Command, name)
+	fmt.Fprintf(w, "  type:     %s -> %s\n", info.RawType, info.ContentType)
+	fmt.Fprintf(w, "  channel:  %q\n", info.Channel)
+	fmt.Fprintf(w, "  id:       %d\n", info.ID)
+	if info.Signature != "" {
+		fmt.Fprintf(w, "  sign:     %s\n", info.Signature)
+	}
+	if info.ChecksumOK() {
+		fmt.Fprintf(w, "  checksum: 0x%08X OK\n", info.Checksum)
+	} else {
+		fmt.Fprintf(w, "  checksum: 0x%08X BAD (expected 0x%08X)\n", info.Checksum, info.Expected)
+	}
+	if !asHex && isPrintable(info.Data) {
+		fmt.Fprintf(w, "  payload (%d bytes, text): %q\n", len(info.Data), info.Data)
+		return
+	}
+	fmt.Fprintf(w, "  payload (%d bytes, hex):\n", len(info.Data))
+	for _, line := range strings.Split(strings.TrimRight(hex.Dump(info.Data), "\n"), "\n") {
+		if line != "" {
+			fmt.Fprintf(w, "    %s\n", line)
+		}
+	}
+}
+
+func isPrintable(data []byte) bool {
+	if !utf8.Valid(data) {
+		return false
+	}
+	for _, r := range string(data) {
+		if r < ' ' && r != '\n' && r != '\r' && r != '\t' {
+			return false
+		}
+	}
+	return true
+}
+
+// decodeHexDump - понимает вывод xxd, hexdump -C, hex.Dump и просто hex строки (пробелы игнорируются)
+func decodeHexDump(dump []byte) ([]byte, error) {
+	var res []byte
+	for n, line := range strings.Split(string(dump), "\n") {
+		line = strings.TrimSpace(line)
+		if i := strings.Index(line, "|"); i >= 0 { // hexdump -C и hex.Dump печатают ASCII колонку между |
+			line = line[:i]
+		}
+		if i := strings.Index(line, "  "); i >= 0 && strings.Contains(line, ":") { // xxd печатает ASCII колонку через два пробела
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) > 1 && isOffset(fields) {
+			fields = fields[1:]
+		}
+		for _, f := range fields {
+			b, err := hex.DecodeString(f)
+			if err != nil {
+				return nil, fmt.Errorf("Line %d: %s", n+1, err.Error())
+			}
+			res = append(res, b...)
+		}
+	}
+	return res, nil
+}
+
+// isOffset - первая колонка строки это смещение ("00000010:" в xxd или "00000010" в hexdump -C),
+// а не данные (байты в дампах сгруппированы максимум по 2)
+func isOffset(fields []string) bool {
+	if strings.HasSuffix(fields[0], ":") {
+		return true
+	}
+	if len(fields[0]) < 7 || len(fields[0]) > 8 {
+		return false
+	}
+	for _, f := range fields[1:] {
+		if len(f) > 4 {
+			return false
+		}
+	}
+	return true
+}
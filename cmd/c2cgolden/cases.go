@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/blabu/messagesLib/dto"
+)
+
+var commands = []uint16{
+	dto.ErrorCOMMAND,
+	dto.PingCOMMAND,
+	dto.RegisterCOMMAND,
+	dto.GenerateCOMMAND,
+	dto.AuthCOMMAND,
+	dto.DataCOMMAND,
+	dto.SaveDataCOMMAND,
+	dto.PropertiesCOMMAND,
+	dto.ConnectCOMMAND,
+	dto.PartedCOMMAND,
+	dto.PatchCOMMAND,
+	dto.AckCOMMAND,
+}
+
+// Данные для каждого типа содержимого. Бинарные данные содержат начало и конец заголовка,
+// чтобы проверить что реализация опирается на размер из заголовка, а не ищет разделители в данных
+var contents = []struct {
+	contentType string
+	data        []byte
+}{
+	{"text", []byte("Hello, world")},
+	{"binary", []byte{0x00, 0x01, 0x7F, 0x80, 0xFF, '$', 'V', '1', ';', '#', '#', '#', 0x0D, 0x0A}},
+	{"audio", bytes.Repeat([]byte{0x55, 0xAA}, 20)},
+	{"video", bytes.Repeat([]byte{0x00, 0x00, 0x01, 0xB3}, 8)},
+	{"file", []byte("name.txt\x00file content\n")},
+}
+
+// cases - все эталоны: каждая команда с каждым типом содержимого, граничные случаи,
+// текстовые варианты протокола и заведомо неверные пакеты
+func cases() []vector {
+	var res []vector
+	for _, cmd := range commands {
+		for _, c := range contents {
+			res = append(res, vector{
+				Name:        fmt.Sprintf("%s_%s", dto.CommandName(cmd), c.contentType),
+				Proto:       1,
+				Command:     cmd,
+				CommandName: dto.CommandName(cmd),
+				ID:          uint8(cmd),
+				From:        "modem1",
+				To:          "server",
+				ContentType: c.contentType,
+				Data:        hex.EncodeToString(c.data),
+			})
+		}
+	}
+	res = append(res,
+		vector{Name: "empty_data", Proto: 1, Command: dto.PingCOMMAND, From: "modem1", ContentType: "binary"},
+		vector{Name: "no_from_to", Proto: 1, Command: dto.DataCOMMAND, ContentType: "text", Data: hex.EncodeToString([]byte("anonymous"))},
+		vector{Name: "channel", Proto: 1, Command: dto.DataCOMMAND, From: "modem1", Channel: "news", ContentType: "text", Data: hex.EncodeToString([]byte("to channel"))},
+		vector{Name: "max_id", Proto: 1, Command: dto.DataCOMMAND, ID: 0xFF, From: "modem1", To: "server", ContentType: "binary", Data: "00"},
+		vector{Name: "unknown_command", Proto: 1, Command: 0xABC, From: "modem1", ContentType: "binary", Data: "01"},
+		vector{Name: "utf8_text", Proto: 1, Command: dto.DataCOMMAND, From: "modem1", To: "server", ContentType: "text", Data: hex.EncodeToString([]byte("Привіт"))},
+		vector{Name: "large_binary", Proto: 1, Command: dto.SaveDataCOMMAND, ID: 1, From: "modem1", To: "server", ContentType: "binary", Data: hex.EncodeToString(sequence(4096))},
+		vector{Name: "armor_base64", Armor: "base64", Proto: 1, Command: dto.DataCOMMAND, ID: 7, From: "modem1", To: "server", ContentType: "binary", Data: hex.EncodeToString(contents[1].data)},
+		vector{Name: "armor_hex", Armor: "hex", Proto: 1, Command: dto.DataCOMMAND, ID: 7, From: "modem1", To: "server", ContentType: "binary", Data: hex.EncodeToString(contents[1].data)},
+	)
+	invalid := []struct {
+		name    string
+		corrupt func([]byte) []byte
+	}{
+		{"invalid_checksum", func(f []byte) []byte { f[len(f)-1] ^= 0xFF; return f }},
+		{"invalid_payload", func(f []byte) []byte { f[len(f)-5] ^= 0x01; return f }},
+		{"invalid_truncated", func(f []byte) []byte { return f[:len(f)-3] }},
+		{"invalid_version", func(f []byte) []byte { return bytes.Replace(f, []byte("$V1;"), []byte("$V2;"), 1) }},
+		{"invalid_size", func(f []byte) []byte { return replaceField(f, 7, "ZZ###") }},
+		{"invalid_too_big", func(f []byte) []byte { return replaceField(f, 7, "FFFFFFF###") }},
+		{"invalid_short_header", func(f []byte) []byte { return replaceField(f, 6, "1###") }},
+	}
+	for _, inv := range invalid {
+		res = append(res, vector{
+			Name: inv.name, Proto: 1, Command: dto.DataCOMMAND, ID: 1, From: "modem1", To: "server",
+			ContentType: "text", Data: hex.EncodeToString([]byte("Hello, world")), corrupt: inv.corrupt,
+		})
+	}
+	for i := range res {
+		res[i].Frame = strings.ToLower(res[i].Name) + ".bin"
+	}
+	return res
+}
+
+func sequence(n int) []byte {
+	res := make([]byte, n)
+	for i := range res {
+		res[i] = byte(i)
+	}
+	return res
+}
+
+// replaceField - заменяет поле заголовка с номером n (с нуля, после $V) и все последующие до конца заголовка на value
+func replaceField(frame []byte, n int, value string) []byte {
+	end := bytes.Index(frame, []byte("###")) + 3
+	fields := bytes.Split(frame[2:end-3], []byte(";"))
+	res := append([]byte(nil), frame[:2]...)
+	res = append(res, bytes.Join(fields[:n], []byte(";"))...)
+	res = append(res, ';')
+	res = append(res, value...)
+	return append(res, frame[end:]...)
+}
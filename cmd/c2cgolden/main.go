@@ -0,0 +1,211 @@
+// c2cgolden - генерирует и проверяет эталонные пакеты протокола клиент-клиент.
+// Эталоны лежат в каталоге (по умолчанию parser/testdata/golden): vectors.json описывает ожидаемые поля сообщения,
+// рядом лежат сами пакеты (*.bin). Прошивки могут проверить свою реализацию, разбирая эти пакеты
+// или формируя такие же сообщения и сравнивая результат побайтно.
+//
+//	c2cgolden -gen    - перегенерировать эталоны текущей реализацией
+//	c2cgolden         - проверить, что текущая реализация разбирает и формирует эталоны без отличий
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/blabu/messagesLib/dto"
+	"github.com/blabu/messagesLib/parser"
+)
+
+const (
+	vectorsFile = "vectors.json"
+	maxSize     = 1 << 16
+)
+
+// vector - описание одного эталонного пакета
+type vector struct {
+	Name        string `json:"name"`
+	Frame       string `json:"frame"`           // Файл с пакетом
+	Armor       string `json:"armor,omitempty"` // Текстовый вариант протокола: base64, hex (пусто - бинарный $V)
+	Proto       uint16 `json:"proto"`
+	Command     uint16 `json:"cmd"`
+	CommandName string `json:"cmdName,omitempty"`
+	ID          uint8  `json:"id"`
+	From        string `json:"from"`
+	To          string `json:"to"`
+	Channel     string `json:"channel"`
+	ContentType string `json:"contentType"`
+	Data        string `json:"data"`            // Данные в hex
+	Error       string `json:"error,omitempty"` // Для заведомо неверных пакетов - ожидаемая ошибка разбора
+
+	corrupt func(frame []byte) []byte // Портит правильный пакет (только при генерации)
+}
+
+func main() {
+	dir := flag.String("dir", filepath.Join("parser", "testdata", "golden"), "Golden vectors directory")
+	gen := flag.Bool("gen", false, "Regenerate golden vectors instead of verifying them")
+	flag.Parse()
+
+	var err error
+	if *gen {
+		err = generate(*dir)
+	} else {
+		var failed int
+		if failed, err = verify(*dir); err == nil && failed > 0 {
+			err = fmt.Errorf("%d golden vectors failed", failed)
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func newParser(armor string) (parser.IParser, error) {
+	switch armor {
+	case "":
+		return parser.CreateEmptyParser(maxSize), nil
+	case "base64":
+		return parser.CreateArmoredParser(maxSize, parser.ArmorBase64), nil
+	case "hex":
+		return parser.CreateArmoredParser(maxSize, parser.ArmorHex), nil
+	}
+	return nil, fmt.Errorf("Unknown armor %s", armor)
+}
+
+func (v *vector) message() (dto.Message, error) {
+	data, err := hex.DecodeString(v.Data)
+	if err != nil {
+		return dto.Message{}, err
+	}
+	return dto.Message{
+		MessageMetaInf: dto.MessageMetaInf{
+			Proto:   v.Proto,
+			Command: v.Command,
+			ID:      v.ID,
+			From:    v.From,
+			To:      v.To,
+			Channel: v.Channel,
+		},
+		MessageContent: dto.MessageContent{ContentType: v.ContentType, Data: data},
+	}, nil
+}
+
+func generate(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	vectors := cases()
+	for i := range vectors {
+		v := &vectors[i]
+		frame, err := v.form()
+		if err != nil {
+			return fmt.Errorf("%s: %v", v.Name, err)
+		}
+		if err = ioutil.WriteFile(filepath.Join(dir, v.Frame), frame, 0644); err != nil {
+			return err
+		}
+	}
+	js, err := json.MarshalIndent(vectors, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(dir, vectorsFile), append(js, '\n'), 0644)
+}
+
+// form - формирует пакет эталона, для неверных пакетов портит правильный пакет
+func (v *vector) form() ([]byte, error) {
+	p, err := newParser(v.Armor)
+	if err != nil {
+		return nil, err
+	}
+	msg, err := v.message()
+	if err != nil {
+		return nil, err
+	}
+	frame, err := p.FormMessage(&msg)
+	if err != nil || v.corrupt == nil {
+		return frame, err
+	}
+	frame = v.corrupt(frame)
+	if _, err = p.ParseMessage(frame); err == nil {
+		return nil, fmt.Errorf("Corrupted frame is parsed without error")
+	}
+	v.Error = err.Error()
+	return frame, nil
+}
+
+func verify(dir string) (int, error) {
+	js, err := ioutil.ReadFile(filepath.Join(dir, vectorsFile))
+	if err != nil {
+		return 0, err
+	}
+	var vectors []vector
+	if err = json.Unmarshal(js, &vectors); err != nil {
+		return 0, err
+	}
+	failed := 0
+	for i := range vectors {
+		if err := vectors[i].verify(dir); err != nil {
+			failed++
+			fmt.Printf("FAIL %s: %v\n", vectors[i].Name, err)
+			continue
+		}
+		fmt.Printf("ok   %s\n", vectors[i].Name)
+	}
+	return failed, nil
+}
+
+func (v *vector) verify(dir string) error {
+	frame, err := ioutil.ReadFile(filepath.Join(dir, v.Frame))
+	if err != nil {
+		return err
+	}
+	p, err := parser.InitParser(frame, maxSize)
+	if err != nil {
+		return err
+	}
+	if v.Error == "" {
+		if rest, err := p.IsFullReceiveMsg(frame); err != nil || rest != 0 {
+			return fmt.Errorf("IsFullReceiveMsg returns %d, %v", rest, err)
+		}
+		head, err := p.ReadPacketHeader(bytes.NewReader(frame))
+		if err != nil || !bytes.HasPrefix(frame, head) || !bytes.Contains(head, []byte(parser.EndHeader)) {
+			return fmt.Errorf("ReadPacketHeader returns %q, %v", head, err)
+		}
+	}
+	got, err := p.ParseMessage(frame)
+	if v.Error != "" {
+		if err == nil {
+			return fmt.Errorf("expected error %q, frame parsed", v.Error)
+		}
+		if err.Error() != v.Error {
+			return fmt.Errorf("expected error %q, got %q", v.Error, err.Error())
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	want, err := v.message()
+	if err != nil {
+		return err
+	}
+	if got.Proto != want.Proto || got.Command != want.Command || got.ID != want.ID ||
+		got.From != want.From || got.To != want.To || got.Channel != want.Channel ||
+		got.ContentType != want.ContentType || !bytes.Equal(got.Data, want.Data) {
+		return fmt.Errorf("parsed message differs: got %+v", got)
+	}
+	formed, err := p.FormMessage(&want)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(formed, frame) {
+		return fmt.Errorf("formed frame differs:\n got  %q\n want %q", formed, frame)
+	}
+	return nil
+}
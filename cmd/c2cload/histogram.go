@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Границы корзин гистограммы растут в 2 раза начиная с histogramBase
+const (
+	histogramBase    = 100 * time.Microsecond
+	histogramBuckets = 22 // последняя корзина - все что больше ~100 секунд
+)
+
+// histogram - гистограмма задержек, безопасна для одновременного использования
+type histogram struct {
+	buckets [histogramBuckets]int64
+	count   int64
+	sum     int64
+	max     int64
+}
+
+func bucketLimit(i int) time.Duration {
+	return histogramBase << uint(i)
+}
+
+func (h *histogram) add(d time.Duration) {
+	i := 0
+	for i < histogramBuckets-1 && d > bucketLimit(i) {
+		i++
+	}
+	atomic.AddInt64(&h.buckets[i], 1)
+	atomic.AddInt64(&h.count, 1)
+	atomic.AddInt64(&h.sum, int64(d))
+	for {
+		cur := atomic.LoadInt64(&h.max)
+		if int64(d) <= cur || atomic.CompareAndSwapInt64(&h.max, cur, int64(d)) {
+			return
+		}
+	}
+}
+
+// percentile - верхняя граница корзины, в которую попадает процентиль p (от 0 до 100)
+func (h *histogram) percentile(p float64) time.Duration {
+	count := atomic.LoadInt64(&h.count)
+	if count == 0 {
+		return 0
+	}
+	need := int64(float64(count)*p/100 + 0.5)
+	if need < 1 {
+		need = 1
+	}
+	var seen int64
+	for i := 0; i < histogramBuckets-1; i++ {
+		if seen += atomic.LoadInt64(&h.buckets[i]); seen >= need {
+			return bucketLimit(i)
+		}
+	}
+	return time.Duration(atomic.LoadInt64(&h.max))
+}
+
+func (h *histogram) print(w io.Writer, name string) {
+	count := atomic.LoadInt64(&h.count)
+	if count == 0 {
+		fmt.Fprintf(w, "%s: no samples\n", name)
+		return
+	}
+	fmt.Fprintf(w, "%s: %d samples, avg %v, p50 <=%v, p90 <=%v, p99 <=%v, max %v\n", name, count,
+		time.Duration(atomic.LoadInt64(&h.sum)/count), h.percentile(50), h.percentile(90), h.percentile(99),
+		time.Duration(atomic.LoadInt64(&h.max)))
+	var top int64
+	for i := range h.buckets {
+		if n := atomic.LoadInt64(&h.buckets[i]); n > top {
+			top = n
+		}
+	}
+	for i := range h.buckets {
+		n := atomic.LoadInt64(&h.buckets[i])
+		if n == 0 {
+			continue
+		}
+		limit := "<=" + bucketLimit(i).String()
+		if i == histogramBuckets-1 {
+			limit = ">" + bucketLimit(i-1).String()
+		}
+		fmt.Fprintf(w, "  %12s %8d %s\n", limit, n, strings.Repeat("#", int((n*40+top-1)/top)))
+	}
+}
@@ -0,0 +1,316 @@
+// c2cload - генератор нагрузки, который имитирует парк модемов.
+// Каждый клиент подключается к серверу, проходит авторизацию, периодически отправляет PingCOMMAND
+// с dto.ModemState и DataCOMMAND со случайными данными. После завершения печатает гистограммы задержек,
+// количество ошибок и пропускную способность.
+//
+// Для большого количества клиентов нужно поднять лимит открытых файлов (ulimit -n)
+// и, возможно, запускать генератор с нескольких машин или адресов
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/blabu/messagesLib/client"
+	"github.com/blabu/messagesLib/dto"
+)
+
+type options struct {
+	network, addr  string
+	clients        int
+	ramp, duration time.Duration
+	name, token    string
+	register       bool
+	ping, data     time.Duration
+	size           int
+	to             string
+	timeout        time.Duration
+	report         time.Duration
+	reconnect      time.Duration
+	tls            *tls.Config
+}
+
+func main() {
+	var opt options
+	flag.StringVar(&opt.network, "net", "tcp", "Network: tcp, tls or unix")
+	flag.StringVar(&opt.addr, "addr", "localhost:6060", "Server address")
+	flag.IntVar(&opt.clients, "clients", 100, "Number of simulated modems")
+	flag.DurationVar(&opt.ramp, "ramp", 10*time.Second, "Time to spread client connects over")
+	flag.DurationVar(&opt.duration, "duration", time.Minute, "Test duration (including ramp)")
+	flag.StringVar(&opt.name, "name", "modem%d", "Client name pattern, %d is replaced by the client number")
+	flag.StringVar(&opt.token, "token", "", "Token for all clients (empty - skip auth unless -register)")
+	flag.BoolVar(&opt.register, "register", false, "Register every client to get its token before auth")
+	flag.DurationVar(&opt.ping, "ping", 30*time.Second, "Ping interval per client (0 - no pings)")
+	flag.DurationVar(&opt.data, "data", time.Minute, "Data upload interval per client (0 - no uploads)")
+	flag.IntVar(&opt.size, "size", 256, "Data upload size in bytes")
+	flag.StringVar(&opt.to, "to", "", "Data receiver")
+	flag.DurationVar(&opt.timeout, "timeout", 10*time.Second, "Timeout for connect, auth and send")
+	flag.DurationVar(&opt.report, "report", 5*time.Second, "Progress report interval (0 - only final report)")
+	flag.DurationVar(&opt.reconnect, "reconnect", time.Second, "Delay before reconnecting a dropped client")
+	insecure := flag.Bool("insecure", false, "Skip server certificate verification for tls")
+	caFile := flag.String("ca", "", "CA certificate file for tls")
+	flag.Parse()
+
+	if opt.network == "tls" {
+		conf, err := client.LoadTLSConfig(*caFile, "", "")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		conf.InsecureSkipVerify = *insecure
+		opt.tls = conf
+	}
+	if !strings.Contains(opt.name, "%") {
+		opt.name += "%d"
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	// Без дедлайна в контексте, иначе чтение заголовка завершится по дедлайну раньше отмены и будет посчитано ошибкой
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	time.AfterFunc(opt.duration, cancel)
+
+	st := newStats()
+	if opt.report > 0 {
+		go st.progress(ctx, opt.report)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < opt.clients; i++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			delay := time.Duration(0)
+			if opt.clients > 1 {
+				delay = opt.ramp * time.Duration(n) / time.Duration(opt.clients)
+			}
+			if sleep(ctx, delay) {
+				(&modem{opt: &opt, st: st, n: n}).run(ctx)
+			}
+		}(i)
+	}
+	wg.Wait()
+	st.print(os.Stdout)
+}
+
+// sleep - ждет d, возвращает false если ctx отменен раньше
+func sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// modem - один имитируемый клиент
+type modem struct {
+	opt  *options
+	st   *stats
+	n    int
+	name string
+	rnd  *rand.Rand
+
+	mu      sync.Mutex
+	pending map[uint16][]time.Time // Время отправки сообщений, ожидающих ответ с той же командой
+}
+
+// maxPending - сколько неотвеченных сообщений одной команды помнить (сервер может не отвечать на них вообще)
+const maxPending = 16
+
+func (m *modem) run(ctx context.Context) {
+	m.name = fmt.Sprintf(m.opt.name, m.n)
+	m.rnd = rand.New(rand.NewSource(int64(m.n)))
+	for {
+		if err := m.session(ctx); err != nil && ctx.Err() == nil {
+			m.st.addError(err)
+		}
+		if !sleep(ctx, m.opt.reconnect) {
+			return
+		}
+	}
+}
+
+func (m *modem) timeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, m.opt.timeout)
+}
+
+// session - одно подключение клиента от соединения до разрыва
+func (m *modem) session(ctx context.Context) error {
+	tctx, cancel := m.timeout(ctx)
+	start := time.Now()
+	c, err := client.Dial(tctx, m.opt.network, m.opt.addr, m.opt.tls)
+	cancel()
+	if err != nil {
+		return fmt.Errorf("connect: %v", err)
+	}
+	defer c.Close()
+	m.st.connect.add(time.Since(start))
+	m.st.connected(1)
+	defer m.st.connected(-1)
+
+	token := m.opt.token
+	if m.opt.register {
+		tctx, cancel = m.timeout(ctx)
+		token, err = c.Register(tctx, m.name)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("register: %v", err)
+		}
+	}
+	if token != "" {
+		tctx, cancel = m.timeout(ctx)
+		start = time.Now()
+		err = c.Auth(tctx, m.name, token)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("auth: %v", err)
+		}
+		m.st.auth.add(time.Since(start))
+	}
+
+	m.mu.Lock()
+	m.pending = make(map[uint16][]time.Time)
+	m.mu.Unlock()
+	done := make(chan error, 1)
+	go func() {
+		done <- m.receive(ctx, c)
+	}()
+	ping := m.ticker(m.opt.ping)
+	data := m.ticker(m.opt.data)
+	defer ping.Stop()
+	defer data.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err = <-done:
+			return fmt.Errorf("receive: %v", err)
+		case <-ping.C:
+			if err = m.send(ctx, c, m.pingMessage()); err != nil {
+				return fmt.Errorf("send ping: %v", err)
+			}
+		case <-data.C:
+			if err = m.send(ctx, c, m.dataMessage()); err != nil {
+				return fmt.Errorf("send data: %v", err)
+			}
+		}
+	}
+}
+
+// ticker - периодический таймер со случайной начальной фазой, чтобы клиенты не отправляли сообщения одновременно
+type ticker struct {
+	C    <-chan time.Time
+	stop func()
+}
+
+func (t *ticker) Stop() {
+	t.stop()
+}
+
+func (m *modem) ticker(interval time.Duration) *ticker {
+	if interval <= 0 {
+		return &ticker{stop: func() {}}
+	}
+	c := make(chan time.Time, 1)
+	quit := make(chan struct{})
+	go func(phase time.Duration) {
+		t := time.NewTimer(phase)
+		defer t.Stop()
+		for {
+			select {
+			case now := <-t.C:
+				select {
+				case c <- now:
+				default:
+				}
+				t.Reset(interval)
+			case <-quit:
+				return
+			}
+		}
+	}(time.Duration(m.rnd.Int63n(int64(interval))))
+	return &ticker{C: c, stop: func() { close(quit) }}
+}
+
+func (m *modem) pingMessage() *dto.Message {
+	state := dto.ModemState{
+		Name:     m.name,
+		LastPing: time.Now().Unix(),
+		Voltage:  uint16(3300 + m.rnd.Intn(900)),
+		Signal:   uint8(m.rnd.Intn(32)),
+	}
+	js, _ := state.MarshalJSON()
+	return &dto.Message{
+		MessageMetaInf: dto.MessageMetaInf{Command: dto.PingCOMMAND},
+		MessageContent: dto.MessageContent{ContentType: "text", Data: js},
+	}
+}
+
+func (m *modem) dataMessage() *dto.Message {
+	data := make([]byte, m.opt.size)
+	m.rnd.Read(data)
+	return &dto.Message{
+		MessageMetaInf: dto.MessageMetaInf{Command: dto.DataCOMMAND, To: m.opt.to},
+		MessageContent: dto.MessageContent{ContentType: "binary", Data: data},
+	}
+}
+
+func (m *modem) send(ctx context.Context, c *client.Conn, msg *dto.Message) error {
+	tctx, cancel := m.timeout(ctx)
+	defer cancel()
+	m.mu.Lock()
+	queue := append(m.pending[msg.Command], time.Now())
+	if len(queue) > maxPending {
+		m.st.unanswered(len(queue) - maxPending)
+		queue = queue[len(queue)-maxPending:]
+	}
+	m.pending[msg.Command] = queue
+	m.mu.Unlock()
+	if err := c.Send(tctx, msg); err != nil {
+		return err
+	}
+	m.st.sent(msg)
+	return nil
+}
+
+// receive - принимает ответы сервера и считает задержку до ответа с той же командой
+func (m *modem) receive(ctx context.Context, c *client.Conn) error {
+	for {
+		var msg dto.Message
+		if err := c.Receive(ctx, &msg); err != nil {
+			return err
+		}
+		m.st.received(&msg)
+		m.mu.Lock()
+		queue := m.pending[msg.Command]
+		var sent time.Time
+		if len(queue) > 0 {
+			sent = queue[0]
+			m.pending[msg.Command] = queue[1:]
+		}
+		m.mu.Unlock()
+		if sent.IsZero() {
+			continue
+		}
+		switch msg.Command {
+		case dto.PingCOMMAND:
+			m.st.ping.add(time.Since(sent))
+		case dto.DataCOMMAND:
+			m.st.data.add(time.Since(sent))
+		}
+	}
+}
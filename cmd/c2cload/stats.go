@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/blabu/messagesLib/dto"
+)
+
+// stats - общая статистика всех клиентов
+type stats struct {
+	online        int64
+	sentMsgs      int64
+	sentBytes     int64
+	recvMsgs      int64
+	recvBytes     int64
+	serverErrors  int64
+	noAnswer      int64
+	connect, auth histogram
+	ping, data    histogram
+	start         time.Time
+
+	mu     sync.Mutex
+	errors map[string]int64
+}
+
+func newStats() *stats {
+	return &stats{start: time.Now(), errors: make(map[string]int64)}
+}
+
+func (st *stats) connected(delta int64) {
+	atomic.AddInt64(&st.online, delta)
+}
+
+func (st *stats) unanswered(n int) {
+	atomic.AddInt64(&st.noAnswer, int64(n))
+}
+
+func (st *stats) sent(msg *dto.Message) {
+	atomic.AddInt64(&st.sentMsgs, 1)
+	atomic.AddInt64(&st.sentBytes, int64(len(msg.Data)))
+}
+
+func (st *stats) received(msg *dto.Message) {
+	atomic.AddInt64(&st.recvMsgs, 1)
+	atomic.AddInt64(&st.recvBytes, int64(len(msg.Data)))
+	if msg.Command == dto.ErrorCOMMAND {
+		atomic.AddInt64(&st.serverErrors, 1)
+	}
+}
+
+// addError - считает ошибки по виду (текст до первого двоеточия после операции)
+func (st *stats) addError(err error) {
+	kind := err.Error()
+	if i := strings.Index(kind, ": "); i >= 0 {
+		if j := strings.Index(kind[i+2:], ": "); j >= 0 {
+			kind = kind[:i+2+j]
+		}
+	}
+	st.mu.Lock()
+	st.errors[kind]++
+	st.mu.Unlock()
+}
+
+func (st *stats) progress(ctx context.Context, interval time.Duration) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	var lastSent, lastRecv int64
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			sent, recv := atomic.LoadInt64(&st.sentMsgs), atomic.LoadInt64(&st.recvMsgs)
+			st.mu.Lock()
+			var errs int64
+			for _, n := range st.errors {
+				errs += n
+			}
+			st.mu.Unlock()
+			fmt.Fprintf(os.Stderr, "%6s online %d, sent %.1f/s, received %.1f/s, errors %d\n",
+				time.Since(st.start).Round(time.Second), atomic.LoadInt64(&st.online),
+				float64(sent-lastSent)/interval.Seconds(), float64(recv-lastRecv)/interval.Seconds(), errs)
+			lastSent, lastRecv = sent, recv
+		}
+	}
+}
+
+func (st *stats) print(w io.Writer) {
+	elapsed := time.Since(st.start).Seconds()
+	fmt.Fprintf(w, "duration %.1fs\n", elapsed)
+	fmt.Fprintf(w, "sent     %d messages (%.1f/s), %d bytes (%.1f B/s)\n",
+		st.sentMsgs, float64(st.sentMsgs)/elapsed, st.sentBytes, float64(st.sentBytes)/elapsed)
+	fmt.Fprintf(w, "received %d messages (%.1f/s), %d bytes (%.1f B/s)\n",
+		st.recvMsgs, float64(st.recvMsgs)/elapsed, st.recvBytes, float64(st.recvBytes)/elapsed)
+	fmt.Fprintf(w, "server ErrorCOMMAND replies %d, messages without reply %d\n", st.serverErrors, st.noAnswer)
+	st.connect.print(w, "connect")
+	st.auth.print(w, "auth")
+	st.ping.print(w, "ping reply")
+	st.data.print(w, "data reply")
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	kinds := make([]string, 0, len(st.errors))
+	for k := range st.errors {
+		kinds = append(kinds, k)
+	}
+	sort.Strings(kinds)
+	fmt.Fprintf(w, "errors: %d kinds\n", len(kinds))
+	for _, k := range kinds {
+		fmt.Fprintf(w, "  %8d %s\n", st.errors[k], k)
+	}
+}
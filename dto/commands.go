@@ -2,6 +2,8 @@ package dto
 
 import (
 	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"strings"
 )
 
@@ -9,6 +11,7 @@ import (
 Набор поддерживаемых команд
 протого моста между клиентами
 Обработка всех команд происходит в Write методе
+(пакет router позволяет зарегистрировать обработчик для каждой команды)
 */
 const (
 	ErrorCOMMAND      uint16 = 1
@@ -22,9 +25,30 @@ const (
 	ConnectCOMMAND    uint16 = 9
 	PartedCOMMAND     uint16 = 10
 	PatchCOMMAND      uint16 = 11
+	AckCOMMAND        uint16 = 12 // Подтверждение доставки сообщения с ненулевым ID (для транспортов без гарантии доставки)
 )
 
-//CalculateSignature - generate signature
+var commandNames = map[uint16]string{
+	ErrorCOMMAND:      "ErrorCOMMAND",
+	PingCOMMAND:       "PingCOMMAND",
+	RegisterCOMMAND:   "RegisterCOMMAND",
+	GenerateCOMMAND:   "GenerateCOMMAND",
+	AuthCOMMAND:       "AuthCOMMAND",
+	DataCOMMAND:       "DataCOMMAND",
+	SaveDataCOMMAND:   "SaveDataCOMMAND",
+	PropertiesCOMMAND: "PropertiesCOMMAND",
+	ConnectCOMMAND:    "ConnectCOMMAND",
+	PartedCOMMAND:     "PartedCOMMAND",
+	PatchCOMMAND:      "PatchCOMMAND",
+	AckCOMMAND:        "AckCOMMAND",
+}
+
+//CommandName - имя команды для логов и отладки, для неизвестной команды вернет пустую строку
+func CommandName(cmd uint16) string {
+	return commandNames[cmd]
+}
+
+//CalculateSignature - generate signature (SignatureV1, смотри также CalculateSignatureV2)
 func CalculateSignature(name, salt, token string) [32]byte {
 	var cred strings.Builder
 	cred.WriteString(name)
@@ -32,3 +56,44 @@ func CalculateSignature(name, salt, token string) [32]byte {
 	cred.WriteString(token)
 	return sha256.Sum256([]byte(cred.String()))
 }
+
+/*
+Обмен командами регистрации и авторизации (поля разделяются DataDelimiter)
+GenerateCOMMAND - клиент просит сгенерировать новое имя, ответ "имя;токен",
+после авторизации - ротация токена текущего клиента (если поддерживается сервером), ответ тот же
+RegisterCOMMAND - клиент регистрирует имя из From, ответ - токен.
+В данных может быть одноразовый код регистрации (BootstrapCode)
+AuthCOMMAND - сначала клиент отправляет пустые данные, в ответ сервер присылает вызов (challenge)
+с версиями подписи, которые он принимает (FormChallenge), затем клиент выбирает версию и отправляет
+"соль;подпись", где подпись это hex от CalculateSignature(имя, вызов+соль, токен) (SignatureV1),
+или "v2;соль;время;подпись" с подписью CalculateSignatureV2, или "v3;соль;время;доказательство"
+с CalculateProofV3, если токен хранится на сервере в виде хеша (смотри AuthData и TokenKDF).
+Вызовом считается весь ответ сервера вместе с версиями.
+При успешной авторизации сервер отвечает AuthOK, иначе ErrorCOMMAND
+*/
+
+// DataDelimiter - разделитель полей в данных служебных команд
+const DataDelimiter = ";"
+
+// AuthOK - ответ сервера на успешную авторизацию
+const AuthOK = "OK"
+
+//FormAuthData - формирует данные второго шага авторизации
+func FormAuthData(salt string, sign [32]byte) []byte {
+	return []byte(salt + DataDelimiter + hex.EncodeToString(sign[:]))
+}
+
+//ParseAuthData - разбирает данные второго шага авторизации
+func ParseAuthData(data []byte) (string, [32]byte, error) {
+	var sign [32]byte
+	fields := strings.Split(string(data), DataDelimiter)
+	if len(fields) != 2 {
+		return "", sign, errors.New("Incorrect auth data, it must be salt;signature")
+	}
+	s, err := hex.DecodeString(fields[1])
+	if err != nil || len(s) != len(sign) {
+		return "", sign, errors.New("Incorrect signature")
+	}
+	copy(sign[:], s)
+	return fields[0], sign, nil
+}
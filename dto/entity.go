@@ -27,6 +27,7 @@ type MessageMetaInf struct {
 	SendedTime  int64  `json:"sendedTime,omitempty" db:"SendedTime"`
 	From        string `json:"from,omitempty" db:"FromName"`
 	To          string `json:"to,omitempty" db:"ToName"`
+	Signature   string `json:"sig,omitempty" db:"Signature"` // Подпись ed25519 отправителя в base64 (смотри SignMessage), пусто - без подписи
 }
 
 //Message - сообщение между клиентами. Сообщение разделено на мета информации и содержимое сообщения разделение позволяет исключить дубликаты содержимого сообщений
@@ -38,11 +39,39 @@ type Message struct {
 
 //ClientDescriptor - предоставляет базовую информацию о пользователе (человек, участник общения) через веб интерфейс
 type ClientDescriptor struct {
-	Name        string    `json:"name" db:"Name"`
-	Token       string    `json:"token,omitempty" db:"Token"`
-	ImageURL    string    `json:"image,omitempty" db:"ImageURL"`
-	CreatedDate time.Time `json:"created,omitempty" db:"CreatedDate"`
-	LastDate    time.Time `json:"activity,omitempty" db:"LastDate"`
+	Name        string        `json:"name" db:"Name"`
+	Token       string        `json:"token,omitempty" db:"Token"`
+	ImageURL    string        `json:"image,omitempty" db:"ImageURL"`
+	CreatedDate time.Time     `json:"created,omitempty" db:"CreatedDate"`
+	LastDate    time.Time     `json:"activity,omitempty" db:"LastDate"`
+	OldToken    string        `json:"oldToken,omitempty" db:"OldToken"`        // Предыдущий токен после ротации, действует до OldExpire
+	OldExpire   time.Time     `json:"oldExpire,omitempty" db:"OldTokenExpire"` // Конец периода перекрытия старого и нового токена
+	Revoked     bool          `json:"revoked,omitempty" db:"Revoked"`          // Учетные данные отозваны, авторизация запрещена
+	Limits      *ClientLimits `json:"limits,omitempty" db:"Limits"`            // Ограничения клиента (nil - ограничения по умолчанию)
+	PublicKeys  []string      `json:"publicKeys,omitempty" db:"PublicKeys"`    // Открытые ключи ed25519 в base64 для проверки подписи сообщений клиента
+}
+
+//ClientLimits - ограничения частоты сообщений и объема данных клиента (нулевое значение - без ограничения)
+type ClientLimits struct {
+	Rate       float64        `json:"rate,omitempty"`       // Сообщений в секунду
+	Burst      int            `json:"burst,omitempty"`      // Сколько сообщений можно отправить подряд
+	Commands   []CommandLimit `json:"commands,omitempty"`   // Ограничения отдельных команд (в дополнение к общему)
+	DailyBytes int64          `json:"dailyBytes,omitempty"` // Байт данных в сутки (UTC)
+}
+
+//CommandLimit - ограничение частоты одной команды
+type CommandLimit struct {
+	Command uint16  `json:"cmd"`
+	Rate    float64 `json:"rate"`
+	Burst   int     `json:"burst,omitempty"`
+}
+
+//BootstrapCode - одноразовый код первой регистрации устройства (выдается администратором или при производстве)
+type BootstrapCode struct {
+	Code    string    `json:"code" db:"Code"`
+	Name    string    `json:"name,omitempty" db:"Name"` // Имя, которое можно зарегистрировать с этим кодом (пусто - любое)
+	Expire  time.Time `json:"expire,omitempty" db:"Expire"`
+	Created time.Time `json:"created,omitempty" db:"Created"`
 }
 
 //Bot is entity with base communication and validation functions
@@ -69,3 +98,34 @@ type ModemState struct {
 	Voltage  uint16 `json:"voltage,omitempty"`
 	Signal   uint8  `json:"signal,omitempty"`
 }
+
+//ErrorInfo - содержимое ответа ErrorCOMMAND в json. Code - один из ErrCode..., Command - команда, на которую пришла ошибка
+type ErrorInfo struct {
+	Code    uint16 `json:"code"`
+	Command uint16 `json:"cmd,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+//ACLRule - правило доступа на отправку сообщений. From, To и Channel - шаблоны:
+//пусто или "*" - любое значение, "@группа" - член группы, "префикс*" - имя с префиксом, иначе точное имя.
+//Правила проверяются по убыванию Priority, при равном Priority запрещающие раньше разрешающих,
+//решение принимает первое подходящее правило
+type ACLRule struct {
+	ID       string `json:"id" db:"ID"`
+	Priority int    `json:"priority,omitempty" db:"Priority"`
+	Allow    bool   `json:"allow" db:"Allow"`
+	From     string `json:"from,omitempty" db:"FromName"`
+	To       string `json:"to,omitempty" db:"ToName"`
+	Channel  string `json:"channel,omitempty" db:"Channel"`
+	Comment  string `json:"comment,omitempty" db:"Comment"`
+}
+
+//ACLDenial - запись аудита о запрещенном сообщении
+type ACLDenial struct {
+	Time    time.Time `json:"time" db:"Time"`
+	From    string    `json:"from" db:"FromName"`
+	To      string    `json:"to,omitempty" db:"ToName"`
+	Channel string    `json:"channel,omitempty" db:"Channel"`
+	Command uint16    `json:"cmd,omitempty" db:"Command"`
+	Rule    string    `json:"rule,omitempty" db:"Rule"` // ID запретившего правила, пусто - политика по умолчанию
+}
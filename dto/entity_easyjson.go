@@ -143,6 +143,8 @@ func easyjson163c17a9DecodeGithubComBlabuMessagesLibDto1(in *jlexer.Lexer, out *
 			out.From = string(in.String())
 		case "to":
 			out.To = string(in.String())
+		case "sig":
+			out.Signature = string(in.String())
 		default:
 			in.SkipRecursive()
 		}
@@ -212,6 +214,11 @@ func easyjson163c17a9EncodeGithubComBlabuMessagesLibDto1(out *jwriter.Writer, in
 		out.RawString(prefix)
 		out.String(string(in.To))
 	}
+	if in.Signature != "" {
+		const prefix string = ",\"sig\":"
+		out.RawString(prefix)
+		out.String(string(in.Signature))
+	}
 	out.RawByte('}')
 }
 
@@ -399,6 +406,8 @@ func easyjson163c17a9DecodeGithubComBlabuMessagesLibDto3(in *jlexer.Lexer, out *
 			out.From = string(in.String())
 		case "to":
 			out.To = string(in.String())
+		case "sig":
+			out.Signature = string(in.String())
 		default:
 			in.SkipRecursive()
 		}
@@ -488,6 +497,11 @@ func easyjson163c17a9EncodeGithubComBlabuMessagesLibDto3(out *jwriter.Writer, in
 		out.RawString(prefix)
 		out.String(string(in.To))
 	}
+	if in.Signature != "" {
+		const prefix string = ",\"sig\":"
+		out.RawString(prefix)
+		out.String(string(in.Signature))
+	}
 	out.RawByte('}')
 }
 
@@ -514,7 +528,7 @@ func (v *Message) UnmarshalJSON(data []byte) error {
 func (v *Message) UnmarshalEasyJSON(l *jlexer.Lexer) {
 	easyjson163c17a9DecodeGithubComBlabuMessagesLibDto3(l, v)
 }
-func easyjson163c17a9DecodeGithubComBlabuMessagesLibDto4(in *jlexer.Lexer, out *ClientDescriptor) {
+func easyjson163c17a9DecodeGithubComBlabuMessagesLibDto4(in *jlexer.Lexer, out *ErrorInfo) {
 	isTopLevel := in.IsStart()
 	if in.IsNull() {
 		if isTopLevel {
@@ -533,20 +547,12 @@ func easyjson163c17a9DecodeGithubComBlabuMessagesLibDto4(in *jlexer.Lexer, out *
 			continue
 		}
 		switch key {
-		case "name":
-			out.Name = string(in.String())
-		case "token":
-			out.Token = string(in.String())
-		case "image":
-			out.ImageURL = string(in.String())
-		case "created":
-			if data := in.Raw(); in.Ok() {
-				in.AddError((out.CreatedDate).UnmarshalJSON(data))
-			}
-		case "activity":
-			if data := in.Raw(); in.Ok() {
-				in.AddError((out.LastDate).UnmarshalJSON(data))
-			}
+		case "code":
+			out.Code = uint16(in.Uint16())
+		case "cmd":
+			out.Command = uint16(in.Uint16())
+		case "message":
+			out.Message = string(in.String())
 		default:
 			in.SkipRecursive()
 		}
@@ -557,62 +563,52 @@ func easyjson163c17a9DecodeGithubComBlabuMessagesLibDto4(in *jlexer.Lexer, out *
 		in.Consumed()
 	}
 }
-func easyjson163c17a9EncodeGithubComBlabuMessagesLibDto4(out *jwriter.Writer, in ClientDescriptor) {
+func easyjson163c17a9EncodeGithubComBlabuMessagesLibDto4(out *jwriter.Writer, in ErrorInfo) {
 	out.RawByte('{')
 	first := true
 	_ = first
 	{
-		const prefix string = ",\"name\":"
+		const prefix string = ",\"code\":"
 		out.RawString(prefix[1:])
-		out.String(string(in.Name))
-	}
-	if in.Token != "" {
-		const prefix string = ",\"token\":"
-		out.RawString(prefix)
-		out.String(string(in.Token))
-	}
-	if in.ImageURL != "" {
-		const prefix string = ",\"image\":"
-		out.RawString(prefix)
-		out.String(string(in.ImageURL))
+		out.Uint16(uint16(in.Code))
 	}
-	if true {
-		const prefix string = ",\"created\":"
+	if in.Command != 0 {
+		const prefix string = ",\"cmd\":"
 		out.RawString(prefix)
-		out.Raw((in.CreatedDate).MarshalJSON())
+		out.Uint16(uint16(in.Command))
 	}
-	if true {
-		const prefix string = ",\"activity\":"
+	if in.Message != "" {
+		const prefix string = ",\"message\":"
 		out.RawString(prefix)
-		out.Raw((in.LastDate).MarshalJSON())
+		out.String(string(in.Message))
 	}
 	out.RawByte('}')
 }
 
 // MarshalJSON supports json.Marshaler interface
-func (v ClientDescriptor) MarshalJSON() ([]byte, error) {
+func (v ErrorInfo) MarshalJSON() ([]byte, error) {
 	w := jwriter.Writer{}
 	easyjson163c17a9EncodeGithubComBlabuMessagesLibDto4(&w, v)
 	return w.Buffer.BuildBytes(), w.Error
 }
 
 // MarshalEasyJSON supports easyjson.Marshaler interface
-func (v ClientDescriptor) MarshalEasyJSON(w *jwriter.Writer) {
+func (v ErrorInfo) MarshalEasyJSON(w *jwriter.Writer) {
 	easyjson163c17a9EncodeGithubComBlabuMessagesLibDto4(w, v)
 }
 
 // UnmarshalJSON supports json.Unmarshaler interface
-func (v *ClientDescriptor) UnmarshalJSON(data []byte) error {
+func (v *ErrorInfo) UnmarshalJSON(data []byte) error {
 	r := jlexer.Lexer{Data: data}
 	easyjson163c17a9DecodeGithubComBlabuMessagesLibDto4(&r, v)
 	return r.Error()
 }
 
 // UnmarshalEasyJSON supports easyjson.Unmarshaler interface
-func (v *ClientDescriptor) UnmarshalEasyJSON(l *jlexer.Lexer) {
+func (v *ErrorInfo) UnmarshalEasyJSON(l *jlexer.Lexer) {
 	easyjson163c17a9DecodeGithubComBlabuMessagesLibDto4(l, v)
 }
-func easyjson163c17a9DecodeGithubComBlabuMessagesLibDto5(in *jlexer.Lexer, out *Channel) {
+func easyjson163c17a9DecodeGithubComBlabuMessagesLibDto5(in *jlexer.Lexer, out *CommandLimit) {
 	isTopLevel := in.IsStart()
 	if in.IsNull() {
 		if isTopLevel {
@@ -631,26 +627,115 @@ func easyjson163c17a9DecodeGithubComBlabuMessagesLibDto5(in *jlexer.Lexer, out *
 			continue
 		}
 		switch key {
-		case "more":
-			out.More = string(in.String())
-		case "about":
-			out.About = string(in.String())
-		case "who":
-			out.CreatedBy = string(in.String())
-		case "name":
-			out.Name = string(in.String())
-		case "token":
-			out.Token = string(in.String())
-		case "image":
-			out.ImageURL = string(in.String())
-		case "created":
-			if data := in.Raw(); in.Ok() {
-				in.AddError((out.CreatedDate).UnmarshalJSON(data))
-			}
-		case "activity":
-			if data := in.Raw(); in.Ok() {
-				in.AddError((out.LastDate).UnmarshalJSON(data))
+		case "cmd":
+			out.Command = uint16(in.Uint16())
+		case "rate":
+			out.Rate = float64(in.Float64())
+		case "burst":
+			out.Burst = int(in.Int())
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson163c17a9EncodeGithubComBlabuMessagesLibDto5(out *jwriter.Writer, in CommandLimit) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"cmd\":"
+		out.RawString(prefix[1:])
+		out.Uint16(uint16(in.Command))
+	}
+	{
+		const prefix string = ",\"rate\":"
+		out.RawString(prefix)
+		out.Float64(float64(in.Rate))
+	}
+	if in.Burst != 0 {
+		const prefix string = ",\"burst\":"
+		out.RawString(prefix)
+		out.Int(int(in.Burst))
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v CommandLimit) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson163c17a9EncodeGithubComBlabuMessagesLibDto5(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v CommandLimit) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson163c17a9EncodeGithubComBlabuMessagesLibDto5(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *CommandLimit) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson163c17a9DecodeGithubComBlabuMessagesLibDto5(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *CommandLimit) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson163c17a9DecodeGithubComBlabuMessagesLibDto5(l, v)
+}
+func easyjson163c17a9DecodeGithubComBlabuMessagesLibDto6(in *jlexer.Lexer, out *ClientLimits) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "rate":
+			out.Rate = float64(in.Float64())
+		case "burst":
+			out.Burst = int(in.Int())
+		case "commands":
+			if in.IsNull() {
+				in.Skip()
+				out.Commands = nil
+			} else {
+				in.Delim('[')
+				if out.Commands == nil {
+					if !in.IsDelim(']') {
+						out.Commands = make([]CommandLimit, 0, 2)
+					} else {
+						out.Commands = []CommandLimit{}
+					}
+				} else {
+					out.Commands = (out.Commands)[:0]
+				}
+				for !in.IsDelim(']') {
+					var v7 CommandLimit
+					(v7).UnmarshalEasyJSON(in)
+					out.Commands = append(out.Commands, v7)
+					in.WantComma()
+				}
+				in.Delim(']')
 			}
+		case "dailyBytes":
+			out.DailyBytes = int64(in.Int64())
 		default:
 			in.SkipRecursive()
 		}
@@ -661,93 +746,82 @@ func easyjson163c17a9DecodeGithubComBlabuMessagesLibDto5(in *jlexer.Lexer, out *
 		in.Consumed()
 	}
 }
-func easyjson163c17a9EncodeGithubComBlabuMessagesLibDto5(out *jwriter.Writer, in Channel) {
+func easyjson163c17a9EncodeGithubComBlabuMessagesLibDto6(out *jwriter.Writer, in ClientLimits) {
 	out.RawByte('{')
 	first := true
 	_ = first
-	if in.More != "" {
-		const prefix string = ",\"more\":"
+	if in.Rate != 0 {
+		const prefix string = ",\"rate\":"
 		first = false
 		out.RawString(prefix[1:])
-		out.String(string(in.More))
+		out.Float64(float64(in.Rate))
 	}
-	if in.About != "" {
-		const prefix string = ",\"about\":"
+	if in.Burst != 0 {
+		const prefix string = ",\"burst\":"
 		if first {
 			first = false
 			out.RawString(prefix[1:])
 		} else {
 			out.RawString(prefix)
 		}
-		out.String(string(in.About))
+		out.Int(int(in.Burst))
 	}
-	if in.CreatedBy != "" {
-		const prefix string = ",\"who\":"
+	if len(in.Commands) != 0 {
+		const prefix string = ",\"commands\":"
 		if first {
 			first = false
 			out.RawString(prefix[1:])
 		} else {
 			out.RawString(prefix)
 		}
-		out.String(string(in.CreatedBy))
+		{
+			out.RawByte('[')
+			for v8, v9 := range in.Commands {
+				if v8 > 0 {
+					out.RawByte(',')
+				}
+				(v9).MarshalEasyJSON(out)
+			}
+			out.RawByte(']')
+		}
 	}
-	{
-		const prefix string = ",\"name\":"
+	if in.DailyBytes != 0 {
+		const prefix string = ",\"dailyBytes\":"
 		if first {
 			first = false
 			out.RawString(prefix[1:])
 		} else {
 			out.RawString(prefix)
 		}
-		out.String(string(in.Name))
-	}
-	if in.Token != "" {
-		const prefix string = ",\"token\":"
-		out.RawString(prefix)
-		out.String(string(in.Token))
-	}
-	if in.ImageURL != "" {
-		const prefix string = ",\"image\":"
-		out.RawString(prefix)
-		out.String(string(in.ImageURL))
-	}
-	if true {
-		const prefix string = ",\"created\":"
-		out.RawString(prefix)
-		out.Raw((in.CreatedDate).MarshalJSON())
-	}
-	if true {
-		const prefix string = ",\"activity\":"
-		out.RawString(prefix)
-		out.Raw((in.LastDate).MarshalJSON())
+		out.Int64(int64(in.DailyBytes))
 	}
 	out.RawByte('}')
 }
 
 // MarshalJSON supports json.Marshaler interface
-func (v Channel) MarshalJSON() ([]byte, error) {
+func (v ClientLimits) MarshalJSON() ([]byte, error) {
 	w := jwriter.Writer{}
-	easyjson163c17a9EncodeGithubComBlabuMessagesLibDto5(&w, v)
+	easyjson163c17a9EncodeGithubComBlabuMessagesLibDto6(&w, v)
 	return w.Buffer.BuildBytes(), w.Error
 }
 
 // MarshalEasyJSON supports easyjson.Marshaler interface
-func (v Channel) MarshalEasyJSON(w *jwriter.Writer) {
-	easyjson163c17a9EncodeGithubComBlabuMessagesLibDto5(w, v)
+func (v ClientLimits) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson163c17a9EncodeGithubComBlabuMessagesLibDto6(w, v)
 }
 
 // UnmarshalJSON supports json.Unmarshaler interface
-func (v *Channel) UnmarshalJSON(data []byte) error {
+func (v *ClientLimits) UnmarshalJSON(data []byte) error {
 	r := jlexer.Lexer{Data: data}
-	easyjson163c17a9DecodeGithubComBlabuMessagesLibDto5(&r, v)
+	easyjson163c17a9DecodeGithubComBlabuMessagesLibDto6(&r, v)
 	return r.Error()
 }
 
 // UnmarshalEasyJSON supports easyjson.Unmarshaler interface
-func (v *Channel) UnmarshalEasyJSON(l *jlexer.Lexer) {
-	easyjson163c17a9DecodeGithubComBlabuMessagesLibDto5(l, v)
+func (v *ClientLimits) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson163c17a9DecodeGithubComBlabuMessagesLibDto6(l, v)
 }
-func easyjson163c17a9DecodeGithubComBlabuMessagesLibDto6(in *jlexer.Lexer, out *Bot) {
+func easyjson163c17a9DecodeGithubComBlabuMessagesLibDto7(in *jlexer.Lexer, out *ClientDescriptor) {
 	isTopLevel := in.IsStart()
 	if in.IsNull() {
 		if isTopLevel {
@@ -766,14 +840,6 @@ func easyjson163c17a9DecodeGithubComBlabuMessagesLibDto6(in *jlexer.Lexer, out *
 			continue
 		}
 		switch key {
-		case "who":
-			out.CreatedBy = string(in.String())
-		case "about":
-			out.About = string(in.String())
-		case "endpoint":
-			out.Endpoint = string(in.String())
-		case "health":
-			out.HealthCheck = string(in.String())
 		case "name":
 			out.Name = string(in.String())
 		case "token":
@@ -788,6 +854,47 @@ func easyjson163c17a9DecodeGithubComBlabuMessagesLibDto6(in *jlexer.Lexer, out *
 			if data := in.Raw(); in.Ok() {
 				in.AddError((out.LastDate).UnmarshalJSON(data))
 			}
+		case "oldToken":
+			out.OldToken = string(in.String())
+		case "oldExpire":
+			if data := in.Raw(); in.Ok() {
+				in.AddError((out.OldExpire).UnmarshalJSON(data))
+			}
+		case "revoked":
+			out.Revoked = bool(in.Bool())
+		case "limits":
+			if in.IsNull() {
+				in.Skip()
+				out.Limits = nil
+			} else {
+				if out.Limits == nil {
+					out.Limits = new(ClientLimits)
+				}
+				(*out.Limits).UnmarshalEasyJSON(in)
+			}
+		case "publicKeys":
+			if in.IsNull() {
+				in.Skip()
+				out.PublicKeys = nil
+			} else {
+				in.Delim('[')
+				if out.PublicKeys == nil {
+					if !in.IsDelim(']') {
+						out.PublicKeys = make([]string, 0, 4)
+					} else {
+						out.PublicKeys = []string{}
+					}
+				} else {
+					out.PublicKeys = (out.PublicKeys)[:0]
+				}
+				for !in.IsDelim(']') {
+					var v10 string
+					v10 = string(in.String())
+					out.PublicKeys = append(out.PublicKeys, v10)
+					in.WantComma()
+				}
+				in.Delim(']')
+			}
 		default:
 			in.SkipRecursive()
 		}
@@ -798,54 +905,13 @@ func easyjson163c17a9DecodeGithubComBlabuMessagesLibDto6(in *jlexer.Lexer, out *
 		in.Consumed()
 	}
 }
-func easyjson163c17a9EncodeGithubComBlabuMessagesLibDto6(out *jwriter.Writer, in Bot) {
+func easyjson163c17a9EncodeGithubComBlabuMessagesLibDto7(out *jwriter.Writer, in ClientDescriptor) {
 	out.RawByte('{')
 	first := true
 	_ = first
-	if in.CreatedBy != "" {
-		const prefix string = ",\"who\":"
-		first = false
-		out.RawString(prefix[1:])
-		out.String(string(in.CreatedBy))
-	}
-	if in.About != "" {
-		const prefix string = ",\"about\":"
-		if first {
-			first = false
-			out.RawString(prefix[1:])
-		} else {
-			out.RawString(prefix)
-		}
-		out.String(string(in.About))
-	}
-	if in.Endpoint != "" {
-		const prefix string = ",\"endpoint\":"
-		if first {
-			first = false
-			out.RawString(prefix[1:])
-		} else {
-			out.RawString(prefix)
-		}
-		out.String(string(in.Endpoint))
-	}
-	if in.HealthCheck != "" {
-		const prefix string = ",\"health\":"
-		if first {
-			first = false
-			out.RawString(prefix[1:])
-		} else {
-			out.RawString(prefix)
-		}
-		out.String(string(in.HealthCheck))
-	}
 	{
 		const prefix string = ",\"name\":"
-		if first {
-			first = false
-			out.RawString(prefix[1:])
-		} else {
-			out.RawString(prefix)
-		}
+		out.RawString(prefix[1:])
 		out.String(string(in.Name))
 	}
 	if in.Token != "" {
@@ -868,29 +934,797 @@ func easyjson163c17a9EncodeGithubComBlabuMessagesLibDto6(out *jwriter.Writer, in
 		out.RawString(prefix)
 		out.Raw((in.LastDate).MarshalJSON())
 	}
+	if in.OldToken != "" {
+		const prefix string = ",\"oldToken\":"
+		out.RawString(prefix)
+		out.String(string(in.OldToken))
+	}
+	if true {
+		const prefix string = ",\"oldExpire\":"
+		out.RawString(prefix)
+		out.Raw((in.OldExpire).MarshalJSON())
+	}
+	if in.Revoked {
+		const prefix string = ",\"revoked\":"
+		out.RawString(prefix)
+		out.Bool(bool(in.Revoked))
+	}
+	if in.Limits != nil {
+		const prefix string = ",\"limits\":"
+		out.RawString(prefix)
+		(*in.Limits).MarshalEasyJSON(out)
+	}
+	if len(in.PublicKeys) != 0 {
+		const prefix string = ",\"publicKeys\":"
+		out.RawString(prefix)
+		{
+			out.RawByte('[')
+			for v11, v12 := range in.PublicKeys {
+				if v11 > 0 {
+					out.RawByte(',')
+				}
+				out.String(string(v12))
+			}
+			out.RawByte(']')
+		}
+	}
 	out.RawByte('}')
 }
 
 // MarshalJSON supports json.Marshaler interface
-func (v Bot) MarshalJSON() ([]byte, error) {
+func (v ClientDescriptor) MarshalJSON() ([]byte, error) {
 	w := jwriter.Writer{}
-	easyjson163c17a9EncodeGithubComBlabuMessagesLibDto6(&w, v)
+	easyjson163c17a9EncodeGithubComBlabuMessagesLibDto7(&w, v)
 	return w.Buffer.BuildBytes(), w.Error
 }
 
 // MarshalEasyJSON supports easyjson.Marshaler interface
-func (v Bot) MarshalEasyJSON(w *jwriter.Writer) {
-	easyjson163c17a9EncodeGithubComBlabuMessagesLibDto6(w, v)
+func (v ClientDescriptor) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson163c17a9EncodeGithubComBlabuMessagesLibDto7(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *ClientDescriptor) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson163c17a9DecodeGithubComBlabuMessagesLibDto7(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *ClientDescriptor) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson163c17a9DecodeGithubComBlabuMessagesLibDto7(l, v)
+}
+func easyjson163c17a9DecodeGithubComBlabuMessagesLibDto8(in *jlexer.Lexer, out *Channel) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "more":
+			out.More = string(in.String())
+		case "about":
+			out.About = string(in.String())
+		case "who":
+			out.CreatedBy = string(in.String())
+		case "name":
+			out.Name = string(in.String())
+		case "token":
+			out.Token = string(in.String())
+		case "image":
+			out.ImageURL = string(in.String())
+		case "created":
+			if data := in.Raw(); in.Ok() {
+				in.AddError((out.CreatedDate).UnmarshalJSON(data))
+			}
+		case "activity":
+			if data := in.Raw(); in.Ok() {
+				in.AddError((out.LastDate).UnmarshalJSON(data))
+			}
+		case "oldToken":
+			out.OldToken = string(in.String())
+		case "oldExpire":
+			if data := in.Raw(); in.Ok() {
+				in.AddError((out.OldExpire).UnmarshalJSON(data))
+			}
+		case "revoked":
+			out.Revoked = bool(in.Bool())
+		case "limits":
+			if in.IsNull() {
+				in.Skip()
+				out.Limits = nil
+			} else {
+				if out.Limits == nil {
+					out.Limits = new(ClientLimits)
+				}
+				(*out.Limits).UnmarshalEasyJSON(in)
+			}
+		case "publicKeys":
+			if in.IsNull() {
+				in.Skip()
+				out.PublicKeys = nil
+			} else {
+				in.Delim('[')
+				if out.PublicKeys == nil {
+					if !in.IsDelim(']') {
+						out.PublicKeys = make([]string, 0, 4)
+					} else {
+						out.PublicKeys = []string{}
+					}
+				} else {
+					out.PublicKeys = (out.PublicKeys)[:0]
+				}
+				for !in.IsDelim(']') {
+					var v13 string
+					v13 = string(in.String())
+					out.PublicKeys = append(out.PublicKeys, v13)
+					in.WantComma()
+				}
+				in.Delim(']')
+			}
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson163c17a9EncodeGithubComBlabuMessagesLibDto8(out *jwriter.Writer, in Channel) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	if in.More != "" {
+		const prefix string = ",\"more\":"
+		first = false
+		out.RawString(prefix[1:])
+		out.String(string(in.More))
+	}
+	if in.About != "" {
+		const prefix string = ",\"about\":"
+		if first {
+			first = false
+			out.RawString(prefix[1:])
+		} else {
+			out.RawString(prefix)
+		}
+		out.String(string(in.About))
+	}
+	if in.CreatedBy != "" {
+		const prefix string = ",\"who\":"
+		if first {
+			first = false
+			out.RawString(prefix[1:])
+		} else {
+			out.RawString(prefix)
+		}
+		out.String(string(in.CreatedBy))
+	}
+	{
+		const prefix string = ",\"name\":"
+		if first {
+			first = false
+			out.RawString(prefix[1:])
+		} else {
+			out.RawString(prefix)
+		}
+		out.String(string(in.Name))
+	}
+	if in.Token != "" {
+		const prefix string = ",\"token\":"
+		out.RawString(prefix)
+		out.String(string(in.Token))
+	}
+	if in.ImageURL != "" {
+		const prefix string = ",\"image\":"
+		out.RawString(prefix)
+		out.String(string(in.ImageURL))
+	}
+	if true {
+		const prefix string = ",\"created\":"
+		out.RawString(prefix)
+		out.Raw((in.CreatedDate).MarshalJSON())
+	}
+	if true {
+		const prefix string = ",\"activity\":"
+		out.RawString(prefix)
+		out.Raw((in.LastDate).MarshalJSON())
+	}
+	if in.OldToken != "" {
+		const prefix string = ",\"oldToken\":"
+		out.RawString(prefix)
+		out.String(string(in.OldToken))
+	}
+	if true {
+		const prefix string = ",\"oldExpire\":"
+		out.RawString(prefix)
+		out.Raw((in.OldExpire).MarshalJSON())
+	}
+	if in.Revoked {
+		const prefix string = ",\"revoked\":"
+		out.RawString(prefix)
+		out.Bool(bool(in.Revoked))
+	}
+	if in.Limits != nil {
+		const prefix string = ",\"limits\":"
+		out.RawString(prefix)
+		(*in.Limits).MarshalEasyJSON(out)
+	}
+	if len(in.PublicKeys) != 0 {
+		const prefix string = ",\"publicKeys\":"
+		out.RawString(prefix)
+		{
+			out.RawByte('[')
+			for v14, v15 := range in.PublicKeys {
+				if v14 > 0 {
+					out.RawByte(',')
+				}
+				out.String(string(v15))
+			}
+			out.RawByte(']')
+		}
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v Channel) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson163c17a9EncodeGithubComBlabuMessagesLibDto8(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v Channel) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson163c17a9EncodeGithubComBlabuMessagesLibDto8(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *Channel) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson163c17a9DecodeGithubComBlabuMessagesLibDto8(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *Channel) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson163c17a9DecodeGithubComBlabuMessagesLibDto8(l, v)
+}
+func easyjson163c17a9DecodeGithubComBlabuMessagesLibDto9(in *jlexer.Lexer, out *Bot) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "who":
+			out.CreatedBy = string(in.String())
+		case "about":
+			out.About = string(in.String())
+		case "endpoint":
+			out.Endpoint = string(in.String())
+		case "health":
+			out.HealthCheck = string(in.String())
+		case "name":
+			out.Name = string(in.String())
+		case "token":
+			out.Token = string(in.String())
+		case "image":
+			out.ImageURL = string(in.String())
+		case "created":
+			if data := in.Raw(); in.Ok() {
+				in.AddError((out.CreatedDate).UnmarshalJSON(data))
+			}
+		case "activity":
+			if data := in.Raw(); in.Ok() {
+				in.AddError((out.LastDate).UnmarshalJSON(data))
+			}
+		case "oldToken":
+			out.OldToken = string(in.String())
+		case "oldExpire":
+			if data := in.Raw(); in.Ok() {
+				in.AddError((out.OldExpire).UnmarshalJSON(data))
+			}
+		case "revoked":
+			out.Revoked = bool(in.Bool())
+		case "limits":
+			if in.IsNull() {
+				in.Skip()
+				out.Limits = nil
+			} else {
+				if out.Limits == nil {
+					out.Limits = new(ClientLimits)
+				}
+				(*out.Limits).UnmarshalEasyJSON(in)
+			}
+		case "publicKeys":
+			if in.IsNull() {
+				in.Skip()
+				out.PublicKeys = nil
+			} else {
+				in.Delim('[')
+				if out.PublicKeys == nil {
+					if !in.IsDelim(']') {
+						out.PublicKeys = make([]string, 0, 4)
+					} else {
+						out.PublicKeys = []string{}
+					}
+				} else {
+					out.PublicKeys = (out.PublicKeys)[:0]
+				}
+				for !in.IsDelim(']') {
+					var v16 string
+					v16 = string(in.String())
+					out.PublicKeys = append(out.PublicKeys, v16)
+					in.WantComma()
+				}
+				in.Delim(']')
+			}
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson163c17a9EncodeGithubComBlabuMessagesLibDto9(out *jwriter.Writer, in Bot) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	if in.CreatedBy != "" {
+		const prefix string = ",\"who\":"
+		first = false
+		out.RawString(prefix[1:])
+		out.String(string(in.CreatedBy))
+	}
+	if in.About != "" {
+		const prefix string = ",\"about\":"
+		if first {
+			first = false
+			out.RawString(prefix[1:])
+		} else {
+			out.RawString(prefix)
+		}
+		out.String(string(in.About))
+	}
+	if in.Endpoint != "" {
+		const prefix string = ",\"endpoint\":"
+		if first {
+			first = false
+			out.RawString(prefix[1:])
+		} else {
+			out.RawString(prefix)
+		}
+		out.String(string(in.Endpoint))
+	}
+	if in.HealthCheck != "" {
+		const prefix string = ",\"health\":"
+		if first {
+			first = false
+			out.RawString(prefix[1:])
+		} else {
+			out.RawString(prefix)
+		}
+		out.String(string(in.HealthCheck))
+	}
+	{
+		const prefix string = ",\"name\":"
+		if first {
+			first = false
+			out.RawString(prefix[1:])
+		} else {
+			out.RawString(prefix)
+		}
+		out.String(string(in.Name))
+	}
+	if in.Token != "" {
+		const prefix string = ",\"token\":"
+		out.RawString(prefix)
+		out.String(string(in.Token))
+	}
+	if in.ImageURL != "" {
+		const prefix string = ",\"image\":"
+		out.RawString(prefix)
+		out.String(string(in.ImageURL))
+	}
+	if true {
+		const prefix string = ",\"created\":"
+		out.RawString(prefix)
+		out.Raw((in.CreatedDate).MarshalJSON())
+	}
+	if true {
+		const prefix string = ",\"activity\":"
+		out.RawString(prefix)
+		out.Raw((in.LastDate).MarshalJSON())
+	}
+	if in.OldToken != "" {
+		const prefix string = ",\"oldToken\":"
+		out.RawString(prefix)
+		out.String(string(in.OldToken))
+	}
+	if true {
+		const prefix string = ",\"oldExpire\":"
+		out.RawString(prefix)
+		out.Raw((in.OldExpire).MarshalJSON())
+	}
+	if in.Revoked {
+		const prefix string = ",\"revoked\":"
+		out.RawString(prefix)
+		out.Bool(bool(in.Revoked))
+	}
+	if in.Limits != nil {
+		const prefix string = ",\"limits\":"
+		out.RawString(prefix)
+		(*in.Limits).MarshalEasyJSON(out)
+	}
+	if len(in.PublicKeys) != 0 {
+		const prefix string = ",\"publicKeys\":"
+		out.RawString(prefix)
+		{
+			out.RawByte('[')
+			for v17, v18 := range in.PublicKeys {
+				if v17 > 0 {
+					out.RawByte(',')
+				}
+				out.String(string(v18))
+			}
+			out.RawByte(']')
+		}
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v Bot) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson163c17a9EncodeGithubComBlabuMessagesLibDto9(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v Bot) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson163c17a9EncodeGithubComBlabuMessagesLibDto9(w, v)
 }
 
 // UnmarshalJSON supports json.Unmarshaler interface
 func (v *Bot) UnmarshalJSON(data []byte) error {
 	r := jlexer.Lexer{Data: data}
-	easyjson163c17a9DecodeGithubComBlabuMessagesLibDto6(&r, v)
+	easyjson163c17a9DecodeGithubComBlabuMessagesLibDto9(&r, v)
 	return r.Error()
 }
 
 // UnmarshalEasyJSON supports easyjson.Unmarshaler interface
 func (v *Bot) UnmarshalEasyJSON(l *jlexer.Lexer) {
-	easyjson163c17a9DecodeGithubComBlabuMessagesLibDto6(l, v)
+	easyjson163c17a9DecodeGithubComBlabuMessagesLibDto9(l, v)
+}
+func easyjson163c17a9DecodeGithubComBlabuMessagesLibDto10(in *jlexer.Lexer, out *BootstrapCode) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "code":
+			out.Code = string(in.String())
+		case "name":
+			out.Name = string(in.String())
+		case "expire":
+			if data := in.Raw(); in.Ok() {
+				in.AddError((out.Expire).UnmarshalJSON(data))
+			}
+		case "created":
+			if data := in.Raw(); in.Ok() {
+				in.AddError((out.Created).UnmarshalJSON(data))
+			}
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson163c17a9EncodeGithubComBlabuMessagesLibDto10(out *jwriter.Writer, in BootstrapCode) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"code\":"
+		out.RawString(prefix[1:])
+		out.String(string(in.Code))
+	}
+	if in.Name != "" {
+		const prefix string = ",\"name\":"
+		out.RawString(prefix)
+		out.String(string(in.Name))
+	}
+	if true {
+		const prefix string = ",\"expire\":"
+		out.RawString(prefix)
+		out.Raw((in.Expire).MarshalJSON())
+	}
+	if true {
+		const prefix string = ",\"created\":"
+		out.RawString(prefix)
+		out.Raw((in.Created).MarshalJSON())
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v BootstrapCode) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson163c17a9EncodeGithubComBlabuMessagesLibDto10(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v BootstrapCode) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson163c17a9EncodeGithubComBlabuMessagesLibDto10(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *BootstrapCode) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson163c17a9DecodeGithubComBlabuMessagesLibDto10(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *BootstrapCode) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson163c17a9DecodeGithubComBlabuMessagesLibDto10(l, v)
+}
+func easyjson163c17a9DecodeGithubComBlabuMessagesLibDto11(in *jlexer.Lexer, out *ACLRule) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "id":
+			out.ID = string(in.String())
+		case "priority":
+			out.Priority = int(in.Int())
+		case "allow":
+			out.Allow = bool(in.Bool())
+		case "from":
+			out.From = string(in.String())
+		case "to":
+			out.To = string(in.String())
+		case "channel":
+			out.Channel = string(in.String())
+		case "comment":
+			out.Comment = string(in.String())
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson163c17a9EncodeGithubComBlabuMessagesLibDto11(out *jwriter.Writer, in ACLRule) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"id\":"
+		out.RawString(prefix[1:])
+		out.String(string(in.ID))
+	}
+	if in.Priority != 0 {
+		const prefix string = ",\"priority\":"
+		out.RawString(prefix)
+		out.Int(int(in.Priority))
+	}
+	{
+		const prefix string = ",\"allow\":"
+		out.RawString(prefix)
+		out.Bool(bool(in.Allow))
+	}
+	if in.From != "" {
+		const prefix string = ",\"from\":"
+		out.RawString(prefix)
+		out.String(string(in.From))
+	}
+	if in.To != "" {
+		const prefix string = ",\"to\":"
+		out.RawString(prefix)
+		out.String(string(in.To))
+	}
+	if in.Channel != "" {
+		const prefix string = ",\"channel\":"
+		out.RawString(prefix)
+		out.String(string(in.Channel))
+	}
+	if in.Comment != "" {
+		const prefix string = ",\"comment\":"
+		out.RawString(prefix)
+		out.String(string(in.Comment))
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v ACLRule) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson163c17a9EncodeGithubComBlabuMessagesLibDto11(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v ACLRule) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson163c17a9EncodeGithubComBlabuMessagesLibDto11(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *ACLRule) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson163c17a9DecodeGithubComBlabuMessagesLibDto11(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *ACLRule) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson163c17a9DecodeGithubComBlabuMessagesLibDto11(l, v)
+}
+func easyjson163c17a9DecodeGithubComBlabuMessagesLibDto12(in *jlexer.Lexer, out *ACLDenial) {
+	isTopLevel := in.IsStart()
+	if in.IsNull() {
+		if isTopLevel {
+			in.Consumed()
+		}
+		in.Skip()
+		return
+	}
+	in.Delim('{')
+	for !in.IsDelim('}') {
+		key := in.UnsafeFieldName(false)
+		in.WantColon()
+		if in.IsNull() {
+			in.Skip()
+			in.WantComma()
+			continue
+		}
+		switch key {
+		case "time":
+			if data := in.Raw(); in.Ok() {
+				in.AddError((out.Time).UnmarshalJSON(data))
+			}
+		case "from":
+			out.From = string(in.String())
+		case "to":
+			out.To = string(in.String())
+		case "channel":
+			out.Channel = string(in.String())
+		case "cmd":
+			out.Command = uint16(in.Uint16())
+		case "rule":
+			out.Rule = string(in.String())
+		default:
+			in.SkipRecursive()
+		}
+		in.WantComma()
+	}
+	in.Delim('}')
+	if isTopLevel {
+		in.Consumed()
+	}
+}
+func easyjson163c17a9EncodeGithubComBlabuMessagesLibDto12(out *jwriter.Writer, in ACLDenial) {
+	out.RawByte('{')
+	first := true
+	_ = first
+	{
+		const prefix string = ",\"time\":"
+		out.RawString(prefix[1:])
+		out.Raw((in.Time).MarshalJSON())
+	}
+	{
+		const prefix string = ",\"from\":"
+		out.RawString(prefix)
+		out.String(string(in.From))
+	}
+	if in.To != "" {
+		const prefix string = ",\"to\":"
+		out.RawString(prefix)
+		out.String(string(in.To))
+	}
+	if in.Channel != "" {
+		const prefix string = ",\"channel\":"
+		out.RawString(prefix)
+		out.String(string(in.Channel))
+	}
+	if in.Command != 0 {
+		const prefix string = ",\"cmd\":"
+		out.RawString(prefix)
+		out.Uint16(uint16(in.Command))
+	}
+	if in.Rule != "" {
+		const prefix string = ",\"rule\":"
+		out.RawString(prefix)
+		out.String(string(in.Rule))
+	}
+	out.RawByte('}')
+}
+
+// MarshalJSON supports json.Marshaler interface
+func (v ACLDenial) MarshalJSON() ([]byte, error) {
+	w := jwriter.Writer{}
+	easyjson163c17a9EncodeGithubComBlabuMessagesLibDto12(&w, v)
+	return w.Buffer.BuildBytes(), w.Error
+}
+
+// MarshalEasyJSON supports easyjson.Marshaler interface
+func (v ACLDenial) MarshalEasyJSON(w *jwriter.Writer) {
+	easyjson163c17a9EncodeGithubComBlabuMessagesLibDto12(w, v)
+}
+
+// UnmarshalJSON supports json.Unmarshaler interface
+func (v *ACLDenial) UnmarshalJSON(data []byte) error {
+	r := jlexer.Lexer{Data: data}
+	easyjson163c17a9DecodeGithubComBlabuMessagesLibDto12(&r, v)
+	return r.Error()
+}
+
+// UnmarshalEasyJSON supports easyjson.Unmarshaler interface
+func (v *ACLDenial) UnmarshalEasyJSON(l *jlexer.Lexer) {
+	easyjson163c17a9DecodeGithubComBlabuMessagesLibDto12(l, v)
 }
@@ -0,0 +1,53 @@
+package dto
+
+import "fmt"
+
+// Коды ошибок в ответе ErrorCOMMAND (смотри ErrorInfo)
+const (
+	ErrCodeUnknown        uint16 = 0  // Ответ без json, текст ошибки в Message
+	ErrCodeInternal       uint16 = 1  // Ошибка бизнес логики
+	ErrCodeUnknownCommand uint16 = 2  // Команда не поддерживается
+	ErrCodeUnauthorized   uint16 = 3  // Команда требует авторизации
+	ErrCodeBadRequest     uint16 = 4  // Неверные данные команды
+	ErrCodeRateLimited    uint16 = 5  // Превышен лимит сообщений
+	ErrCodeForbidden      uint16 = 6  // Клиенту запрещена команда
+	ErrCodeReplay         uint16 = 7  // Повторно использованы данные авторизации (соль или вызов)
+	ErrCodeStale          uint16 = 8  // Вызов просрочен или время клиента вне допустимого окна
+	ErrCodeSessionLimit   uint16 = 9  // Превышено количество одновременных сессий клиента
+	ErrCodeQuotaExceeded  uint16 = 10 // Превышена суточная квота данных клиента
+	ErrCodeBadSignature   uint16 = 11 // Подпись сообщения отсутствует или неверна
+)
+
+func (e *ErrorInfo) Error() string {
+	if name := CommandName(e.Command); name != "" {
+		return fmt.Sprintf("Error %d on %s: %s", e.Code, name, e.Message)
+	}
+	return fmt.Sprintf("Error %d: %s", e.Code, e.Message)
+}
+
+//NewErrorMessage - ответ ErrorCOMMAND на сообщение req с ErrorInfo в данных
+func NewErrorMessage(req *Message, code uint16, text string) *Message {
+	info := ErrorInfo{Code: code, Command: req.Command, Message: text}
+	data, _ := info.MarshalJSON()
+	return &Message{
+		MessageMetaInf: MessageMetaInf{
+			Command: ErrorCOMMAND,
+			Proto:   req.Proto,
+			ID:      req.ID,
+			To:      req.From,
+		},
+		MessageContent: MessageContent{
+			ContentType: "text",
+			Data:        data,
+		},
+	}
+}
+
+//ParseErrorInfo - разбирает данные ответа ErrorCOMMAND. Ответ старых серверов (просто текст) вернется с кодом ErrCodeUnknown
+func ParseErrorInfo(data []byte) *ErrorInfo {
+	var info ErrorInfo
+	if err := info.UnmarshalJSON(data); err != nil || (info.Code == ErrCodeUnknown && info.Message == "") {
+		return &ErrorInfo{Code: ErrCodeUnknown, Message: string(data)}
+	}
+	return &info
+}
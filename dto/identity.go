@@ -0,0 +1,66 @@
+package dto
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// Политика проверки From сообщений на соединении с подтвержденным именем клиента
+const (
+	IdentityReject  = 0 // Отклонять сообщения с чужим From
+	IdentityRewrite = 1 // Заменять чужой From на имя соединения
+)
+
+// ErrIdentityMismatch - From сообщения не совпадает с подтвержденным именем соединения и делегирования нет
+var ErrIdentityMismatch = errors.New("From does not match authenticated identity")
+
+//MatchName - подходит ли имя name шаблону pattern: "*" - любое имя, "префикс*" - имя с префиксом, иначе точное имя
+func MatchName(pattern, name string) bool {
+	if strings.HasSuffix(pattern, "*") {
+		return strings.HasPrefix(name, pattern[:len(pattern)-1])
+	}
+	return pattern == name
+}
+
+//CanActAs - может ли agent (бот, шлюз) отправлять сообщения от имени from по делегированиям из d
+func CanActAs(ctx context.Context, d IBgDelegation, agent, from string) (bool, error) {
+	if d == nil {
+		return false, nil
+	}
+	patterns, err := d.GetDelegations(ctx, agent)
+	if err != nil {
+		return false, err
+	}
+	for _, p := range patterns {
+		if MatchName(p, from) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+//BindIdentity - привязывает сообщение msg к подтвержденному имени соединения name.
+//Пустой From заполняется именем, чужой From допускается только по делегированию,
+//иначе заменяется (IdentityRewrite) или возвращается ErrIdentityMismatch (IdentityReject)
+func BindIdentity(ctx context.Context, name string, msg *Message, policy int, d IBgDelegation) error {
+	if name == "" || msg.From == name {
+		return nil
+	}
+	if msg.From == "" {
+		msg.From = name
+		return nil
+	}
+	ok, err := CanActAs(ctx, d, name, msg.From)
+	if err != nil {
+		return err
+	}
+	if ok {
+		return nil
+	}
+	if policy == IdentityRewrite {
+		msg.From = name
+		return nil
+	}
+	return ErrIdentityMismatch
+}
@@ -0,0 +1,70 @@
+package dto
+
+import (
+	"context"
+	"testing"
+)
+
+// testDelegations - делегирования для тестов (имя агента "broken" - ошибка хранилища)
+type testDelegations map[string][]string
+
+func (d testDelegations) GetDelegations(ctx context.Context, agent string) ([]string, error) {
+	if agent == "broken" {
+		return nil, errStorage
+	}
+	return d[agent], nil
+}
+
+func TestMatchName(t *testing.T) {
+	cases := []struct {
+		pattern, name string
+		ok            bool
+	}{
+		{"*", "modem", true},
+		{"*", "", true},
+		{"modem*", "modem1", true},
+		{"modem*", "modem", true},
+		{"modem*", "bot", false},
+		{"modem", "modem", true},
+		{"modem", "modem1", false},
+		{"", "", true},
+		{"", "modem", false},
+	}
+	for _, tc := range cases {
+		if ok := MatchName(tc.pattern, tc.name); ok != tc.ok {
+			t.Errorf("MatchName(%q, %q) %v, want %v", tc.pattern, tc.name, ok, tc.ok)
+		}
+	}
+}
+
+func TestBindIdentity(t *testing.T) {
+	d := testDelegations{"gateway": {"modem*", "sensor"}, "admin": {"*"}}
+	cases := []struct {
+		name   string
+		conn   string
+		from   string
+		policy int
+		d      IBgDelegation
+		want   string
+		err    error
+	}{
+		{"not_authenticated", "", "modem", IdentityReject, d, "modem", nil},
+		{"same", "modem", "modem", IdentityReject, d, "modem", nil},
+		{"empty_from", "modem", "", IdentityReject, d, "modem", nil},
+		{"reject", "modem", "other", IdentityReject, d, "other", ErrIdentityMismatch},
+		{"rewrite", "modem", "other", IdentityRewrite, d, "modem", nil},
+		{"no_delegations", "gateway", "modem1", IdentityReject, nil, "modem1", ErrIdentityMismatch},
+		{"delegated_prefix", "gateway", "modem1", IdentityReject, d, "modem1", nil},
+		{"delegated_exact", "gateway", "sensor", IdentityRewrite, d, "sensor", nil},
+		{"not_delegated_reject", "gateway", "sensor2", IdentityReject, d, "sensor2", ErrIdentityMismatch},
+		{"not_delegated_rewrite", "gateway", "bot", IdentityRewrite, d, "gateway", nil},
+		{"delegated_any", "admin", "bot", IdentityReject, d, "bot", nil},
+		{"storage_error", "broken", "modem", IdentityRewrite, d, "modem", errStorage},
+	}
+	for _, tc := range cases {
+		msg := Message{MessageMetaInf: MessageMetaInf{Command: DataCOMMAND, From: tc.from}}
+		if err := BindIdentity(context.Background(), tc.conn, &msg, tc.policy, tc.d); err != tc.err || msg.From != tc.want {
+			t.Errorf("%s: From %q %v, want %q %v", tc.name, msg.From, err, tc.want, tc.err)
+		}
+	}
+}
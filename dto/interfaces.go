@@ -2,6 +2,8 @@ package dto
 
 import (
 	"context"
+	"crypto/ed25519"
+	"errors"
 	"io"
 	"time"
 )
@@ -98,6 +100,68 @@ type IBgClientSaver interface {
 	GenerateClient(ctx context.Context, name string) (ClientDescriptor, error)
 }
 
+var (
+	// ErrNotFound - записи с таким ключом нет в хранилище. Хранилища возвращают ее (или обертку над ней),
+	// чтобы отсутствие записи можно было отличить от ошибки хранилища (например в ClientKeyDirectory)
+	ErrNotFound = errors.New("Not found")
+	// ErrClientExists - клиент с таким именем уже есть (смотри IBgClientCreator)
+	ErrClientExists = errors.New("Client already exists")
+	// ErrRevoked - учетные данные клиента отозваны (смотри IBgClientUpdater)
+	ErrRevoked = errors.New("Client credentials are revoked")
+)
+
+//IBgClientCreator - хранилище клиентов с атомарным созданием (дополнение к IBgClientSaver).
+//CreateClient сохраняет клиента только если клиента с таким именем нет или он отозван, иначе возвращает ErrClientExists
+type IBgClientCreator interface {
+	CreateClient(ctx context.Context, cl *ClientDescriptor) error
+}
+
+//IBgClientGenerator - хранилище, которое сохраняет сгенерированного клиента сразу с хешем токена (дополнение к IBgClientSaver).
+//GenerateHashedClient работает как GenerateClient, но сохраняет hash(токен) и возвращает открытый токен
+type IBgClientGenerator interface {
+	GenerateHashedClient(ctx context.Context, name string, hash func(token string) (string, error)) (ClientDescriptor, error)
+}
+
+//IBgClientUpdater - хранилище клиентов с атомарным изменением (дополнение к IBgClientSaver).
+//UpdateClient читает клиента name, вызывает update и сохраняет результат так, что между чтением и сохранением
+//клиента никто не изменит, ошибка update возвращается без сохранения.
+//TouchClient меняет только LastDate и возвращает ErrRevoked для отозванного клиента
+type IBgClientUpdater interface {
+	UpdateClient(ctx context.Context, name string, update func(cl *ClientDescriptor) error) error
+	TouchClient(ctx context.Context, name string, last time.Time) error
+}
+
+//IBgKeyDirectory - каталог открытых ключей ed25519 клиентов для проверки подписи сообщений (смотри ClientKeyDirectory)
+type IBgKeyDirectory interface {
+	GetPublicKeys(ctx context.Context, name string) ([]ed25519.PublicKey, error)
+}
+
+//IBgBootstrapCode - хранилище одноразовых кодов первой регистрации
+type IBgBootstrapCode interface {
+	SaveCode(ctx context.Context, code *BootstrapCode) error
+	TakeCode(ctx context.Context, code string) (BootstrapCode, error) // Возвращает и удаляет код, повторный вызов с тем же кодом вернет ошибку
+}
+
+//IBgACL - хранилище правил доступа и групп клиентов и каналов для правил
+type IBgACL interface {
+	GetRules(ctx context.Context) ([]ACLRule, error)
+	SaveRule(ctx context.Context, rule *ACLRule) error // Пустой ID - новое правило, ID заполняется хранилищем
+	DeleteRule(ctx context.Context, id string) error
+	GetGroups(ctx context.Context, name string) ([]string, error) // Группы, в которые входит клиент или канал name
+	AddToGroup(ctx context.Context, group, name string) error
+	RemoveFromGroup(ctx context.Context, group, name string) error
+}
+
+//IBgACLAudit - журнал запрещенных сообщений
+type IBgACLAudit interface {
+	AddDenial(ctx context.Context, d *ACLDenial) error
+}
+
+//IBgDelegation - делегирования: от имени каких клиентов может отправлять сообщения бот или шлюз agent
+type IBgDelegation interface {
+	GetDelegations(ctx context.Context, agent string) ([]string, error) // Шаблоны имен (смотри MatchName)
+}
+
 type IBgMsgSaver interface {
 	Get(ctx context.Context, key string) (MessageContent, error)
 	Set(ctx context.Context, key string, val *MessageContent) error
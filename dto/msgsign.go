@@ -0,0 +1,155 @@
+package dto
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"strings"
+)
+
+/*
+Подпись сообщений ed25519 (сквозная, от отправителя до получателя).
+Отправитель подписывает своим закрытым ключом канонический вид сообщения (смотри MessageSignedData):
+версию протокола, команду, From, To, Channel, тип содержимого и данные. ID, UID, время и хеш не подписываются,
+они меняются при пересылке и хранении. Подпись передается в заголовке пакета и хранится в MessageMetaInf.Signature,
+поэтому переживает сохранение и пересылку через IMessanger.
+Открытые ключи клиента хранятся в ClientDescriptor.PublicKeys (смотри ClientKeyDirectory)
+
+Подпись подтверждает только автора и целостность сообщения, защиты от повтора в ней нет:
+ID и время не подписываются, поэтому перехваченное подписанное сообщение можно отправить повторно
+и оно пройдет проверку. Если получателю важна свежесть или уникальность сообщения, отправитель
+должен включить время или одноразовый номер в Data (данные подписываются), а получатель - проверять их
+и помнить уже принятые номера
+*/
+
+// messageSignDomain - префикс канонического вида сообщения, отделяет подпись сообщения от других подписей тем же ключом
+const messageSignDomain = "c2c-message-ed25519-v1"
+
+// Ошибки проверки подписи сообщения
+var (
+	ErrNoSignature   = errors.New("Message is not signed")
+	ErrBadSignature  = errors.New("Invalid message signature")
+	ErrUnknownSigner = errors.New("No public keys for message sender")
+	ErrBadPublicKey  = errors.New("Invalid ed25519 public key")
+)
+
+// contentTypeCode - тип содержимого в том виде, в котором он передается в заголовке пакета (первая буква)
+func contentTypeCode(contentType string) string {
+	if contentType == "" {
+		return ""
+	}
+	return strings.ToUpper(contentType[:1])
+}
+
+//MessageSignedData - канонический вид сообщения для подписи: префикс, версия протокола и команда,
+//затем From, To, Channel, тип содержимого и данные, каждое поле с длиной
+func MessageSignedData(msg *Message) []byte {
+	proto := msg.Proto
+	if proto == 0 {
+		proto = 1 // Как в FormMessage
+	}
+	res := make([]byte, 0, 64+len(msg.From)+len(msg.To)+len(msg.Channel)+len(msg.Data))
+	res = append(res, messageSignDomain...)
+	var buf [4]byte
+	binary.BigEndian.PutUint16(buf[:2], proto)
+	binary.BigEndian.PutUint16(buf[2:], msg.Command)
+	res = append(res, buf[:]...)
+	for _, field := range [...][]byte{[]byte(msg.From), []byte(msg.To), []byte(msg.Channel), []byte(contentTypeCode(msg.ContentType)), msg.Data} {
+		binary.BigEndian.PutUint32(buf[:], uint32(len(field)))
+		res = append(res, buf[:]...)
+		res = append(res, field...)
+	}
+	return res
+}
+
+//SignMessage - подписывает сообщение закрытым ключом отправителя и записывает подпись в Signature.
+//Сообщение нельзя менять после подписи
+func SignMessage(msg *Message, key ed25519.PrivateKey) {
+	msg.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(key, MessageSignedData(msg)))
+}
+
+//VerifyMessage - проверяет подпись сообщения одним из открытых ключей отправителя.
+//Повторно отправленное подписанное сообщение тоже проходит проверку (смотри описание подписи выше)
+func VerifyMessage(msg *Message, keys []ed25519.PublicKey) error {
+	if msg.Signature == "" {
+		return ErrNoSignature
+	}
+	if len(keys) == 0 {
+		return ErrUnknownSigner
+	}
+	sign, err := base64.StdEncoding.DecodeString(msg.Signature)
+	if err != nil || len(sign) != ed25519.SignatureSize {
+		return ErrBadSignature
+	}
+	data := MessageSignedData(msg)
+	for _, k := range keys {
+		if ed25519.Verify(k, data, sign) {
+			return nil
+		}
+	}
+	return ErrBadSignature
+}
+
+//VerifyMessageFrom - проверяет подпись сообщения ключами его отправителя (From) из каталога keys
+func VerifyMessageFrom(ctx context.Context, keys IBgKeyDirectory, msg *Message) error {
+	if msg.Signature == "" {
+		return ErrNoSignature
+	}
+	if msg.From == "" {
+		return ErrUnknownSigner
+	}
+	pub, err := keys.GetPublicKeys(ctx, msg.From)
+	if err != nil {
+		return err
+	}
+	return VerifyMessage(msg, pub)
+}
+
+//EncodePublicKey - открытый ключ в виде для ClientDescriptor.PublicKeys
+func EncodePublicKey(key ed25519.PublicKey) string {
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+//ParsePublicKey - разбирает открытый ключ из ClientDescriptor.PublicKeys
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	key, err := base64.StdEncoding.DecodeString(s)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, ErrBadPublicKey
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+//SigningKeys - открытые ключи клиента для проверки подписи его сообщений
+func (c *ClientDescriptor) SigningKeys() ([]ed25519.PublicKey, error) {
+	res := make([]ed25519.PublicKey, 0, len(c.PublicKeys))
+	for _, s := range c.PublicKeys {
+		key, err := ParsePublicKey(s)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, key)
+	}
+	return res, nil
+}
+
+//ClientKeyDirectory - каталог открытых ключей поверх хранилища клиентов (ключи из ClientDescriptor.PublicKeys)
+type ClientKeyDirectory struct {
+	Clients IBgClientSaver
+}
+
+//GetPublicKeys - открытые ключи клиента name. Для неизвестного (хранилище вернуло ErrNotFound) и отозванного клиента - ErrUnknownSigner
+func (d ClientKeyDirectory) GetPublicKeys(ctx context.Context, name string) ([]ed25519.PublicKey, error) {
+	c, err := d.Clients.GetClient(ctx, name)
+	if errors.Is(err, ErrNotFound) {
+		return nil, ErrUnknownSigner
+	}
+	if err != nil {
+		return nil, err
+	}
+	if c.Revoked {
+		return nil, ErrUnknownSigner
+	}
+	return c.SigningKeys()
+}
@@ -0,0 +1,100 @@
+package dto
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// testClients - хранилище клиентов для тестов, для неизвестного имени возвращает обертку над ErrNotFound
+type testClients map[string]ClientDescriptor
+
+func (c testClients) GetClient(ctx context.Context, name string) (ClientDescriptor, error) {
+	cl, ok := c[name]
+	if !ok {
+		return cl, fmt.Errorf("Client %s: %w", name, ErrNotFound)
+	}
+	return cl, nil
+}
+
+func (c testClients) SaveClient(ctx context.Context, cl *ClientDescriptor) error {
+	c[cl.Name] = *cl
+	return nil
+}
+
+func (c testClients) GenerateClient(ctx context.Context, name string) (ClientDescriptor, error) {
+	return ClientDescriptor{}, errors.New("Not supported")
+}
+
+// failingClients - хранилище, которое недоступно
+type failingClients struct{ testClients }
+
+var errStorage = errors.New("Storage is down")
+
+func (failingClients) GetClient(ctx context.Context, name string) (ClientDescriptor, error) {
+	return ClientDescriptor{}, errStorage
+}
+
+func newKey(t *testing.T) (ed25519.PublicKey, ed25519.PrivateKey) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pub, priv
+}
+
+func TestVerifyMessageFrom(t *testing.T) {
+	pub, priv := newKey(t)
+	oldPub, oldPriv := newKey(t)
+	_, otherPriv := newKey(t)
+	keys := ClientKeyDirectory{Clients: testClients{
+		"modem":   {Name: "modem", PublicKeys: []string{EncodePublicKey(pub), EncodePublicKey(oldPub)}},
+		"revoked": {Name: "revoked", Revoked: true, PublicKeys: []string{EncodePublicKey(pub)}},
+		"nokeys":  {Name: "nokeys"},
+		"badkey":  {Name: "badkey", PublicKeys: []string{"c2hvcnQ="}},
+	}}
+	signed := func(from string, key ed25519.PrivateKey, change func(m *Message)) *Message {
+		m := &Message{
+			MessageMetaInf: MessageMetaInf{Command: DataCOMMAND, ID: 1, From: from, To: "server"},
+			MessageContent: MessageContent{ContentType: "text", Data: []byte("hello")},
+		}
+		if key != nil {
+			SignMessage(m, key)
+		}
+		if change != nil {
+			change(m)
+		}
+		return m
+	}
+	cases := []struct {
+		name string
+		keys IBgKeyDirectory
+		msg  *Message
+		err  error
+	}{
+		{"valid", keys, signed("modem", priv, nil), nil},
+		{"second_key", keys, signed("modem", oldPriv, nil), nil},
+		{"id_and_proto_1_not_signed", keys, signed("modem", priv, func(m *Message) { m.ID, m.Proto = 2, 1 }), nil},
+		{"other_key", keys, signed("modem", otherPriv, nil), ErrBadSignature},
+		{"changed_data", keys, signed("modem", priv, func(m *Message) { m.Data = []byte("HELLO") }), ErrBadSignature},
+		{"changed_to", keys, signed("modem", priv, func(m *Message) { m.To = "other" }), ErrBadSignature},
+		{"changed_type", keys, signed("modem", priv, func(m *Message) { m.ContentType = "binary" }), ErrBadSignature},
+		{"field_boundary", keys, signed("modem", priv, func(m *Message) { m.To, m.Channel = "serv", "er" }), ErrBadSignature},
+		{"bad_encoding", keys, signed("modem", priv, func(m *Message) { m.Signature = "!" }), ErrBadSignature},
+		{"not_signed", keys, signed("modem", nil, nil), ErrNoSignature},
+		{"no_from", keys, signed("", priv, nil), ErrUnknownSigner},
+		{"unknown_client", keys, signed("ghost", priv, nil), ErrUnknownSigner},
+		{"revoked_client", keys, signed("revoked", priv, nil), ErrUnknownSigner},
+		{"no_keys", keys, signed("nokeys", priv, nil), ErrUnknownSigner},
+		{"bad_public_key", keys, signed("badkey", priv, nil), ErrBadPublicKey},
+		{"storage_error", ClientKeyDirectory{Clients: failingClients{}}, signed("modem", priv, nil), errStorage},
+	}
+	for _, tc := range cases {
+		if err := VerifyMessageFrom(context.Background(), tc.keys, tc.msg); err != tc.err {
+			t.Errorf("%s: %v, want %v", tc.name, err, tc.err)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package dto
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// Версии подписи второго шага авторизации
+const (
+	SignatureV1 = 1 // sha256(имя+вызов+соль+токен), CalculateSignature
+	SignatureV2 = 2 // HMAC-SHA256 с ключом токен от полей с длиной и времени клиента, CalculateSignatureV2
+	SignatureV3 = 3 // Доказательство знания токена, который хранится на сервере в виде хеша, CalculateProofV3
+)
+
+// Префиксы подписываемых данных, чтобы подпись нельзя было использовать в другом протоколе или другой версии
+const (
+	signatureV2Domain = "c2c-auth-v2"
+	signatureV3Domain = "c2c-auth-v3"
+)
+
+// versionsDelimiter - разделитель версий подписи в вызове
+const versionsDelimiter = ","
+
+// authMAC - HMAC-SHA256 с ключом key от префикса, имени, вызова, соли (каждое поле с длиной) и времени клиента
+func authMAC(key []byte, domain, name, challenge, salt string, timestamp int64) [32]byte {
+	mac := hmac.New(sha256.New, key)
+	var buf [8]byte
+	for _, field := range [...]string{domain, name, challenge, salt} {
+		binary.BigEndian.PutUint32(buf[:4], uint32(len(field)))
+		mac.Write(buf[:4])
+		mac.Write([]byte(field))
+	}
+	binary.BigEndian.PutUint64(buf[:], uint64(timestamp))
+	mac.Write(buf[:])
+	var sign [32]byte
+	copy(sign[:], mac.Sum(nil))
+	return sign
+}
+
+//CalculateSignatureV2 - HMAC-SHA256 с ключом token от имени, вызова, соли (каждое поле с длиной) и времени клиента в unix секундах
+func CalculateSignatureV2(name, challenge, salt, token string, timestamp int64) [32]byte {
+	return authMAC([]byte(token), signatureV2Domain, name, challenge, salt, timestamp)
+}
+
+//CalculateProofV3 - доказательство знания токена для SignatureV3 (схема как в SCRAM):
+//clientKey XOR HMAC(storedKey, данные как в SignatureV2), где clientKey и storedKey считаются
+//по параметрам хеширования из вызова (смотри TokenKDF). Сервер проверяет его, зная только storedKey
+func CalculateProofV3(name, challenge, salt, token string, timestamp int64) ([32]byte, error) {
+	var proof [32]byte
+	kdf, err := ChallengeKDF(challenge)
+	if err != nil {
+		return proof, err
+	}
+	clientKey := kdf.clientKey(token)
+	storedKey := sha256.Sum256(clientKey)
+	sign := authMAC(storedKey[:], signatureV3Domain, name, challenge, salt, timestamp)
+	for i := range proof {
+		proof[i] = clientKey[i] ^ sign[i]
+	}
+	return proof, nil
+}
+
+// verifyProofV3 - проверяет доказательство по хранимому ключу
+func verifyProofV3(name, challenge, salt string, timestamp int64, proof, storedKey [32]byte) bool {
+	sign := authMAC(storedKey[:], signatureV3Domain, name, challenge, salt, timestamp)
+	var clientKey [32]byte
+	for i := range clientKey {
+		clientKey[i] = proof[i] ^ sign[i]
+	}
+	key := sha256.Sum256(clientKey[:])
+	return subtle.ConstantTimeCompare(key[:], storedKey[:]) == 1
+}
+
+//FormChallenge - ответ сервера на первый шаг авторизации: вызов и список поддерживаемых версий подписи "вызов;1,2".
+//Для SignatureV3 в конец добавляются параметры хеширования токена (смотри ChallengeWithKDF).
+//Старые клиенты используют весь ответ как вызов, поэтому подпись всегда считается от всего ответа
+func FormChallenge(nonce string, versions ...int) string {
+	if len(versions) == 0 {
+		return nonce
+	}
+	v := make([]string, len(versions))
+	for i := range versions {
+		v[i] = strconv.Itoa(versions[i])
+	}
+	return nonce + DataDelimiter + strings.Join(v, versionsDelimiter)
+}
+
+//ChallengeWithKDF - добавляет к вызову с версиями параметры хеширования токена клиента "вызов;1,2,3;pbkdf2-sha256$итерации$соль"
+func ChallengeWithKDF(challenge string, kdf TokenKDF) string {
+	return challenge + DataDelimiter + kdf.String()
+}
+
+//ChallengeKDF - параметры хеширования токена из вызова (нужны для SignatureV3)
+func ChallengeKDF(challenge string) (TokenKDF, error) {
+	fields := strings.Split(challenge, DataDelimiter)
+	if len(fields) < 3 {
+		return TokenKDF{}, errors.New("Challenge has no token hash parameters")
+	}
+	return ParseTokenKDF(fields[2])
+}
+
+//ParseChallenge - версии подписи, которые поддерживает сервер. Старые серверы не присылают версии, для них только SignatureV1
+func ParseChallenge(challenge string) []int {
+	fields := strings.Split(challenge, DataDelimiter)
+	if len(fields) < 2 {
+		return []int{SignatureV1}
+	}
+	var versions []int
+	for _, s := range strings.Split(fields[1], versionsDelimiter) {
+		if v, err := strconv.Atoi(s); err == nil {
+			versions = append(versions, v)
+		}
+	}
+	if len(versions) == 0 {
+		return []int{SignatureV1}
+	}
+	return versions
+}
+
+// AuthData - данные второго шага авторизации.
+// SignatureV1 передается как "соль;подпись", SignatureV2 как "v2;соль;время;подпись", SignatureV3 как "v3;соль;время;доказательство"
+type AuthData struct {
+	Version   int
+	Salt      string
+	Timestamp int64 // Время клиента в unix секундах, для SignatureV2 и SignatureV3
+	Sign      [32]byte
+}
+
+//SignAuthData - подписывает второй шаг авторизации версией version
+func SignAuthData(version int, name, challenge, salt, token string, timestamp int64) (AuthData, error) {
+	a := AuthData{Version: version, Salt: salt}
+	switch version {
+	case SignatureV1:
+		a.Sign = CalculateSignature(name, challenge+salt, token)
+	case SignatureV2:
+		a.Timestamp = timestamp
+		a.Sign = CalculateSignatureV2(name, challenge, salt, token, timestamp)
+	case SignatureV3:
+		a.Timestamp = timestamp
+		sign, err := CalculateProofV3(name, challenge, salt, token, timestamp)
+		if err != nil {
+			return a, err
+		}
+		a.Sign = sign
+	default:
+		return a, errors.New("Unsupported signature version")
+	}
+	return a, nil
+}
+
+//Encode - данные для отправки в AuthCOMMAND
+func (a *AuthData) Encode() []byte {
+	if a.Version == SignatureV1 {
+		return FormAuthData(a.Salt, a.Sign)
+	}
+	return []byte("v" + strconv.Itoa(a.Version) + DataDelimiter + a.Salt + DataDelimiter +
+		strconv.FormatInt(a.Timestamp, 10) + DataDelimiter + hex.EncodeToString(a.Sign[:]))
+}
+
+//DecodeAuthData - разбирает второй шаг авторизации любой версии
+func DecodeAuthData(data []byte) (AuthData, error) {
+	var a AuthData
+	fields := strings.Split(string(data), DataDelimiter)
+	if len(fields) == 2 {
+		salt, sign, err := ParseAuthData(data)
+		return AuthData{Version: SignatureV1, Salt: salt, Sign: sign}, err
+	}
+	if len(fields) != 4 || !strings.HasPrefix(fields[0], "v") {
+		return a, errors.New("Incorrect auth data, it must be salt;signature or vN;salt;timestamp;signature")
+	}
+	var err error
+	if a.Version, err = strconv.Atoi(fields[0][1:]); err != nil || (a.Version != SignatureV2 && a.Version != SignatureV3) {
+		return a, errors.New("Unsupported signature version")
+	}
+	if a.Timestamp, err = strconv.ParseInt(fields[2], 10, 64); err != nil {
+		return a, errors.New("Incorrect timestamp")
+	}
+	s, err := hex.DecodeString(fields[3])
+	if err != nil || len(s) != len(a.Sign) {
+		return a, errors.New("Incorrect signature")
+	}
+	a.Salt = fields[1]
+	copy(a.Sign[:], s)
+	return a, nil
+}
+
+//Verify - проверяет подпись клиента name на вызов challenge (весь ответ сервера на первый шаг).
+//stored - токен из хранилища: открытый токен или хеш (IsTokenHash). Хеш подходит только для SignatureV3
+//и только если он сделан с параметрами из вызова
+func (a *AuthData) Verify(name, challenge, stored string) bool {
+	if a.Version == SignatureV3 {
+		kdf, err := ChallengeKDF(challenge)
+		if err != nil {
+			return false
+		}
+		if !IsTokenHash(stored) {
+			return stored != "" && verifyProofV3(name, challenge, a.Salt, a.Timestamp, a.Sign, kdf.StoredKey(stored))
+		}
+		h, err := ParseTokenHash(stored)
+		if err != nil || h.Iterations != kdf.Iterations || !hmac.Equal(h.Salt, kdf.Salt) {
+			return false
+		}
+		return verifyProofV3(name, challenge, a.Salt, a.Timestamp, a.Sign, h.StoredKey)
+	}
+	if stored == "" || IsTokenHash(stored) {
+		return false
+	}
+	expected, err := SignAuthData(a.Version, name, challenge, a.Salt, stored, a.Timestamp)
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(expected.Sign[:], a.Sign[:]) == 1
+}
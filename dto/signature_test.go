@@ -0,0 +1,115 @@
+package dto
+
+import (
+	"encoding/hex"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseChallenge(t *testing.T) {
+	kdf := TokenKDF{Iterations: 10, Salt: []byte("salt")}
+	cases := []struct {
+		challenge string
+		versions  []int
+		kdf       bool
+	}{
+		{"nonce", []int{SignatureV1}, false},
+		{FormChallenge("nonce"), []int{SignatureV1}, false},
+		{FormChallenge("nonce", SignatureV1, SignatureV2), []int{SignatureV1, SignatureV2}, false},
+		{ChallengeWithKDF(FormChallenge("nonce", SignatureV2, SignatureV3), kdf), []int{SignatureV2, SignatureV3}, true},
+		{"nonce;x,y", []int{SignatureV1}, false},
+		{"nonce;3;md5$1$c2FsdA", []int{SignatureV3}, false},
+		{"nonce;3;pbkdf2-sha256$2000000000$c2FsdA", []int{SignatureV3}, false},
+	}
+	for _, tc := range cases {
+		if got := ParseChallenge(tc.challenge); !reflect.DeepEqual(got, tc.versions) {
+			t.Errorf("%q: versions %v, want %v", tc.challenge, got, tc.versions)
+		}
+		got, err := ChallengeKDF(tc.challenge)
+		if (err == nil) != tc.kdf || (tc.kdf && !reflect.DeepEqual(got, kdf)) {
+			t.Errorf("%q: KDF %+v %v", tc.challenge, got, err)
+		}
+	}
+}
+
+// TestAuthDataVerify - подпись всех версий проверяется по открытому токену и по хешу и не подходит к измененным полям
+func TestAuthDataVerify(t *testing.T) {
+	const token = "secret"
+	kdf := TokenKDF{Iterations: 100, Salt: []byte("0123456789abcdef")}
+	otherKDF := TokenKDF{Iterations: 100, Salt: []byte("fedcba9876543210")}
+	challenge := ChallengeWithKDF(FormChallenge("nonce", SignatureV1, SignatureV2, SignatureV3), kdf)
+	noKDF := FormChallenge("nonce", SignatureV1, SignatureV2)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC).Unix()
+	type change func(a *AuthData, name, challenge, stored *string)
+	cases := []struct {
+		name      string
+		version   int
+		challenge string
+		stored    string
+		change    change
+		ok        bool
+	}{
+		{"v1_plain", SignatureV1, noKDF, token, nil, true},
+		{"v2_plain", SignatureV2, noKDF, token, nil, true},
+		{"v3_plain", SignatureV3, challenge, token, nil, true},
+		{"v3_hash", SignatureV3, challenge, kdf.Hash(token), nil, true},
+		{"v1_hash", SignatureV1, challenge, kdf.Hash(token), nil, false},
+		{"v2_hash", SignatureV2, challenge, kdf.Hash(token), nil, false},
+		{"v3_hash_other_kdf", SignatureV3, challenge, otherKDF.Hash(token), nil, false},
+		{"v3_hash_other_token", SignatureV3, challenge, kdf.Hash("other"), nil, false},
+		{"v1_empty_stored", SignatureV1, noKDF, "", nil, false},
+		{"v3_empty_stored", SignatureV3, challenge, "", nil, false},
+		{"v1_other_token", SignatureV1, noKDF, "other", nil, false},
+		{"v2_other_token", SignatureV2, noKDF, "other", nil, false},
+		{"v1_other_name", SignatureV1, noKDF, token, func(a *AuthData, n, c, s *string) { *n = "modem2" }, false},
+		{"v2_other_name", SignatureV2, noKDF, token, func(a *AuthData, n, c, s *string) { *n = "modem2" }, false},
+		{"v3_other_name", SignatureV3, challenge, kdf.Hash(token), func(a *AuthData, n, c, s *string) { *n = "modem2" }, false},
+		{"v1_other_challenge", SignatureV1, noKDF, token, func(a *AuthData, n, c, s *string) { *c = "other" + *c }, false},
+		{"v2_other_challenge", SignatureV2, noKDF, token, func(a *AuthData, n, c, s *string) { *c = "other" + *c }, false},
+		{"v3_other_challenge", SignatureV3, challenge, kdf.Hash(token), func(a *AuthData, n, c, s *string) { *c = "other" + *c }, false},
+		{"v1_other_salt", SignatureV1, noKDF, token, func(a *AuthData, n, c, s *string) { a.Salt = "salt2" }, false},
+		{"v2_other_salt", SignatureV2, noKDF, token, func(a *AuthData, n, c, s *string) { a.Salt = "salt2" }, false},
+		{"v3_other_salt", SignatureV3, challenge, kdf.Hash(token), func(a *AuthData, n, c, s *string) { a.Salt = "salt2" }, false},
+		{"v2_other_time", SignatureV2, noKDF, token, func(a *AuthData, n, c, s *string) { a.Timestamp++ }, false},
+		{"v3_other_time", SignatureV3, challenge, kdf.Hash(token), func(a *AuthData, n, c, s *string) { a.Timestamp++ }, false},
+		{"v2_as_v1", SignatureV2, noKDF, token, func(a *AuthData, n, c, s *string) { a.Version = SignatureV1 }, false},
+		{"v3_no_kdf_in_challenge", SignatureV3, challenge, token, func(a *AuthData, n, c, s *string) { *c = noKDF }, false},
+	}
+	for _, tc := range cases {
+		data, err := SignAuthData(tc.version, "modem", tc.challenge, "salt", token, now)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		decoded, err := DecodeAuthData(data.Encode())
+		if err != nil || decoded != data {
+			t.Fatalf("%s: decoded %+v %v, want %+v", tc.name, decoded, err, data)
+		}
+		name, challenge, stored := "modem", tc.challenge, tc.stored
+		if tc.change != nil {
+			tc.change(&decoded, &name, &challenge, &stored)
+		}
+		if ok := decoded.Verify(name, challenge, stored); ok != tc.ok {
+			t.Errorf("%s: Verify %v, want %v", tc.name, ok, tc.ok)
+		}
+	}
+}
+
+func TestDecodeAuthDataErrors(t *testing.T) {
+	sign := hex.EncodeToString(make([]byte, 32))
+	for _, data := range []string{
+		"",
+		"salt",
+		"salt;" + sign[:10],
+		"v2;salt;1;" + sign + ";x",
+		"x2;salt;1;" + sign,
+		"v1;salt;1;" + sign,
+		"v4;salt;1;" + sign,
+		"v2;salt;time;" + sign,
+		"v3;salt;1;zz",
+	} {
+		if _, err := DecodeAuthData([]byte(data)); err == nil {
+			t.Errorf("%q is decoded", data)
+		}
+	}
+}
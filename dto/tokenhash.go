@@ -0,0 +1,181 @@
+package dto
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+/*
+Хранение токенов в виде медленного хеша с солью (PBKDF2-HMAC-SHA256), чтобы утечка хранилища не раскрывала токены.
+Формат хранимого значения: "$pbkdf2-sha256$итерации$соль$ключ" (соль и ключ в base64 без выравнивания), где
+ключ = sha256(HMAC(PBKDF2(токен, соль, итерации), "Client Key")). По ключу нельзя ни восстановить токен,
+ни авторизоваться: для SignatureV3 клиент доказывает знание HMAC(PBKDF2(...), "Client Key") (смотри CalculateProofV3)
+*/
+
+// TokenHashScheme - имя схемы хеширования токена, первое поле хранимого значения и параметров в вызове
+const TokenHashScheme = "pbkdf2-sha256"
+
+// DefaultTokenIterations - количество итераций PBKDF2 по умолчанию
+const DefaultTokenIterations = 20000
+
+// MaxTokenIterations - наибольшее допустимое количество итераций. Больше клиент не примет из вызова сервера,
+// чтобы чужой сервер не мог занять процессор клиента
+const MaxTokenIterations = 10 * DefaultTokenIterations
+
+// tokenHashDelimiter - разделитель полей хранимого хеша и параметров в вызове
+const tokenHashDelimiter = "$"
+
+// ErrIncorrectTokenHash - значение не является хешем токена или параметры хеширования неверные
+var ErrIncorrectTokenHash = errors.New("Incorrect token hash")
+
+// PBKDF2 - PBKDF2 (RFC 8018) с HMAC-SHA256
+func PBKDF2(password, salt []byte, iter, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	hashLen := prf.Size()
+	blocks := (keyLen + hashLen - 1) / hashLen
+	res := make([]byte, 0, blocks*hashLen)
+	var idx [4]byte
+	u := make([]byte, hashLen)
+	for block := 1; block <= blocks; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(idx[:], uint32(block))
+		prf.Write(idx[:])
+		u = prf.Sum(u[:0])
+		t := make([]byte, hashLen)
+		copy(t, u)
+		for i := 1; i < iter; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		res = append(res, t...)
+	}
+	return res[:keyLen]
+}
+
+// TokenKDF - параметры хеширования токена
+type TokenKDF struct {
+	Iterations int
+	Salt       []byte
+}
+
+// NewTokenKDF - параметры хеширования со случайной солью, iter <= 0 - DefaultTokenIterations.
+// Больше MaxTokenIterations - ErrIncorrectTokenHash
+func NewTokenKDF(iter int) (TokenKDF, error) {
+	if iter <= 0 {
+		iter = DefaultTokenIterations
+	}
+	if iter > MaxTokenIterations {
+		return TokenKDF{}, ErrIncorrectTokenHash
+	}
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return TokenKDF{}, err
+	}
+	return TokenKDF{Iterations: iter, Salt: salt}, nil
+}
+
+// String - параметры в виде "pbkdf2-sha256$итерации$соль" (передаются клиенту в вызове)
+func (k TokenKDF) String() string {
+	return TokenHashScheme + tokenHashDelimiter + strconv.Itoa(k.Iterations) + tokenHashDelimiter +
+		base64.RawStdEncoding.EncodeToString(k.Salt)
+}
+
+// ParseTokenKDF - разбирает параметры, сформированные TokenKDF.String (не больше MaxTokenIterations итераций)
+func ParseTokenKDF(s string) (TokenKDF, error) {
+	fields := strings.Split(s, tokenHashDelimiter)
+	if len(fields) != 3 || fields[0] != TokenHashScheme {
+		return TokenKDF{}, ErrIncorrectTokenHash
+	}
+	iter, err := strconv.Atoi(fields[1])
+	if err != nil || iter <= 0 || iter > MaxTokenIterations {
+		return TokenKDF{}, ErrIncorrectTokenHash
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(fields[2])
+	if err != nil || len(salt) == 0 {
+		return TokenKDF{}, ErrIncorrectTokenHash
+	}
+	return TokenKDF{Iterations: iter, Salt: salt}, nil
+}
+
+// clientKey - HMAC(PBKDF2(token), "Client Key")
+func (k TokenKDF) clientKey(token string) []byte {
+	mac := hmac.New(sha256.New, PBKDF2([]byte(token), k.Salt, k.Iterations, sha256.Size))
+	mac.Write([]byte("Client Key"))
+	return mac.Sum(nil)
+}
+
+// StoredKey - ключ, который хранится вместо токена
+func (k TokenKDF) StoredKey(token string) [32]byte {
+	return sha256.Sum256(k.clientKey(token))
+}
+
+// Hash - хранимое значение токена "$pbkdf2-sha256$итерации$соль$ключ"
+func (k TokenKDF) Hash(token string) string {
+	key := k.StoredKey(token)
+	return tokenHashDelimiter + k.String() + tokenHashDelimiter + base64.RawStdEncoding.EncodeToString(key[:])
+}
+
+// TokenHash - разобранное хранимое значение токена
+type TokenHash struct {
+	TokenKDF
+	StoredKey [32]byte
+}
+
+// IsTokenHash - значение токена в хранилище является хешем, а не открытым токеном
+func IsTokenHash(stored string) bool {
+	return strings.HasPrefix(stored, tokenHashDelimiter+TokenHashScheme+tokenHashDelimiter)
+}
+
+// HashToken - хеширует токен со случайной солью, iter <= 0 - DefaultTokenIterations
+func HashToken(token string, iter int) (string, error) {
+	kdf, err := NewTokenKDF(iter)
+	if err != nil {
+		return "", err
+	}
+	return kdf.Hash(token), nil
+}
+
+// ParseTokenHash - разбирает хранимое значение, сформированное HashToken или TokenKDF.Hash
+func ParseTokenHash(stored string) (TokenHash, error) {
+	var h TokenHash
+	if !IsTokenHash(stored) {
+		return h, ErrIncorrectTokenHash
+	}
+	i := strings.LastIndex(stored, tokenHashDelimiter)
+	kdf, err := ParseTokenKDF(stored[1:i])
+	if err != nil {
+		return h, err
+	}
+	key, err := base64.RawStdEncoding.DecodeString(stored[i+1:])
+	if err != nil || len(key) != len(h.StoredKey) {
+		return h, ErrIncorrectTokenHash
+	}
+	h.TokenKDF = kdf
+	copy(h.StoredKey[:], key)
+	return h, nil
+}
+
+// VerifyToken - открытый токен token соответствует хранимому значению stored (хешу или открытому токену)
+func VerifyToken(stored, token string) bool {
+	if !IsTokenHash(stored) {
+		return stored != "" && subtle.ConstantTimeCompare([]byte(stored), []byte(token)) == 1
+	}
+	h, err := ParseTokenHash(stored)
+	if err != nil {
+		return false
+	}
+	key := h.TokenKDF.StoredKey(token)
+	return subtle.ConstantTimeCompare(key[:], h.StoredKey[:]) == 1
+}
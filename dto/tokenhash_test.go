@@ -0,0 +1,35 @@
+package dto
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+// TestPBKDF2 - тестовый вектор PBKDF2-HMAC-SHA256 из RFC 7914
+func TestPBKDF2(t *testing.T) {
+	want := "55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783"
+	if got := hex.EncodeToString(PBKDF2([]byte("passwd"), []byte("salt"), 1, 64)); got != want {
+		t.Fatalf("PBKDF2 %s, want %s", got, want)
+	}
+}
+
+func TestTokenHash(t *testing.T) {
+	stored, err := HashToken("secret", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsTokenHash(stored) || !VerifyToken(stored, "secret") || VerifyToken(stored, "other") {
+		t.Fatalf("Hash %q does not verify", stored)
+	}
+	if !VerifyToken("secret", "secret") || VerifyToken("", "") || VerifyToken("secret", "other") {
+		t.Fatal("Plain token check failed")
+	}
+	if _, err := HashToken("secret", MaxTokenIterations+1); err != ErrIncorrectTokenHash {
+		t.Fatalf("Hash with too many iterations: %v", err)
+	}
+	for _, bad := range []string{"secret", "$pbkdf2-sha256$0$c2FsdA$a2V5", "$pbkdf2-sha256$2000000000$c2FsdA$a2V5", "$pbkdf2-sha256$10$$a2V5", "$pbkdf2-sha256$10$c2FsdA$a2V5"} {
+		if _, err := ParseTokenHash(bad); err == nil {
+			t.Errorf("%q is parsed", bad)
+		}
+	}
+}
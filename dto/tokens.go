@@ -0,0 +1,19 @@
+package dto
+
+import "time"
+
+//ActiveTokens - токены, которыми клиент может авторизоваться в момент now:
+//текущий и предыдущий до конца периода перекрытия после ротации. Для отозванного клиента пусто
+func (cl *ClientDescriptor) ActiveTokens(now time.Time) []string {
+	if cl.Revoked {
+		return nil
+	}
+	var tokens []string
+	if cl.Token != "" {
+		tokens = append(tokens, cl.Token)
+	}
+	if cl.OldToken != "" && now.Before(cl.OldExpire) {
+		tokens = append(tokens, cl.OldToken)
+	}
+	return tokens
+}
@@ -0,0 +1,27 @@
+package dto
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestActiveTokens(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name string
+		cl   ClientDescriptor
+		want []string
+	}{
+		{"current", ClientDescriptor{Token: "new"}, []string{"new"}},
+		{"overlap", ClientDescriptor{Token: "new", OldToken: "old", OldExpire: now.Add(time.Second)}, []string{"new", "old"}},
+		{"overlap_ended", ClientDescriptor{Token: "new", OldToken: "old", OldExpire: now}, []string{"new"}},
+		{"revoked", ClientDescriptor{Token: "new", OldToken: "old", OldExpire: now.Add(time.Hour), Revoked: true}, nil},
+		{"empty", ClientDescriptor{}, nil},
+	}
+	for _, tc := range cases {
+		if got := tc.cl.ActiveTokens(now); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package memstore
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/blabu/messagesLib/dto"
+)
+
+// DefaultAuditSize - сколько последних записей хранит ACL.Denials
+const DefaultAuditSize = 1000
+
+// ACL - правила доступа, группы и журнал запретов в памяти, реализует dto.IBgACL и dto.IBgACLAudit
+type ACL struct {
+	AuditSize int // 0 - DefaultAuditSize
+
+	mu      sync.RWMutex
+	rules   map[string]dto.ACLRule
+	members map[string]map[string]bool // Имя -> группы
+	lastID  int
+	audit   []dto.ACLDenial // Кольцевой буфер, next - позиция следующей записи
+	next    int
+}
+
+// NewACL - пустые правила и группы
+func NewACL() *ACL {
+	return &ACL{rules: make(map[string]dto.ACLRule), members: make(map[string]map[string]bool)}
+}
+
+// GetRules - все правила, отсортированные по ID
+func (a *ACL) GetRules(ctx context.Context) ([]dto.ACLRule, error) {
+	a.mu.RLock()
+	res := make([]dto.ACLRule, 0, len(a.rules))
+	for _, r := range a.rules {
+		res = append(res, r)
+	}
+	a.mu.RUnlock()
+	sort.Slice(res, func(i, j int) bool { return res[i].ID < res[j].ID })
+	return res, nil
+}
+
+// SaveRule - добавляет или заменяет правило, пустой ID заполняется номером
+func (a *ACL) SaveRule(ctx context.Context, rule *dto.ACLRule) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if rule.ID == "" {
+		for {
+			a.lastID++
+			id := "rule" + strconv.Itoa(a.lastID)
+			if _, ok := a.rules[id]; !ok {
+				rule.ID = id
+				break
+			}
+		}
+	}
+	a.rules[rule.ID] = *rule
+	return nil
+}
+
+// DeleteRule - удаляет правило
+func (a *ACL) DeleteRule(ctx context.Context, id string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, ok := a.rules[id]; !ok {
+		return ErrNotFound
+	}
+	delete(a.rules, id)
+	return nil
+}
+
+// GetGroups - группы клиента или канала name, отсортированные по имени
+func (a *ACL) GetGroups(ctx context.Context, name string) ([]string, error) {
+	a.mu.RLock()
+	res := make([]string, 0, len(a.members[name]))
+	for g := range a.members[name] {
+		res = append(res, g)
+	}
+	a.mu.RUnlock()
+	sort.Strings(res)
+	return res, nil
+}
+
+// AddToGroup - добавляет name в группу group
+func (a *ACL) AddToGroup(ctx context.Context, group, name string) error {
+	if group == "" || name == "" {
+		return ErrEmptyKey
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.members[name] == nil {
+		a.members[name] = make(map[string]bool)
+	}
+	a.members[name][group] = true
+	return nil
+}
+
+// RemoveFromGroup - удаляет name из группы group
+func (a *ACL) RemoveFromGroup(ctx context.Context, group, name string) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if !a.members[name][group] {
+		return ErrNotFound
+	}
+	delete(a.members[name], group)
+	if len(a.members[name]) == 0 {
+		delete(a.members, name)
+	}
+	return nil
+}
+
+// AddDenial - добавляет запись в журнал, самые старые записи вытесняются
+func (a *ACL) AddDenial(ctx context.Context, d *dto.ACLDenial) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	size := a.AuditSize
+	if size <= 0 {
+		size = DefaultAuditSize
+	}
+	if len(a.audit) < size {
+		a.audit = append(a.audit, *d)
+		a.next = len(a.audit) % size
+		return nil
+	}
+	a.audit[a.next] = *d
+	a.next = (a.next + 1) % len(a.audit)
+	return nil
+}
+
+// Denials - последние записи журнала (не больше limit, 0 - все), от новых к старым
+func (a *ACL) Denials(limit int) []dto.ACLDenial {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	n := len(a.audit)
+	if limit <= 0 || limit > n {
+		limit = n
+	}
+	res := make([]dto.ACLDenial, 0, limit)
+	for i := 0; i < limit; i++ {
+		res = append(res, a.audit[(a.next-1-i+2*n)%n])
+	}
+	return res
+}
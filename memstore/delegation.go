@@ -0,0 +1,58 @@
+package memstore
+
+import (
+	"context"
+	"sort"
+	"sync"
+)
+
+// Delegations - делегирования в памяти, реализует dto.IBgDelegation
+type Delegations struct {
+	mu sync.RWMutex
+	m  map[string]map[string]bool // agent -> шаблоны имен
+}
+
+// NewDelegations - пустые делегирования
+func NewDelegations() *Delegations {
+	return &Delegations{m: make(map[string]map[string]bool)}
+}
+
+// GetDelegations - шаблоны имен, от которых может писать agent, отсортированные
+func (d *Delegations) GetDelegations(ctx context.Context, agent string) ([]string, error) {
+	d.mu.RLock()
+	res := make([]string, 0, len(d.m[agent]))
+	for p := range d.m[agent] {
+		res = append(res, p)
+	}
+	d.mu.RUnlock()
+	sort.Strings(res)
+	return res, nil
+}
+
+// Delegate - разрешает agent писать от имени клиентов, подходящих шаблону pattern (смотри dto.MatchName)
+func (d *Delegations) Delegate(ctx context.Context, agent, pattern string) error {
+	if agent == "" || pattern == "" {
+		return ErrEmptyKey
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.m[agent] == nil {
+		d.m[agent] = make(map[string]bool)
+	}
+	d.m[agent][pattern] = true
+	return nil
+}
+
+// Revoke - отменяет делегирование
+func (d *Delegations) Revoke(ctx context.Context, agent, pattern string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if !d.m[agent][pattern] {
+		return ErrNotFound
+	}
+	delete(d.m[agent], pattern)
+	if len(d.m[agent]) == 0 {
+		delete(d.m, agent)
+	}
+	return nil
+}
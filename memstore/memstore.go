@@ -0,0 +1,212 @@
+// Package memstore - хранилища в памяти, которые реализуют интерфейсы dto.
+// Подходят для тестов, отладки и небольших установок без базы данных (данные теряются при перезапуске)
+package memstore
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/blabu/messagesLib/dto"
+)
+
+var (
+	// ErrNotFound - записи с таким ключом нет (то же значение, что dto.ErrNotFound)
+	ErrNotFound = dto.ErrNotFound
+	// ErrExists - запись с таким ключом уже есть
+	ErrExists = errors.New("Already exists")
+	// ErrEmptyKey - пустое имя или код
+	ErrEmptyKey = errors.New("Empty key")
+)
+
+// Store - клиенты и коды регистрации в памяти, реализует dto.IBgClientSaver, dto.IBgClientCreator,
+// dto.IBgClientGenerator, dto.IBgClientUpdater и dto.IBgBootstrapCode
+type Store struct {
+	// HashTokens - GenerateClient сохраняет хеш токена (dto.HashToken), открытый токен возвращается только вызывающему
+	HashTokens     bool
+	HashIterations int // 0 - dto.DefaultTokenIterations
+
+	mu      sync.RWMutex
+	clients map[string]dto.ClientDescriptor
+	codes   map[string]dto.BootstrapCode
+}
+
+// New - пустое хранилище
+func New() *Store {
+	return &Store{
+		clients: make(map[string]dto.ClientDescriptor),
+		codes:   make(map[string]dto.BootstrapCode),
+	}
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// GetClient - клиент по имени
+func (s *Store) GetClient(ctx context.Context, name string) (dto.ClientDescriptor, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	cl, ok := s.clients[name]
+	if !ok {
+		return dto.ClientDescriptor{}, ErrNotFound
+	}
+	return cl, nil
+}
+
+// SaveClient - добавляет или заменяет клиента
+func (s *Store) SaveClient(ctx context.Context, cl *dto.ClientDescriptor) error {
+	if cl.Name == "" {
+		return ErrEmptyKey
+	}
+	s.mu.Lock()
+	s.clients[cl.Name] = *cl
+	s.mu.Unlock()
+	return nil
+}
+
+// CreateClient - добавляет клиента, если клиента с таким именем нет или он отозван, иначе dto.ErrClientExists
+func (s *Store) CreateClient(ctx context.Context, cl *dto.ClientDescriptor) error {
+	if cl.Name == "" {
+		return ErrEmptyKey
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if old, ok := s.clients[cl.Name]; ok && !old.Revoked {
+		return dto.ErrClientExists
+	}
+	s.clients[cl.Name] = *cl
+	return nil
+}
+
+// UpdateClient - изменяет клиента name функцией update под блокировкой хранилища
+func (s *Store) UpdateClient(ctx context.Context, name string, update func(cl *dto.ClientDescriptor) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cl, ok := s.clients[name]
+	if !ok {
+		return ErrNotFound
+	}
+	if err := update(&cl); err != nil {
+		return err
+	}
+	cl.Name = name
+	s.clients[name] = cl
+	return nil
+}
+
+// TouchClient - сохраняет время последней активности клиента, для отозванного клиента dto.ErrRevoked
+func (s *Store) TouchClient(ctx context.Context, name string, last time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cl, ok := s.clients[name]
+	switch {
+	case !ok:
+		return ErrNotFound
+	case cl.Revoked:
+		return dto.ErrRevoked
+	}
+	cl.LastDate = last
+	s.clients[name] = cl
+	return nil
+}
+
+// GenerateClient - создает клиента со случайным токеном. Пустое name - случайное имя.
+// Возвращается открытый токен, даже если сохранен его хеш (HashTokens)
+func (s *Store) GenerateClient(ctx context.Context, name string) (dto.ClientDescriptor, error) {
+	var hash func(string) (string, error)
+	if s.HashTokens {
+		hash = func(token string) (string, error) { return dto.HashToken(token, s.HashIterations) }
+	}
+	return s.GenerateHashedClient(ctx, name, hash)
+}
+
+// GenerateHashedClient - создает клиента со случайным токеном и сохраняет hash(токен) (nil - открытый токен).
+// Пустое name - случайное имя. Возвращается открытый токен
+func (s *Store) GenerateHashedClient(ctx context.Context, name string, hash func(token string) (string, error)) (dto.ClientDescriptor, error) {
+	token, err := randomHex(16)
+	if err != nil {
+		return dto.ClientDescriptor{}, err
+	}
+	stored := token
+	if hash != nil {
+		if stored, err = hash(token); err != nil {
+			return dto.ClientDescriptor{}, err
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for name == "" {
+		suffix, err := randomHex(4)
+		if err != nil {
+			return dto.ClientDescriptor{}, err
+		}
+		if _, ok := s.clients["client"+suffix]; !ok {
+			name = "client" + suffix
+		}
+	}
+	if _, ok := s.clients[name]; ok {
+		return dto.ClientDescriptor{}, ErrExists
+	}
+	cl := dto.ClientDescriptor{Name: name, Token: stored, CreatedDate: time.Now()}
+	s.clients[name] = cl
+	cl.Token = token
+	return cl, nil
+}
+
+// DeleteClient - удаляет клиента
+func (s *Store) DeleteClient(ctx context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.clients[name]; !ok {
+		return ErrNotFound
+	}
+	delete(s.clients, name)
+	return nil
+}
+
+// Clients - все клиенты, отсортированные по имени
+func (s *Store) Clients() []dto.ClientDescriptor {
+	s.mu.RLock()
+	res := make([]dto.ClientDescriptor, 0, len(s.clients))
+	for _, cl := range s.clients {
+		res = append(res, cl)
+	}
+	s.mu.RUnlock()
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res
+}
+
+// SaveCode - добавляет код регистрации
+func (s *Store) SaveCode(ctx context.Context, code *dto.BootstrapCode) error {
+	if code.Code == "" {
+		return ErrEmptyKey
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.codes[code.Code]; ok {
+		return ErrExists
+	}
+	s.codes[code.Code] = *code
+	return nil
+}
+
+// TakeCode - возвращает и удаляет код регистрации
+func (s *Store) TakeCode(ctx context.Context, code string) (dto.BootstrapCode, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.codes[code]
+	if !ok {
+		return dto.BootstrapCode{}, ErrNotFound
+	}
+	delete(s.codes, code)
+	return c, nil
+}
@@ -0,0 +1,157 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+
+	"github.com/blabu/messagesLib/dto"
+)
+
+// Armor - способ кодирования данных и контрольной суммы в текстовом варианте протокола
+type Armor int
+
+const (
+	ArmorBase64 Armor = iota // Данные и контрольная сумма в base64 (StdEncoding)
+	ArmorHex                 // Данные и контрольная сумма в hex (заглавные буквы)
+)
+
+// Начало заголовка текстового варианта протокола. Остальной заголовок такой же как в версии 1,
+// размер в заголовке - это размер закодированных данных вместе с контрольной суммой
+const (
+	ArmorBase64Header = "$A"
+	ArmorHexHeader    = "$H"
+)
+
+// ArmoredParser - текстовый вариант протокола 1 для шлюзов, которые портят не ASCII байты (SMS, USSD).
+// Данные и контрольная сумма кодируются в base64 или hex, поэтому весь пакет состоит из печатных ASCII символов.
+// Контрольная сумма считается как в версии 1 по заголовку и исходным (не закодированным) данным
+type ArmoredParser struct {
+	C2cParser
+	armor Armor
+}
+
+// CreateArmoredParser - создает парсер текстового варианта протокола с ограничением максимального размера сообщения maxSize
+func CreateArmoredParser(maxSize uint64, armor Armor) IParser {
+	a := &ArmoredParser{armor: armor}
+	a.maxPackageSize = maxSize
+	a.begin = ArmorBase64Header
+	if armor == ArmorHex {
+		a.begin = ArmorHexHeader
+	}
+	return a
+}
+
+func (a *ArmoredParser) encodedLen(n int) int {
+	if a.armor == ArmorHex {
+		return hex.EncodedLen(n)
+	}
+	return base64.StdEncoding.EncodedLen(n)
+}
+
+func (a *ArmoredParser) encode(dst, src []byte) {
+	if a.armor == ArmorHex {
+		hex.Encode(dst, src)
+		copy(dst, bytes.ToUpper(dst))
+		return
+	}
+	base64.StdEncoding.Encode(dst, src)
+}
+
+func (a *ArmoredParser) decode(src []byte) ([]byte, error) {
+	if a.armor == ArmorHex {
+		dst := make([]byte, hex.DecodedLen(len(src)))
+		_, err := hex.Decode(dst, src)
+		return dst, err
+	}
+	dst := make([]byte, base64.StdEncoding.DecodedLen(len(src)))
+	n, err := base64.StdEncoding.Decode(dst, src)
+	return dst[:n], err
+}
+
+//FormMessage - формирует пакет, в котором данные и контрольная сумма закодированы
+func (a *ArmoredParser) FormMessage(msg *dto.Message) ([]byte, error) {
+	if msg == nil {
+		return []byte{}, errors.New("Message nil")
+	}
+	if msg.Proto == 0 {
+		msg.Proto = 1
+	}
+	size := a.encodedLen(len(msg.Data) + 4)
+	res := a.formHeader(make([]byte, 0, 128+size), msg, size)
+	raw := make([]byte, 0, len(res)+len(msg.Data))
+	raw = append(append(raw, res...), msg.Data...)
+	body := make([]byte, len(msg.Data)+4)
+	copy(body, msg.Data)
+	binary.LittleEndian.PutUint32(body[len(msg.Data):], checksumCustom(raw))
+	encoded := make([]byte, size)
+	a.encode(encoded, body)
+	return append(res, encoded...), nil
+}
+
+// decodeFrame - разбирает заголовок и декодирует данные первого пакета в data
+func (a *ArmoredParser) decodeFrame(data []byte) (start int, header []byte, content []byte, crc uint32, expected uint32, err error) {
+	if start, err = a.findFrame(data); err != nil {
+		return
+	}
+	frame := data[start:]
+	size := a.head.headerSize + a.head.contentSize
+	if len(frame) < size {
+		err = errors.New("Not full message")
+		return
+	}
+	header = frame[:a.head.headerSize]
+	body, err := a.decode(frame[a.head.headerSize:size])
+	if err != nil {
+		return
+	}
+	if len(body) < 4 {
+		err = errors.New("Icorrect message size, it must include checksum")
+		return
+	}
+	content = body[:len(body)-4]
+	crc = binary.LittleEndian.Uint32(body[len(body)-4:])
+	raw := make([]byte, 0, len(header)+len(content))
+	expected = checksumCustom(append(append(raw, header...), content...))
+	return
+}
+
+//ParseMessage - разбирает пакет и декодирует его данные
+func (a *ArmoredParser) ParseMessage(data []byte) (dto.Message, error) {
+	defer func() {
+		a.head = header{}
+	}()
+	_, _, content, crc, expected, err := a.decodeFrame(data)
+	if err != nil {
+		return dto.Message{}, err
+	}
+	if crc != expected {
+		return dto.Message{}, errors.New("Invalid checksum")
+	}
+	return a.message(content), nil
+}
+
+// Dissect - разбирает первый пакет в data для отладки (смотри C2cParser.Dissect)
+func (a *ArmoredParser) Dissect(data []byte) (FrameInfo, error) {
+	defer func() {
+		a.head = header{}
+	}()
+	start, head, content, crc, expected, err := a.decodeFrame(data)
+	if err != nil {
+		return FrameInfo{Offset: start}, err
+	}
+	info := FrameInfo{
+		Message:  a.message(content),
+		Offset:   start,
+		Size:     a.head.headerSize + a.head.contentSize,
+		Header:   head,
+		Checksum: crc,
+		Expected: expected,
+	}
+	if fields := bytes.Split(head, delim); len(fields) > 4 {
+		info.RawType = string(fields[4])
+	}
+	return info, nil
+}
@@ -0,0 +1,263 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"unicode/utf8"
+
+	"github.com/blabu/messagesLib/dto"
+)
+
+// Charset - кодировка текстовых данных (ContentType "text") на стороне клиента
+type Charset int32
+
+const (
+	CharsetUTF8        Charset = iota // Данные передаются как есть
+	CharsetWindows1251                // Windows-1251 (cp1251)
+	CharsetKOI8U                      // KOI8-U
+)
+
+// ReplacementChar - замена символов, которых нет в кодировке клиента, в исходящих сообщениях (смотри EncodeLossy)
+const ReplacementChar = '?'
+
+// ErrInvalidCharset - данные не могут быть перекодированы
+var ErrInvalidCharset = errors.New("Invalid byte sequence for charset")
+
+// CharsetError - ошибка перекодирования с позицией некорректного байта в исходных данных
+type CharsetError struct {
+	Charset Charset
+	Offset  int
+	Byte    byte
+}
+
+func (e *CharsetError) Error() string {
+	return fmt.Sprintf("%s: %s byte 0x%02X at offset %d", ErrInvalidCharset.Error(), e.Charset, e.Byte, e.Offset)
+}
+
+func (e *CharsetError) Unwrap() error {
+	return ErrInvalidCharset
+}
+
+func (cs Charset) String() string {
+	switch cs {
+	case CharsetUTF8:
+		return "utf-8"
+	case CharsetWindows1251:
+		return "windows-1251"
+	case CharsetKOI8U:
+		return "koi8-u"
+	}
+	return fmt.Sprintf("charset(%d)", int32(cs))
+}
+
+// ParseCharset - кодировка по имени из конфигурации (utf-8, windows-1251, cp1251, koi8-u)
+func ParseCharset(name string) (Charset, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "utf-8", "utf8":
+		return CharsetUTF8, nil
+	case "windows-1251", "cp1251", "win1251":
+		return CharsetWindows1251, nil
+	case "koi8-u", "koi8u":
+		return CharsetKOI8U, nil
+	}
+	return CharsetUTF8, fmt.Errorf("Unsupported charset %s", name)
+}
+
+func (cs Charset) table() *[128]rune {
+	switch cs {
+	case CharsetWindows1251:
+		return &windows1251Table
+	case CharsetKOI8U:
+		return &koi8uTable
+	}
+	return nil
+}
+
+// Decode - перекодирует data из кодировки cs в UTF-8
+func (cs Charset) Decode(data []byte) ([]byte, error) {
+	t := cs.table()
+	if t == nil {
+		if !utf8.Valid(data) {
+			return nil, invalidUTF8(cs, data)
+		}
+		return data, nil
+	}
+	res := make([]byte, 0, len(data)*2)
+	for i, b := range data {
+		if b < 0x80 {
+			res = append(res, b)
+			continue
+		}
+		r := t[b-0x80]
+		if r == utf8.RuneError {
+			return nil, &CharsetError{Charset: cs, Offset: i, Byte: b}
+		}
+		var buf [utf8.UTFMax]byte
+		n := utf8.EncodeRune(buf[:], r)
+		res = append(res, buf[:n]...)
+	}
+	return res, nil
+}
+
+// Encode - перекодирует UTF-8 data в кодировку cs. Символы, которых нет в кодировке, считаются ошибкой
+func (cs Charset) Encode(data []byte) ([]byte, error) {
+	if !utf8.Valid(data) {
+		return nil, invalidUTF8(CharsetUTF8, data)
+	}
+	t := cs.table()
+	if t == nil {
+		return data, nil
+	}
+	res := make([]byte, 0, len(data))
+	for i := 0; i < len(data); {
+		r, size := utf8.DecodeRune(data[i:])
+		if r < 0x80 {
+			res = append(res, byte(r))
+			i += size
+			continue
+		}
+		b, ok := encodeRune(t, r)
+		if !ok {
+			return nil, &CharsetError{Charset: cs, Offset: i, Byte: data[i]}
+		}
+		res = append(res, b)
+		i += size
+	}
+	return res, nil
+}
+
+// EncodeLossy - перекодирует UTF-8 data в кодировку cs, символы, которых нет в кодировке,
+// и некорректные байты UTF-8 заменяются на ReplacementChar
+func (cs Charset) EncodeLossy(data []byte) []byte {
+	t := cs.table()
+	if t == nil && utf8.Valid(data) {
+		return data
+	}
+	res := make([]byte, 0, len(data))
+	for i := 0; i < len(data); {
+		r, size := utf8.DecodeRune(data[i:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			res = append(res, ReplacementChar)
+		case r < 0x80 || t == nil:
+			res = append(res, data[i:i+size]...)
+		default:
+			b, ok := encodeRune(t, r)
+			if !ok {
+				b = ReplacementChar
+			}
+			res = append(res, b)
+		}
+		i += size
+	}
+	return res
+}
+
+func encodeRune(t *[128]rune, r rune) (byte, bool) {
+	if r == utf8.RuneError {
+		return 0, false
+	}
+	for i, v := range t {
+		if v == r {
+			return byte(i + 0x80), true
+		}
+	}
+	return 0, false
+}
+
+func invalidUTF8(cs Charset, data []byte) error {
+	for i := 0; i < len(data); {
+		r, size := utf8.DecodeRune(data[i:])
+		if r == utf8.RuneError && size == 1 {
+			return &CharsetError{Charset: cs, Offset: i, Byte: data[i]}
+		}
+		i += size
+	}
+	return ErrInvalidCharset
+}
+
+// CharsetParser - делегат парсера, который перекодирует данные текстовых сообщений (ContentType "text"):
+// при разборе из кодировки клиента в UTF-8, при формировании из UTF-8 в кодировку клиента.
+// Кодировку можно поменять во время работы соединения (например после авторизации клиента)
+type CharsetParser struct {
+	IParser
+	charset int32
+}
+
+// WithCharset - оборачивает парсер p перекодированием текстовых данных в кодировку cs
+func WithCharset(p IParser, cs Charset) *CharsetParser {
+	return &CharsetParser{IParser: p, charset: int32(cs)}
+}
+
+// Charset - текущая кодировка клиента
+func (c *CharsetParser) Charset() Charset {
+	return Charset(atomic.LoadInt32(&c.charset))
+}
+
+// SetCharset - меняет кодировку клиента
+func (c *CharsetParser) SetCharset(cs Charset) {
+	atomic.StoreInt32(&c.charset, int32(cs))
+}
+
+func isText(msg *dto.Message) bool {
+	return msg.ContentType == "text"
+}
+
+//FormMessage - перекодирует текстовые данные в кодировку клиента и формирует пакет. Данные msg не изменяются.
+// Символы, которых нет в кодировке клиента, заменяются на ReplacementChar, чтобы одно сообщение не разрывало соединение
+func (c *CharsetParser) FormMessage(msg *dto.Message) ([]byte, error) {
+	if msg == nil || !isText(msg) || c.Charset() == CharsetUTF8 {
+		return c.IParser.FormMessage(msg)
+	}
+	m := *msg
+	m.Data = c.Charset().EncodeLossy(msg.Data)
+	res, err := c.IParser.FormMessage(&m)
+	msg.Proto = m.Proto
+	return res, err
+}
+
+//ParseMessage - разбирает пакет и перекодирует текстовые данные в UTF-8.
+// Некорректные для кодировки байты возвращают *CharsetError вместе с разобранным (не перекодированным) сообщением
+func (c *CharsetParser) ParseMessage(data []byte) (dto.Message, error) {
+	msg, err := c.IParser.ParseMessage(data)
+	if err != nil || !isText(&msg) {
+		return msg, err
+	}
+	text, err := c.Charset().Decode(msg.Data)
+	if err != nil {
+		return msg, err
+	}
+	msg.Data = text
+	return msg, nil
+}
+
+// FrameSize - делегирует IFrameSizer, если его реализует исходный парсер
+func (c *CharsetParser) FrameSize(data []byte) (int, int, error) {
+	if fs, ok := c.IParser.(IFrameSizer); ok {
+		return fs.FrameSize(data)
+	}
+	n, err := c.IParser.IsFullReceiveMsg(data)
+	if err != nil {
+		return 0, 0, err
+	}
+	return 0, len(data) + n, nil
+}
+
+// ReadPacketHeaderContext - делегирует IContextHeaderReader, если его реализует исходный парсер
+func (c *CharsetParser) ReadPacketHeaderContext(ctx context.Context, r io.Reader) ([]byte, error) {
+	if hr, ok := c.IParser.(IContextHeaderReader); ok {
+		return hr.ReadPacketHeaderContext(ctx, r)
+	}
+	return c.IParser.ReadPacketHeader(r)
+}
+
+// SetHeaderLimits - передает ограничения на чтение заголовка исходному парсеру
+func (c *CharsetParser) SetHeaderLimits(limits HeaderLimits) {
+	if lp, ok := c.IParser.(interface{ SetHeaderLimits(HeaderLimits) }); ok {
+		lp.SetHeaderLimits(limits)
+	}
+}
@@ -0,0 +1,49 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/blabu/messagesLib/dto"
+)
+
+func TestEncodeLossy(t *testing.T) {
+	cases := []struct {
+		name string
+		cs   Charset
+		in   string
+		want string
+	}{
+		{"ascii", CharsetWindows1251, "AT+CSQ", "AT+CSQ"},
+		{"cyrillic_1251", CharsetWindows1251, "Привет", "\xcf\xf0\xe8\xe2\xe5\xf2"},
+		{"cyrillic_koi8u", CharsetKOI8U, "Ґанок", "\xbd\xc1\xce\xcf\xcb"},
+		{"not_in_charset", CharsetWindows1251, "t=5°C ✓ 😀", "t=5\xb0C ? ?"},
+		{"invalid_utf8", CharsetKOI8U, "a\xffb", "a?b"},
+		{"utf8", CharsetUTF8, "Привет ✓", "Привет ✓"},
+		{"utf8_invalid", CharsetUTF8, "a\xffb", "a?b"},
+	}
+	for _, tc := range cases {
+		if got := string(tc.cs.EncodeLossy([]byte(tc.in))); got != tc.want {
+			t.Errorf("%s: %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
+
+// TestCharsetFormMessage - сообщение с символом, которого нет в кодировке клиента, формируется с заменой, а не ошибкой
+func TestCharsetFormMessage(t *testing.T) {
+	p := WithCharset(CreateEmptyParser(testMaxSize), CharsetWindows1251)
+	msg := dto.Message{
+		MessageMetaInf: dto.MessageMetaInf{Command: dto.DataCOMMAND, From: "server", To: "modem"},
+		MessageContent: dto.MessageContent{ContentType: "text", Data: []byte("Температура 5° ✓")},
+	}
+	frame, err := p.FormMessage(&msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(msg.Data) != "Температура 5° ✓" {
+		t.Fatalf("Message data changed to %q", msg.Data)
+	}
+	got, err := p.ParseMessage(frame)
+	if err != nil || string(got.Data) != "Температура 5° ?" {
+		t.Fatalf("Parsed %q %v", got.Data, err)
+	}
+}
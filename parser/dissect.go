@@ -0,0 +1,83 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/blabu/messagesLib/dto"
+)
+
+// FrameInfo - результат разбора пакета для отладки.
+// В отличии от ParseMessage пакет с неверной контрольной суммой тоже разбирается
+type FrameInfo struct {
+	dto.Message
+	Offset   int    // Смещение начала пакета во входных данных
+	Size     int    // Полный размер пакета (заголовок + данные + контрольная сумма)
+	Header   []byte // Заголовок пакета вместе с BeginHeader и EndHeader
+	RawType  string // Тип сообщения как он записан в заголовке (T, B, A, V, F)
+	Checksum uint32 // Контрольная сумма из пакета
+	Expected uint32 // Рассчитанная контрольная сумма
+}
+
+// ChecksumOK - контрольная сумма пакета верна
+func (f *FrameInfo) ChecksumOK() bool {
+	return f.Checksum == f.Expected
+}
+
+func (c2c *C2cParser) findFrame(data []byte) (int, error) {
+	start := bytes.Index(data, []byte(c2c.beginHeader()))
+	if start < 0 {
+		return start, fmt.Errorf("Package must be started from %s", c2c.beginHeader())
+	}
+	if _, err := c2c.parseHeader(data[start:]); err != nil {
+		return start, err
+	}
+	if c2c.head.contentSize < 4 {
+		return start, errors.New("Icorrect message size, it must include checksum")
+	}
+	return start, nil
+}
+
+// FrameSize - возвращает смещение начала первого пакета в data и его полный размер
+func (c2c *C2cParser) FrameSize(data []byte) (int, int, error) {
+	defer func() {
+		c2c.head = header{}
+	}()
+	start, err := c2c.findFrame(data)
+	if err != nil {
+		return start, 0, err
+	}
+	return start, c2c.head.headerSize + c2c.head.contentSize, nil
+}
+
+// Dissect - разбирает первый пакет в data. Несовпадение контрольной суммы не является ошибкой, результат проверки в FrameInfo
+func (c2c *C2cParser) Dissect(data []byte) (FrameInfo, error) {
+	defer func() {
+		c2c.head = header{}
+	}()
+	start, err := c2c.findFrame(data)
+	if err != nil {
+		return FrameInfo{Offset: start}, err
+	}
+	frame := data[start:]
+	size := c2c.head.headerSize + c2c.head.contentSize
+	if len(frame) < size {
+		return FrameInfo{Offset: start}, errors.New("Not full message")
+	}
+	content := make([]byte, c2c.head.contentSize-4)
+	copy(content, frame[c2c.head.headerSize:size-4])
+	info := FrameInfo{
+		Message:  c2c.message(content),
+		Offset:   start,
+		Size:     size,
+		Header:   frame[:c2c.head.headerSize],
+		Checksum: binary.LittleEndian.Uint32(frame[size-4 : size]),
+		Expected: checksumCustom(frame[:size-4]),
+	}
+	if fields := bytes.Split(info.Header, delim); len(fields) > 4 {
+		info.RawType = string(fields[4])
+	}
+	return info, nil
+}
@@ -0,0 +1,84 @@
+//go:build go1.18
+// +build go1.18
+
+package parser
+
+import (
+	"bytes"
+	"testing"
+)
+
+// addGoldenSeeds - эталонные пакеты и известные проблемные заголовки как начальный корпус
+func addGoldenSeeds(f *testing.F) {
+	for _, v := range loadGolden(f) {
+		f.Add(v.frame(f))
+	}
+	f.Add([]byte("$V1;a;b;5;T;;0;0###"))
+	f.Add([]byte("$V1;a;b;5;;;0;4###\x00\x00\x00\x00"))
+	f.Add([]byte("$V1;;;0;T;;;FFFFFFFF###"))
+	f.Add([]byte("###$V1;a;b;5;T;;0;4###"))
+}
+
+// FuzzParseMessage - разбор произвольных данных не паникует, разобранное сообщение формируется и разбирается повторно без изменений
+func FuzzParseMessage(f *testing.F) {
+	addGoldenSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		p, err := InitParser(data, testMaxSize)
+		if err != nil {
+			return
+		}
+		msg, err := p.ParseMessage(data)
+		if err != nil || msg.ContentType == "" {
+			return // FormMessage требует тип содержимого
+		}
+		frame, err := p.FormMessage(&msg)
+		if err != nil {
+			t.Fatalf("FormMessage of parsed %+v: %v", msg, err)
+		}
+		again, err := p.ParseMessage(frame)
+		if err != nil {
+			t.Fatalf("ParseMessage of formed %q: %v", frame, err)
+		}
+		formed, err := p.FormMessage(&again)
+		if err != nil || !bytes.Equal(formed, frame) {
+			t.Fatalf("Round trip differs:\n got  %q\n want %q (%v)", formed, frame, err)
+		}
+	})
+}
+
+// FuzzIsFullReceiveMsg - проверка полноты пакета не паникует и не возвращает отрицательный остаток без ошибки
+func FuzzIsFullReceiveMsg(f *testing.F) {
+	addGoldenSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		p, err := InitParser(data, testMaxSize)
+		if err != nil {
+			return
+		}
+		if rest, err := p.IsFullReceiveMsg(data); err == nil && rest < 0 {
+			t.Fatalf("IsFullReceiveMsg returns %d without error", rest)
+		}
+		if fs, ok := p.(IFrameSizer); ok {
+			if start, size, err := fs.FrameSize(data); err == nil && (start < 0 || size < 0) {
+				t.Fatalf("FrameSize returns %d, %d without error", start, size)
+			}
+		}
+	})
+}
+
+// FuzzReadPacketHeader - чтение заголовка из потока не паникует, не выходит за MaxHeaderSize
+// и возвращает начало потока с концом заголовка
+func FuzzReadPacketHeader(f *testing.F) {
+	addGoldenSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		head, err := CreateEmptyParser(testMaxSize).ReadPacketHeader(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		if !bytes.HasPrefix(data, head) || !bytes.Contains(head, []byte(EndHeader)) {
+			t.Fatalf("ReadPacketHeader returns %q", head)
+		}
+		if end := bytes.Index(head, []byte(EndHeader)) + len(EndHeader); end > DefaultMaxHeaderSize {
+			t.Fatalf("Header of %d bytes exceeds limit", end)
+		}
+	})
+}
@@ -0,0 +1,152 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/blabu/messagesLib/dto"
+)
+
+const testMaxSize = 1 << 16
+
+var goldenDir = filepath.Join("testdata", "golden")
+
+// goldenVector - описание эталонного пакета из testdata/golden/vectors.json (смотри cmd/c2cgolden)
+type goldenVector struct {
+	Name        string `json:"name"`
+	Frame       string `json:"frame"`
+	Armor       string `json:"armor"`
+	Proto       uint16 `json:"proto"`
+	Command     uint16 `json:"cmd"`
+	ID          uint8  `json:"id"`
+	From        string `json:"from"`
+	To          string `json:"to"`
+	Channel     string `json:"channel"`
+	ContentType string `json:"contentType"`
+	Data        string `json:"data"`
+	Error       string `json:"error"`
+}
+
+func loadGolden(t testing.TB) []goldenVector {
+	js, err := ioutil.ReadFile(filepath.Join(goldenDir, "vectors.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var vectors []goldenVector
+	if err = json.Unmarshal(js, &vectors); err != nil {
+		t.Fatal(err)
+	}
+	return vectors
+}
+
+func (v *goldenVector) frame(t testing.TB) []byte {
+	frame, err := ioutil.ReadFile(filepath.Join(goldenDir, v.Frame))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return frame
+}
+
+func (v *goldenVector) message(t testing.TB) dto.Message {
+	data, err := hex.DecodeString(v.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msg dto.Message
+	msg.Proto, msg.Command, msg.ID = v.Proto, v.Command, v.ID
+	msg.From, msg.To, msg.Channel = v.From, v.To, v.Channel
+	msg.ContentType, msg.Data = v.ContentType, data
+	return msg
+}
+
+func sameMessage(got, want *dto.Message) bool {
+	return got.Proto == want.Proto && got.Command == want.Command && got.ID == want.ID &&
+		got.From == want.From && got.To == want.To && got.Channel == want.Channel &&
+		got.ContentType == want.ContentType && bytes.Equal(got.Data, want.Data) && got.Signature == want.Signature
+}
+
+// TestGoldenRoundTrip - эталоны разбираются в описанные сообщения, а сообщения формируются в те же байты
+func TestGoldenRoundTrip(t *testing.T) {
+	for _, v := range loadGolden(t) {
+		v := v
+		t.Run(v.Name, func(t *testing.T) {
+			frame := v.frame(t)
+			p, err := InitParser(frame, testMaxSize)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := p.ParseMessage(frame)
+			if v.Error != "" {
+				if err == nil || err.Error() != v.Error {
+					t.Fatalf("ParseMessage error %v, want %q", err, v.Error)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := v.message(t)
+			if !sameMessage(&got, &want) {
+				t.Fatalf("ParseMessage got %+v, want %+v", got, want)
+			}
+			if rest, err := p.IsFullReceiveMsg(frame); err != nil || rest != 0 {
+				t.Fatalf("IsFullReceiveMsg returns %d, %v", rest, err)
+			}
+			head, err := p.ReadPacketHeader(bytes.NewReader(frame))
+			if err != nil || !bytes.HasPrefix(frame, head) || !bytes.Contains(head, []byte(EndHeader)) {
+				t.Fatalf("ReadPacketHeader returns %q, %v", head, err)
+			}
+			formed, err := p.FormMessage(&want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(formed, frame) {
+				t.Fatalf("FormMessage differs:\n got  %q\n want %q", formed, frame)
+			}
+		})
+	}
+}
+
+// TestFormParse - FormMessage -> ParseMessage возвращает то же сообщение для всех вариантов протокола
+func TestFormParse(t *testing.T) {
+	parsers := map[string]func() IParser{
+		"c2c":    func() IParser { return CreateEmptyParser(testMaxSize) },
+		"base64": func() IParser { return CreateArmoredParser(testMaxSize, ArmorBase64) },
+		"hex":    func() IParser { return CreateArmoredParser(testMaxSize, ArmorHex) },
+	}
+	messages := []dto.Message{
+		{MessageMetaInf: dto.MessageMetaInf{Proto: 1, Command: dto.PingCOMMAND}, MessageContent: dto.MessageContent{ContentType: "binary"}},
+		{MessageMetaInf: dto.MessageMetaInf{Proto: 1, Command: dto.DataCOMMAND, ID: 9, From: "a", To: "b"}, MessageContent: dto.MessageContent{ContentType: "text", Data: []byte("hello")}},
+		{MessageMetaInf: dto.MessageMetaInf{Proto: 1, Command: dto.DataCOMMAND, ID: 10, From: "a", Channel: "news"}, MessageContent: dto.MessageContent{ContentType: "file", Data: []byte("$V1;###")}},
+		{MessageMetaInf: dto.MessageMetaInf{Proto: 1, Command: 0xFFFF, ID: 255, From: "a", To: "b", Signature: "c2lnbmF0dXJl+/=="}, MessageContent: dto.MessageContent{ContentType: "audio", Data: bytes.Repeat([]byte{0}, 1000)}},
+	}
+	for name, newParser := range parsers {
+		for i := range messages {
+			want := messages[i]
+			frame, err := newParser().FormMessage(&want)
+			if err != nil {
+				t.Fatalf("%s #%d: FormMessage: %v", name, i, err)
+			}
+			got, err := newParser().ParseMessage(frame)
+			if err != nil {
+				t.Fatalf("%s #%d: ParseMessage: %v", name, i, err)
+			}
+			if !sameMessage(&got, &want) {
+				t.Fatalf("%s #%d: got %+v, want %+v", name, i, got, want)
+			}
+		}
+	}
+}
+
+// TestParseShortSize - пакет с размером меньше контрольной суммы отклоняется без паники
+func TestParseShortSize(t *testing.T) {
+	for _, frame := range []string{"$V1;a;b;5;T;;0;0###", "$V1;a;b;5;T;;0;3###abc", "$V1;a;b;5;T;;0;0###\x00\x00\x00\x00"} {
+		if _, err := CreateEmptyParser(testMaxSize).ParseMessage([]byte(frame)); err == nil {
+			t.Errorf("%q is parsed", frame)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// DefaultMaxHeaderSize - максимальный размер заголовка, если он не задан в HeaderLimits
+const DefaultMaxHeaderSize = 512
+
+var (
+	// ErrHeaderTooLarge - в потоке не найден конец заголовка в пределах HeaderLimits.MaxHeaderSize
+	ErrHeaderTooLarge = errors.New("Header is too large")
+	// ErrHeaderTimeout - заголовок не был прочитан за HeaderLimits.Timeout (или до дедлайна контекста)
+	ErrHeaderTimeout = errors.New("Header read timeout")
+	// ErrSlowSender - отправитель передает данные медленнее чем HeaderLimits.MinByteRate
+	ErrSlowSender = errors.New("Sender is too slow")
+)
+
+// HeaderLimits - ограничения при чтении заголовка пакета из потока.
+// Нулевое значение поля означает отсутствие ограничения (кроме MaxHeaderSize, для него используется DefaultMaxHeaderSize)
+type HeaderLimits struct {
+	MaxHeaderSize int           // Максимальный размер заголовка в байтах
+	Timeout       time.Duration // Максимальное время на чтение всего заголовка
+	MinByteRate   int           // Минимальная скорость приема заголовка байт/сек
+	RateGrace     time.Duration // Время с начала чтения, в течение которого скорость приема не проверяется
+}
+
+func (l HeaderLimits) maxHeaderSize() int {
+	if l.MaxHeaderSize <= 0 {
+		return DefaultMaxHeaderSize
+	}
+	return l.MaxHeaderSize
+}
+
+// rateDeadline - время, до которого должен прийти следующий байт, чтобы скорость приема не упала ниже MinByteRate
+func (l HeaderLimits) rateDeadline(start time.Time, received int) time.Time {
+	if l.MinByteRate <= 0 {
+		return time.Time{}
+	}
+	t := start.Add(time.Duration(received+1) * time.Second / time.Duration(l.MinByteRate))
+	if grace := start.Add(l.RateGrace); t.Before(grace) {
+		return grace
+	}
+	return t
+}
+
+// HeaderReadError - ошибка чтения заголовка с ограничениями.
+// Err - одна из ErrHeaderTooLarge, ErrHeaderTimeout, ErrSlowSender или ошибка контекста
+type HeaderReadError struct {
+	Err      error
+	Received int           // Сколько байт было прочитано до ошибки
+	Elapsed  time.Duration // Сколько времени длилось чтение
+}
+
+func (e *HeaderReadError) Error() string {
+	return fmt.Sprintf("%s (received %d bytes in %s)", e.Err.Error(), e.Received, e.Elapsed)
+}
+
+func (e *HeaderReadError) Unwrap() error {
+	return e.Err
+}
+
+// aLongTimeAgo - дедлайн в прошлом, прерывает блокирующее чтение
+var aLongTimeAgo = time.Unix(1, 0)
+
+type readDeadliner interface {
+	SetReadDeadline(t time.Time) error
+}
+
+func isTimeout(err error) bool {
+	var te interface{ Timeout() bool }
+	return errors.As(err, &te) && te.Timeout()
+}
+
+// deadlineGuard - выставляет дедлайны чтения и прерывает блокирующее чтение при отмене контекста
+type deadlineGuard struct {
+	mu       sync.Mutex
+	conn     readDeadliner
+	canceled bool
+	released bool
+	stop     chan struct{}
+}
+
+// newDeadlineGuard - вернет nil если r не поддерживает дедлайны
+func newDeadlineGuard(ctx context.Context, r io.Reader) *deadlineGuard {
+	d, ok := r.(readDeadliner)
+	if !ok {
+		return nil
+	}
+	g := &deadlineGuard{conn: d, stop: make(chan struct{})}
+	go func() {
+		select {
+		case <-ctx.Done():
+			g.mu.Lock()
+			if !g.released {
+				g.canceled = true
+				g.conn.SetReadDeadline(aLongTimeAgo)
+			}
+			g.mu.Unlock()
+		case <-g.stop:
+		}
+	}()
+	return g
+}
+
+func (g *deadlineGuard) set(t time.Time) {
+	if g == nil {
+		return
+	}
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if !g.canceled {
+		g.conn.SetReadDeadline(t)
+	}
+}
+
+// release - снимает дедлайн, чтобы соединение можно было использовать дальше.
+// Дедлайн, который был у соединения до newDeadlineGuard, не восстанавливается (смотри ReadPacketHeaderContext)
+func (g *deadlineGuard) release() {
+	if g == nil {
+		return
+	}
+	g.mu.Lock()
+	g.released = true
+	g.conn.SetReadDeadline(time.Time{})
+	g.mu.Unlock()
+	close(g.stop)
+}
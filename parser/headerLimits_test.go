@@ -0,0 +1,29 @@
+package parser
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+// TestHeaderKeepsCallerDeadline - без ограничений времени и отмены контекста дедлайн соединения, выставленный до вызова, действует
+func TestHeaderKeepsCallerDeadline(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	server.SetReadDeadline(time.Now().Add(50 * time.Millisecond))
+	done := make(chan error, 1)
+	go func() {
+		_, err := CreateEmptyParser(testMaxSize).(IContextHeaderReader).ReadPacketHeaderContext(context.Background(), server)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if !isTimeout(err) {
+			t.Fatalf("Read error %v, want timeout", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Deadline set before ReadPacketHeaderContext is removed")
+	}
+}
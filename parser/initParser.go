@@ -1,5 +1,14 @@
-package parser
-
-func InitParser(rec []byte, size uint64) (IParser, error) {
-	return CreateEmptyParser(size), nil
-}
+package parser
+
+import "bytes"
+
+// InitParser - выбирает парсер по началу принятого сообщения rec
+func InitParser(rec []byte, size uint64) (IParser, error) {
+	switch {
+	case bytes.HasPrefix(rec, []byte(ArmorBase64Header)):
+		return CreateArmoredParser(size, ArmorBase64), nil
+	case bytes.HasPrefix(rec, []byte(ArmorHexHeader)):
+		return CreateArmoredParser(size, ArmorHex), nil
+	}
+	return CreateEmptyParser(size), nil
+}
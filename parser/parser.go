@@ -1,18 +1,31 @@
-package parser
-
-import (
-	"io"
-
-	"github.com/blabu/messagesLib/dto"
-)
-
-// IParser - Основной интерфейс для парсинга сообщений (определяет базовый функционал всего приложения)
-// Это связующая точка между сервером и бизнес логикой инициализация которой происходит здесь же
-// Сделан для того чтобы была возможность модифицировать протокол передачи данных, например добавить "взрослое" шифрование
-// При этом достаточно будет сделать делегата, который реализет этот интерфейс, будет расшифровывать сообщение + делегировать остальной функционал обычному парсеру
-type IParser interface {
-	FormMessage(msg *dto.Message) ([]byte, error)
-	ParseMessage(data []byte) (dto.Message, error)
-	IsFullReceiveMsg(data []byte) (int, error)
-	ReadPacketHeader(r io.Reader) ([]byte, error)
-}
+package parser
+
+import (
+	"context"
+	"io"
+
+	"github.com/blabu/messagesLib/dto"
+)
+
+// IParser - Основной интерфейс для парсинга сообщений (определяет базовый функционал всего приложения)
+// Это связующая точка между сервером и бизнес логикой инициализация которой происходит здесь же
+// Сделан для того чтобы была возможность модифицировать протокол передачи данных, например добавить "взрослое" шифрование
+// При этом достаточно будет сделать делегата, который реализет этот интерфейс, будет расшифровывать сообщение + делегировать остальной функционал обычному парсеру
+type IParser interface {
+	FormMessage(msg *dto.Message) ([]byte, error)
+	ParseMessage(data []byte) (dto.Message, error)
+	IsFullReceiveMsg(data []byte) (int, error)
+	ReadPacketHeader(r io.Reader) ([]byte, error)
+}
+
+// IFrameSizer - парсер, который умеет определять границы пакета в потоке данных.
+// Нужен для того, чтобы отделить принятый пакет от начала следующего
+type IFrameSizer interface {
+	// FrameSize - возвращает смещение начала первого пакета в data и его полный размер (пакет может быть принят не полностью)
+	FrameSize(data []byte) (start int, size int, err error)
+}
+
+// IContextHeaderReader - парсер, который умеет прерывать чтение заголовка по отмене контекста
+type IContextHeaderReader interface {
+	ReadPacketHeaderContext(ctx context.Context, r io.Reader) ([]byte, error)
+}
@@ -0,0 +1,192 @@
+package parser
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// SMSSegmentSize - размер одного SMS сообщения в 7-битной кодировке
+const SMSSegmentSize = 160
+
+// Сегмент начинается с заголовка "*RRIITT:" где RR - номер пакета, II - номер сегмента, TT - количество сегментов (hex).
+// Маркер берется из основной таблицы GSM-7 (символы расширенной таблицы, например '~', занимают два септета)
+const (
+	segmentMarker     = '*'
+	segmentHeaderSize = 8
+	maxSegments       = 0xFF
+)
+
+// WrapLines - разбивает текстовый пакет на строки длиной width, разделенные "\r\n".
+// Применяется к уже сформированному пакету перед отправкой через построчный шлюз, перед разбором нужен UnwrapLines
+func WrapLines(frame []byte, width int) []byte {
+	if width <= 0 || len(frame) <= width {
+		return frame
+	}
+	res := make([]byte, 0, len(frame)+2*(len(frame)/width))
+	for len(frame) > width {
+		res = append(res, frame[:width]...)
+		res = append(res, '\r', '\n')
+		frame = frame[width:]
+	}
+	return append(res, frame...)
+}
+
+// UnwrapLines - удаляет переводы строк добавленные WrapLines (и шлюзом)
+func UnwrapLines(data []byte) []byte {
+	res := make([]byte, 0, len(data))
+	for _, b := range data {
+		if b != '\r' && b != '\n' {
+			res = append(res, b)
+		}
+	}
+	return res
+}
+
+// SegmentSMS - разбивает текстовый пакет на сегменты не длиннее size символов (обычно SMSSegmentSize).
+// ref - номер пакета, по нему Reassembler отличает сегменты разных пакетов одного отправителя
+func SegmentSMS(frame []byte, ref uint8, size int) ([][]byte, error) {
+	chunk := size - segmentHeaderSize
+	if chunk <= 0 {
+		return nil, fmt.Errorf("Segment size must be greater than %d", segmentHeaderSize)
+	}
+	total := (len(frame) + chunk - 1) / chunk
+	if total == 0 {
+		total = 1
+	}
+	if total > maxSegments {
+		return nil, fmt.Errorf("Frame is too big for %d segments", maxSegments)
+	}
+	res := make([][]byte, 0, total)
+	for i := 0; i < total; i++ {
+		end := (i + 1) * chunk
+		if end > len(frame) {
+			end = len(frame)
+		}
+		seg := make([]byte, 0, segmentHeaderSize+end-i*chunk)
+		seg = append(seg, []byte(fmt.Sprintf("%c%02X%02X%02X:", segmentMarker, ref, i+1, total))...)
+		seg = append(seg, frame[i*chunk:end]...)
+		res = append(res, seg)
+	}
+	return res, nil
+}
+
+type segmentedFrame struct {
+	parts    [][]byte
+	received int
+	started  time.Time
+}
+
+// DefaultReassemblyTimeout - время сборки пакета из сегментов по умолчанию
+const DefaultReassemblyTimeout = 10 * time.Minute
+
+// DefaultMaxPendingFrames - наибольшее количество незавершенных пакетов по умолчанию
+const DefaultMaxPendingFrames = 1024
+
+// Reassembler - собирает пакеты из SMS сегментов, которые могут приходить в любом порядке.
+// Незавершенные пакеты удаляются через timeout после приема первого сегмента,
+// а если их больше MaxPending - удаляется самый старый
+type Reassembler struct {
+	Now        func() time.Time // nil - time.Now
+	MaxPending int              // 0 - DefaultMaxPendingFrames
+
+	timeout time.Duration
+	mu      sync.Mutex
+	frames  map[string]*segmentedFrame
+}
+
+// NewReassembler - создает сборщик сегментов, timeout <= 0 - DefaultReassemblyTimeout
+func NewReassembler(timeout time.Duration) *Reassembler {
+	if timeout <= 0 {
+		timeout = DefaultReassemblyTimeout
+	}
+	return &Reassembler{timeout: timeout, frames: make(map[string]*segmentedFrame)}
+}
+
+func (r *Reassembler) now() time.Time {
+	if r.Now == nil {
+		return time.Now()
+	}
+	return r.Now()
+}
+
+func parseSegmentHeader(seg []byte) (ref, index, total uint64, err error) {
+	if len(seg) < segmentHeaderSize || seg[0] != segmentMarker || seg[segmentHeaderSize-1] != ':' {
+		return 0, 0, 0, errors.New("Incorrect segment header")
+	}
+	if ref, err = strconv.ParseUint(string(seg[1:3]), 16, 8); err != nil {
+		return
+	}
+	if index, err = strconv.ParseUint(string(seg[3:5]), 16, 8); err != nil {
+		return
+	}
+	if total, err = strconv.ParseUint(string(seg[5:7]), 16, 8); err != nil {
+		return
+	}
+	if index == 0 || total == 0 || index > total {
+		err = errors.New("Incorrect segment number")
+	}
+	return
+}
+
+// Add - добавляет сегмент от отправителя from. Возвращает собранный пакет, когда приняты все его сегменты, иначе nil
+func (r *Reassembler) Add(from string, seg []byte) ([]byte, error) {
+	seg = bytes.TrimRight(seg, "\r\n")
+	ref, index, total, err := parseSegmentHeader(seg)
+	if err != nil {
+		return nil, err
+	}
+	now := r.now()
+	key := from + "/" + strconv.FormatUint(ref, 16)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.expire(now)
+	f, ok := r.frames[key]
+	if !ok || len(f.parts) != int(total) {
+		if !ok {
+			r.evict()
+		}
+		f = &segmentedFrame{parts: make([][]byte, total), started: now}
+		r.frames[key] = f
+	}
+	if f.parts[index-1] == nil {
+		part := make([]byte, len(seg)-segmentHeaderSize)
+		copy(part, seg[segmentHeaderSize:])
+		f.parts[index-1] = part
+		f.received++
+	}
+	if f.received < len(f.parts) {
+		return nil, nil
+	}
+	delete(r.frames, key)
+	return bytes.Join(f.parts, nil), nil
+}
+
+func (r *Reassembler) expire(now time.Time) {
+	for key, f := range r.frames {
+		if now.Sub(f.started) > r.timeout {
+			delete(r.frames, key)
+		}
+	}
+}
+
+// evict - освобождает место для нового пакета, удаляя самые старые незавершенные пакеты сверх MaxPending
+func (r *Reassembler) evict() {
+	max := r.MaxPending
+	if max <= 0 {
+		max = DefaultMaxPendingFrames
+	}
+	for len(r.frames) >= max {
+		var oldest string
+		var started time.Time
+		for key, f := range r.frames {
+			if started.IsZero() || f.started.Before(started) {
+				oldest, started = key, f.started
+			}
+		}
+		delete(r.frames, oldest)
+	}
+}
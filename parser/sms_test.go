@@ -0,0 +1,108 @@
+package parser
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blabu/messagesLib/dto"
+)
+
+// gsmBasic - печатные ASCII символы основной таблицы GSM-7 (один септет)
+const gsmBasic = " !\"#$%&'()*+,-./0123456789:;<=>?@ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz_\r\n"
+
+func TestSegmentSMS(t *testing.T) {
+	msg := dto.Message{
+		MessageMetaInf: dto.MessageMetaInf{Proto: 1, Command: dto.DataCOMMAND, ID: 3, From: "modem", To: "server"},
+		MessageContent: dto.MessageContent{ContentType: "binary", Data: bytes.Repeat([]byte{0xA5, 0x00, 0x7E}, 200)},
+	}
+	frame, err := CreateArmoredParser(testMaxSize, ArmorBase64).FormMessage(&msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	segs, err := SegmentSMS(frame, 7, SMSSegmentSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(segs) < 2 {
+		t.Fatalf("%d segments", len(segs))
+	}
+	for i, seg := range segs {
+		if len(seg) > SMSSegmentSize {
+			t.Fatalf("Segment %d has %d symbols", i, len(seg))
+		}
+		for _, c := range string(seg) {
+			if !strings.ContainsRune(gsmBasic, c) {
+				t.Fatalf("Segment %d has symbol %q outside GSM-7 basic table", i, c)
+			}
+		}
+	}
+	r := NewReassembler(0)
+	for i := len(segs) - 1; i > 0; i-- { // В обратном порядке и с повтором
+		if res, err := r.Add("modem", segs[i]); err != nil || res != nil {
+			t.Fatalf("Segment %d: %q %v", i, res, err)
+		}
+		r.Add("modem", segs[i])
+	}
+	res, err := r.Add("modem", append(append([]byte(nil), segs[0]...), '\r', '\n'))
+	if err != nil || !bytes.Equal(res, frame) {
+		t.Fatalf("Reassembled %q %v", res, err)
+	}
+}
+
+func TestReassemblerErrors(t *testing.T) {
+	r := NewReassembler(time.Minute)
+	for _, seg := range []string{"", "~010101:data", "*010101data", "*0Z0101:data", "*010001:data", "*010201:data"} {
+		if _, err := r.Add("modem", []byte(seg)); err == nil {
+			t.Errorf("Segment %q is accepted", seg)
+		}
+	}
+}
+
+// TestReassemblerExpire - незавершенный пакет удаляется через timeout, в том числе через timeout по умолчанию
+func TestReassemblerExpire(t *testing.T) {
+	for _, timeout := range []time.Duration{time.Minute, 0} {
+		wait := timeout
+		if wait == 0 {
+			wait = DefaultReassemblyTimeout
+		}
+		clock := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+		r := NewReassembler(timeout)
+		r.Now = func() time.Time { return clock }
+		r.Add("modem", []byte("*010102:ab"))
+		r.Add("other", []byte("*010102:xy"))
+		clock = clock.Add(wait + time.Second)
+		if res, err := r.Add("modem", []byte("*010202:cd")); err != nil || res != nil {
+			t.Fatalf("Timeout %v: expired frame is completed %q %v", timeout, res, err)
+		}
+		if n := len(r.frames); n != 1 {
+			t.Fatalf("Timeout %v: %d frames kept, want 1", timeout, n)
+		}
+		if res, err := r.Add("modem", []byte("*010102:ab")); err != nil || string(res) != "abcd" {
+			t.Fatalf("Timeout %v: reassembled %q %v", timeout, res, err)
+		}
+	}
+}
+
+// TestReassemblerMaxPending - при превышении MaxPending удаляется самый старый незавершенный пакет
+func TestReassemblerMaxPending(t *testing.T) {
+	clock := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	r := NewReassembler(time.Hour)
+	r.Now = func() time.Time { return clock }
+	r.MaxPending = 3
+	for ref := 1; ref <= 300; ref++ {
+		clock = clock.Add(time.Second)
+		r.Add(fmt.Sprintf("modem%d", ref), []byte(fmt.Sprintf("*%02X0102:ab", ref%256)))
+	}
+	if n := len(r.frames); n != 3 {
+		t.Fatalf("%d frames kept, want 3", n)
+	}
+	if res, err := r.Add("modem298", []byte("*2A0202:cd")); err != nil || string(res) != "abcd" {
+		t.Fatalf("Newest frame: %q %v", res, err)
+	}
+	if res, err := r.Add("modem1", []byte("*010202:cd")); err != nil || res != nil {
+		t.Fatalf("Oldest frame is completed: %q %v", res, err)
+	}
+}
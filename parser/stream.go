@@ -0,0 +1,146 @@
+package parser
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"time"
+
+	"github.com/blabu/messagesLib/dto"
+)
+
+// Reader - читает пакеты из потока (например net.Conn) и разбирает их парсером.
+// Если парсер реализует IFrameSizer, данные принятые после конца пакета сохраняются для следующего чтения,
+// иначе считается что в потоке нет данных после конца пакета
+type Reader struct {
+	r   io.Reader
+	p   IParser
+	buf []byte
+}
+
+// NewReader - создает читателя пакетов из потока r
+func NewReader(r io.Reader, p IParser) *Reader {
+	return &Reader{r: r, p: p}
+}
+
+// FrameError - ошибка разбора пакета. Ошибочный пакет пропущен, чтение из потока можно продолжать
+type FrameError struct {
+	Err error
+}
+
+func (e *FrameError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *FrameError) Unwrap() error {
+	return e.Err
+}
+
+// Unread - возвращает уже принятые данные (например прочитанные для InitParser) в начало потока
+func (fr *Reader) Unread(data []byte) {
+	fr.buf = append(append([]byte(nil), data...), fr.buf...)
+}
+
+// Parser - парсер, которым читатель разбирает пакеты
+func (fr *Reader) Parser() IParser {
+	return fr.p
+}
+
+// readHeader - читает заголовок с учетом уже принятых данных
+func (fr *Reader) readHeader(ctx context.Context) error {
+	r := &prefixReader{prefix: fr.buf, r: fr.r}
+	fr.buf = nil
+	var err error
+	if cr, ok := fr.p.(IContextHeaderReader); ok {
+		fr.buf, err = cr.ReadPacketHeaderContext(ctx, r)
+	} else {
+		fr.buf, err = fr.p.ReadPacketHeader(r)
+	}
+	return err
+}
+
+// prefixReader - отдает сначала ранее принятые данные, потом читает из потока. Дедлайны передаются потоку
+type prefixReader struct {
+	prefix []byte
+	r      io.Reader
+}
+
+func (pr *prefixReader) Read(p []byte) (int, error) {
+	if len(pr.prefix) > 0 {
+		n := copy(p, pr.prefix)
+		pr.prefix = pr.prefix[n:]
+		return n, nil
+	}
+	return pr.r.Read(p)
+}
+
+func (pr *prefixReader) SetReadDeadline(t time.Time) error {
+	if d, ok := pr.r.(readDeadliner); ok {
+		return d.SetReadDeadline(t)
+	}
+	return nil
+}
+
+// ReadMessage - читает следующий пакет из потока.
+// Ошибки разбора пакета возвращаются как *FrameError, при этом данные до конца заголовка отбрасываются
+func (fr *Reader) ReadMessage(ctx context.Context) (dto.Message, error) {
+	if bytes.Index(fr.buf, []byte(EndHeader)) < 0 {
+		if err := fr.readHeader(ctx); err != nil {
+			return dto.Message{}, err
+		}
+	}
+	sizer, ok := fr.p.(IFrameSizer)
+	if !ok {
+		return fr.readWhole()
+	}
+	start, size, err := sizer.FrameSize(fr.buf)
+	if err != nil {
+		fr.dropHeader()
+		return dto.Message{}, &FrameError{Err: err}
+	}
+	if rest := start + size - len(fr.buf); rest > 0 {
+		tail := make([]byte, rest)
+		if _, err = io.ReadFull(fr.r, tail); err != nil {
+			return dto.Message{}, err
+		}
+		fr.buf = append(fr.buf, tail...)
+	}
+	frame := fr.buf[start : start+size]
+	fr.buf = append([]byte(nil), fr.buf[start+size:]...)
+	return fr.parse(frame)
+}
+
+// readWhole - дочитывает пакет через IsFullReceiveMsg, весь буфер считается одним пакетом
+func (fr *Reader) readWhole() (dto.Message, error) {
+	rest, err := fr.p.IsFullReceiveMsg(fr.buf)
+	if err != nil {
+		fr.dropHeader()
+		return dto.Message{}, &FrameError{Err: err}
+	}
+	if rest > 0 {
+		tail := make([]byte, rest)
+		if _, err = io.ReadFull(fr.r, tail); err != nil {
+			return dto.Message{}, err
+		}
+		fr.buf = append(fr.buf, tail...)
+	}
+	frame := fr.buf
+	fr.buf = nil
+	return fr.parse(frame)
+}
+
+func (fr *Reader) parse(frame []byte) (dto.Message, error) {
+	msg, err := fr.p.ParseMessage(frame)
+	if err != nil {
+		return msg, &FrameError{Err: err}
+	}
+	return msg, nil
+}
+
+func (fr *Reader) dropHeader() {
+	if end := bytes.Index(fr.buf, []byte(EndHeader)); end >= 0 {
+		fr.buf = append([]byte(nil), fr.buf[end+len(EndHeader):]...)
+		return
+	}
+	fr.buf = nil
+}
@@ -0,0 +1,140 @@
+package parser
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/blabu/messagesLib/chaos"
+	"github.com/blabu/messagesLib/dto"
+)
+
+func streamMessages(n int) []dto.Message {
+	res := make([]dto.Message, n)
+	for i := range res {
+		res[i].Proto, res[i].Command, res[i].ID = 1, dto.DataCOMMAND, uint8(i)
+		res[i].From, res[i].To = "modem", "server"
+		res[i].ContentType, res[i].Data = "text", []byte(fmt.Sprintf("message %d", i))
+	}
+	return res
+}
+
+// chaosPipe - соединение, запись в которое искажается wr, а чтение из которого искажается rd.
+// Сообщения записываются в отдельной горутине, после чего пишущая сторона закрывается
+func chaosPipe(t *testing.T, messages []dto.Message, wr, rd chaos.Faults) (*chaos.Conn, *chaos.Conn) {
+	t.Helper()
+	a, b := net.Pipe()
+	w := chaos.WrapConn(a, chaos.Config{Seed: 1, Write: wr})
+	r := chaos.WrapConn(b, chaos.Config{Seed: 2, Read: rd})
+	t.Cleanup(func() { r.Close() })
+	go func() {
+		defer w.Close()
+		p := CreateEmptyParser(testMaxSize)
+		for i := range messages {
+			frame, err := p.FormMessage(&messages[i])
+			if err != nil {
+				return
+			}
+			if _, err = w.Write(frame); err != nil {
+				return
+			}
+		}
+	}()
+	return w, r
+}
+
+// TestReaderChaosFragmented - пакеты, разбитые и склеенные в произвольных местах, читаются целиком и по порядку
+func TestReaderChaosFragmented(t *testing.T) {
+	sent := streamMessages(50)
+	w, r := chaosPipe(t, sent,
+		chaos.Faults{SplitRate: 0.5, CoalesceRate: 0.3, Jitter: time.Millisecond},
+		chaos.Faults{SplitRate: 0.7})
+	fr := NewReader(r, CreateEmptyParser(testMaxSize))
+	ctx := context.Background()
+	for i := range sent {
+		msg, err := fr.ReadMessage(ctx)
+		if err != nil {
+			t.Fatalf("Message %d: %v", i, err)
+		}
+		if !sameMessage(&msg, &sent[i]) {
+			t.Fatalf("Message %d: got %+v, want %+v", i, msg, sent[i])
+		}
+	}
+	if _, err := fr.ReadMessage(ctx); err != io.EOF {
+		t.Fatalf("After last message: %v, want EOF", err)
+	}
+	if ws, rs := w.Stats(), r.Stats(); ws.Split == 0 || ws.Coalesced == 0 || rs.Split == 0 {
+		t.Fatalf("Faults are not injected: write %+v, read %+v", ws, rs)
+	}
+}
+
+// TestReaderChaosDelay - задержки в пределах HeaderLimits не мешают чтению, а превышающие их прерывают его
+func TestReaderChaosDelay(t *testing.T) {
+	cases := []struct {
+		name    string
+		latency time.Duration
+		timeout time.Duration
+		err     error
+	}{
+		{"in_time", time.Millisecond, time.Second, nil},
+		{"too_slow", 100 * time.Millisecond, 50 * time.Millisecond, ErrHeaderTimeout},
+	}
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			sent := streamMessages(3)
+			_, r := chaosPipe(t, sent, chaos.Faults{}, chaos.Faults{SplitRate: 1, Latency: tc.latency})
+			p := CreateParserWithLimits(testMaxSize, HeaderLimits{Timeout: tc.timeout})
+			fr := NewReader(r, p)
+			for i := range sent {
+				msg, err := fr.ReadMessage(context.Background())
+				if tc.err != nil {
+					if !errors.Is(err, tc.err) {
+						t.Fatalf("Error %v, want %v", err, tc.err)
+					}
+					return
+				}
+				if err != nil || !sameMessage(&msg, &sent[i]) {
+					t.Fatalf("Message %d: %+v %v", i, msg, err)
+				}
+			}
+		})
+	}
+}
+
+// TestReaderChaosCorruption - искаженные пакеты отбрасываются как *FrameError, чтение продолжается со следующих пакетов
+func TestReaderChaosCorruption(t *testing.T) {
+	sent := streamMessages(200)
+	w, r := chaosPipe(t, sent, chaos.Faults{FlipRate: 0.001, DropRate: 0.0005, SplitRate: 0.3}, chaos.Faults{})
+	fr := NewReader(r, CreateEmptyParser(testMaxSize))
+	received, frameErrors := 0, 0
+	for {
+		msg, err := fr.ReadMessage(context.Background())
+		var fe *FrameError
+		if errors.As(err, &fe) {
+			frameErrors++
+			continue
+		}
+		if err != nil {
+			if err != io.EOF && err != io.ErrUnexpectedEOF {
+				t.Fatalf("Stream error %v", err)
+			}
+			break
+		}
+		if int(msg.ID) >= len(sent) || !sameMessage(&msg, &sent[msg.ID]) {
+			t.Fatalf("Corrupted message is accepted: %+v", msg)
+		}
+		received++
+	}
+	stats := w.Stats()
+	if stats.Flipped+stats.Dropped == 0 {
+		t.Fatal("Data is not corrupted")
+	}
+	if frameErrors == 0 || received < len(sent)/2 {
+		t.Fatalf("Received %d of %d messages, %d frame errors, faults %+v", received, len(sent), frameErrors, stats)
+	}
+}
@@ -0,0 +1,234 @@
+// Package provision - жизненный цикл учетных данных устройств: первая регистрация по одноразовому коду,
+// ротация токена с периодом перекрытия, отзыв и учет последней активности (ClientDescriptor.LastDate).
+//
+// Middleware ставится перед middleware авторизации (auth.Service.Middleware):
+//
+//	mux.Use(prov.Middleware(), authSvc.Middleware())
+//
+// RegisterCOMMAND с кодом в данных регистрирует устройство с именем From и возвращает токен,
+// GenerateCOMMAND от авторизованного клиента выдает ему новый токен (ответ "имя;токен"),
+// старый токен действует еще Overlap, чтобы устройство успело сохранить новый
+package provision
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/blabu/messagesLib/dto"
+)
+
+// DefaultOverlap - сколько действует старый токен после ротации
+const DefaultOverlap = 24 * time.Hour
+
+// DefaultActivityInterval - как часто сохранять LastDate активного клиента
+const DefaultActivityInterval = time.Minute
+
+var (
+	// ErrInvalidCode - кода нет, он уже использован, просрочен или выдан для другого имени
+	ErrInvalidCode = errors.New("Invalid or expired bootstrap code")
+	// ErrClientExists - клиент уже зарегистрирован (повторная регистрация возможна только после отзыва)
+	ErrClientExists = dto.ErrClientExists
+	// ErrRevoked - учетные данные клиента отозваны
+	ErrRevoked = dto.ErrRevoked
+	// ErrIncorrectName - пустое имя или имя с разделителем dto.DataDelimiter
+	ErrIncorrectName = errors.New("Incorrect client name")
+)
+
+// Service - операции с учетными данными клиентов
+type Service struct {
+	Clients          dto.IBgClientSaver
+	Codes            dto.IBgBootstrapCode
+	Overlap          time.Duration    // 0 - DefaultOverlap
+	ActivityInterval time.Duration    // 0 - DefaultActivityInterval
+	Now              func() time.Time // nil - time.Now
+
+	// HashTokens - сохранять токены в виде хеша (смотри dto.HashToken и auth.Service.HashTokens).
+	// Открытый токен возвращается только в ответе Register и Rotate
+	HashTokens     bool
+	HashIterations int // 0 - dto.DefaultTokenIterations
+}
+
+func (s *Service) now() time.Time {
+	if s.Now == nil {
+		return time.Now()
+	}
+	return s.Now()
+}
+
+func (s *Service) overlap() time.Duration {
+	if s.Overlap <= 0 {
+		return DefaultOverlap
+	}
+	return s.Overlap
+}
+
+func (s *Service) activityInterval() time.Duration {
+	if s.ActivityInterval <= 0 {
+		return DefaultActivityInterval
+	}
+	return s.ActivityInterval
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// store - хранимое значение нового токена. kdf - параметры хеширования текущего токена клиента,
+// с ними старый и новый токен проверяются одним вызовом в период перекрытия
+func (s *Service) store(token string, kdf *dto.TokenKDF) (string, error) {
+	if kdf != nil {
+		return kdf.Hash(token), nil
+	}
+	if !s.HashTokens {
+		return token, nil
+	}
+	return dto.HashToken(token, s.HashIterations)
+}
+
+// IssueCode - выдает одноразовый код регистрации для имени name (пусто - любое имя), действует ttl (0 - бессрочно)
+func (s *Service) IssueCode(ctx context.Context, name string, ttl time.Duration) (string, error) {
+	code, err := randomHex(10)
+	if err != nil {
+		return "", err
+	}
+	now := s.now()
+	bc := dto.BootstrapCode{Code: code, Name: name, Created: now}
+	if ttl > 0 {
+		bc.Expire = now.Add(ttl)
+	}
+	if err = s.Codes.SaveCode(ctx, &bc); err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+// Register - первая регистрация устройства name по коду code, возвращает клиента с открытым токеном.
+// Код тратится даже при ошибке регистрации. Отозванного клиента можно зарегистрировать заново новым кодом.
+// Если Clients реализует dto.IBgClientCreator, одновременная регистрация одного имени разными кодами
+// успешна только для одного из них, иначе проверка существования и сохранение не атомарны
+func (s *Service) Register(ctx context.Context, name, code string) (dto.ClientDescriptor, error) {
+	if name == "" || strings.Contains(name, dto.DataDelimiter) {
+		return dto.ClientDescriptor{}, ErrIncorrectName
+	}
+	bc, err := s.Codes.TakeCode(ctx, code)
+	now := s.now()
+	if err != nil || (bc.Name != "" && bc.Name != name) || (!bc.Expire.IsZero() && !now.Before(bc.Expire)) {
+		return dto.ClientDescriptor{}, ErrInvalidCode
+	}
+	creator, atomic := s.Clients.(dto.IBgClientCreator)
+	if !atomic {
+		if cl, err := s.Clients.GetClient(ctx, name); err == nil && cl.Name != "" && !cl.Revoked {
+			return dto.ClientDescriptor{}, ErrClientExists
+		}
+	}
+	token, err := randomHex(16)
+	if err != nil {
+		return dto.ClientDescriptor{}, err
+	}
+	stored, err := s.store(token, nil)
+	if err != nil {
+		return dto.ClientDescriptor{}, err
+	}
+	cl := dto.ClientDescriptor{Name: name, Token: stored, CreatedDate: now, LastDate: now}
+	if atomic {
+		err = creator.CreateClient(ctx, &cl)
+	} else {
+		err = s.Clients.SaveClient(ctx, &cl)
+	}
+	if err != nil {
+		return dto.ClientDescriptor{}, err
+	}
+	cl.Token = token
+	return cl, nil
+}
+
+// update - изменяет клиента name функцией fn. Если Clients реализует dto.IBgClientUpdater, изменение атомарно,
+// иначе одновременные изменения одного клиента могут затереть друг друга
+func (s *Service) update(ctx context.Context, name string, fn func(cl *dto.ClientDescriptor) error) error {
+	if u, ok := s.Clients.(dto.IBgClientUpdater); ok {
+		return u.UpdateClient(ctx, name, fn)
+	}
+	cl, err := s.Clients.GetClient(ctx, name)
+	if err != nil {
+		return err
+	}
+	if err = fn(&cl); err != nil {
+		return err
+	}
+	return s.Clients.SaveClient(ctx, &cl)
+}
+
+// Rotate - выдает клиенту новый токен (возвращает клиента с открытым новым токеном), старый действует еще Overlap.
+// Токен, который был старым до этой ротации, перестает действовать сразу
+func (s *Service) Rotate(ctx context.Context, name string) (dto.ClientDescriptor, error) {
+	token, err := randomHex(16)
+	if err != nil {
+		return dto.ClientDescriptor{}, err
+	}
+	var res dto.ClientDescriptor
+	err = s.update(ctx, name, func(cl *dto.ClientDescriptor) error {
+		if cl.Revoked {
+			return ErrRevoked
+		}
+		var kdf *dto.TokenKDF
+		if dto.IsTokenHash(cl.Token) {
+			h, err := dto.ParseTokenHash(cl.Token)
+			if err != nil {
+				return err
+			}
+			kdf = &h.TokenKDF
+		} else if s.HashTokens && cl.Token != "" {
+			k, err := dto.NewTokenKDF(s.HashIterations)
+			if err != nil {
+				return err
+			}
+			kdf, cl.Token = &k, k.Hash(cl.Token)
+		}
+		stored, err := s.store(token, kdf)
+		if err != nil {
+			return err
+		}
+		now := s.now()
+		cl.OldToken, cl.OldExpire, cl.Token = cl.Token, now.Add(s.overlap()), stored
+		cl.LastDate = now
+		res = *cl
+		return nil
+	})
+	if err != nil {
+		return dto.ClientDescriptor{}, err
+	}
+	res.Token = token
+	return res, nil
+}
+
+// Revoke - отзывает все токены клиента. Активные соединения клиента закрываются Middleware
+// при следующей проверке активности (не позже чем через ActivityInterval)
+func (s *Service) Revoke(ctx context.Context, name string) error {
+	return s.update(ctx, name, func(cl *dto.ClientDescriptor) error {
+		cl.Revoked, cl.Token, cl.OldToken, cl.OldExpire = true, "", "", time.Time{}
+		return nil
+	})
+}
+
+// Touch - сохраняет время последней активности клиента. Для отозванного клиента вернет ErrRevoked.
+// Если Clients реализует dto.IBgClientUpdater, меняется только LastDate
+func (s *Service) Touch(ctx context.Context, name string) error {
+	if u, ok := s.Clients.(dto.IBgClientUpdater); ok {
+		return u.TouchClient(ctx, name, s.now())
+	}
+	return s.update(ctx, name, func(cl *dto.ClientDescriptor) error {
+		if cl.Revoked {
+			return ErrRevoked
+		}
+		cl.LastDate = s.now()
+		return nil
+	})
+}
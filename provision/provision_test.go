@@ -0,0 +1,332 @@
+package provision
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/blabu/messagesLib/auth"
+	"github.com/blabu/messagesLib/dto"
+	"github.com/blabu/messagesLib/memstore"
+	"github.com/blabu/messagesLib/router"
+)
+
+// testClock - управляемое время, общее для provision и auth
+type testClock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func (c *testClock) now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *testClock) advance(d time.Duration) {
+	c.mu.Lock()
+	c.t = c.t.Add(d)
+	c.mu.Unlock()
+}
+
+// fleet - сервер с регистрацией, авторизацией и обработчиком DataCOMMAND поверх memstore
+type fleet struct {
+	store *memstore.Store
+	prov  *Service
+	mux   *router.Mux
+	clock *testClock
+}
+
+func newFleet(hash bool) *fleet {
+	f := &fleet{store: memstore.New(), clock: &testClock{t: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}}
+	f.prov = &Service{Clients: f.store, Codes: f.store, Now: f.clock.now, HashTokens: hash, HashIterations: 1000}
+	authSvc := &auth.Service{Clients: f.store, Now: f.clock.now, HashTokens: hash, HashIterations: 1000}
+	f.mux = router.NewMux()
+	f.mux.Use(f.prov.Middleware(), authSvc.Middleware())
+	f.mux.HandleFunc(dto.DataCOMMAND, func(ctx context.Context, r *router.Router, msg *dto.Message) error {
+		return r.Reply(ctx, &dto.Message{MessageMetaInf: dto.MessageMetaInf{Command: msg.Command, ID: msg.ID, To: r.Name()}, MessageContent: msg.MessageContent})
+	})
+	return f
+}
+
+// call - отправляет сообщение в соединение r и возвращает ответ (ok false - соединение закрыто без ответа)
+func call(t *testing.T, r *router.Router, cmd uint16, from, data string) (dto.Message, bool) {
+	t.Helper()
+	ctx := context.Background()
+	msg := dto.Message{
+		MessageMetaInf: dto.MessageMetaInf{Command: cmd, From: from},
+		MessageContent: dto.MessageContent{ContentType: "text", Data: []byte(data)},
+	}
+	if err := r.Write(ctx, &msg); err == router.ErrClosed {
+		return dto.Message{}, false
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	var resp dto.Message
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	if err := r.Read(ctx, &resp); err != nil {
+		return dto.Message{}, false
+	}
+	return resp, true
+}
+
+// login - новое соединение, авторизованное токеном token (nil - авторизация не прошла)
+func (f *fleet) login(t *testing.T, name, token string) *router.Router {
+	t.Helper()
+	r := f.mux.NewRouter()
+	resp, _ := call(t, r, dto.AuthCOMMAND, name, "")
+	challenge := string(resp.Data)
+	version := dto.SignatureV2
+	for _, v := range dto.ParseChallenge(challenge) {
+		if v > version {
+			version = v
+		}
+	}
+	data, err := dto.SignAuthData(version, name, challenge, fmt.Sprintf("salt%d", f.clock.now().UnixNano()), token, f.clock.now().Unix())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp, _ = call(t, r, dto.AuthCOMMAND, name, string(data.Encode())); string(resp.Data) != dto.AuthOK {
+		return nil
+	}
+	return r
+}
+
+func errorCode(msg dto.Message) uint16 {
+	if msg.Command != dto.ErrorCOMMAND {
+		return 0
+	}
+	return dto.ParseErrorInfo(msg.Data).Code
+}
+
+func TestProvisioningLifecycle(t *testing.T) {
+	for _, hash := range []bool{false, true} {
+		hash := hash
+		t.Run(fmt.Sprintf("hash=%v", hash), func(t *testing.T) {
+			f := newFleet(hash)
+			ctx := context.Background()
+			code, err := f.prov.IssueCode(ctx, "modem", time.Hour)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, _ := call(t, f.mux.NewRouter(), dto.RegisterCOMMAND, "modem", code)
+			token := string(resp.Data)
+			if resp.Command != dto.RegisterCOMMAND || token == "" {
+				t.Fatalf("Register answer %+v %q", resp.MessageMetaInf, resp.Data)
+			}
+			if stored, _ := f.store.GetClient(ctx, "modem"); dto.IsTokenHash(stored.Token) != hash {
+				t.Fatalf("Stored token %q, hash %v", stored.Token, hash)
+			}
+			if resp, _ = call(t, f.mux.NewRouter(), dto.RegisterCOMMAND, "modem", code); errorCode(resp) != dto.ErrCodeForbidden {
+				t.Fatalf("Reused code answer %+v", resp.MessageMetaInf)
+			}
+
+			r := f.login(t, "modem", token)
+			if r == nil {
+				t.Fatal("Login with registered token failed")
+			}
+			if resp, _ = call(t, r, dto.DataCOMMAND, "modem", "hello"); string(resp.Data) != "hello" || resp.To != "modem" {
+				t.Fatalf("Data answer %+v %q", resp.MessageMetaInf, resp.Data)
+			}
+
+			// Ротация: старый токен действует еще Overlap
+			resp, _ = call(t, r, dto.GenerateCOMMAND, "modem", "")
+			parts := strings.Split(string(resp.Data), dto.DataDelimiter)
+			if len(parts) != 2 || parts[0] != "modem" || parts[1] == token {
+				t.Fatalf("Rotate answer %+v %q", resp.MessageMetaInf, resp.Data)
+			}
+			newToken := parts[1]
+			f.clock.advance(time.Hour)
+			if f.login(t, "modem", token) == nil || f.login(t, "modem", newToken) == nil {
+				t.Fatal("Login during overlap failed")
+			}
+			f.clock.advance(DefaultOverlap)
+			if f.login(t, "modem", token) != nil {
+				t.Fatal("Old token works after overlap")
+			}
+			if f.login(t, "modem", newToken) == nil {
+				t.Fatal("Login with new token failed")
+			}
+
+			// Отзыв закрывает активное соединение и запрещает вход, новый код регистрирует клиента заново
+			if err = f.prov.Revoke(ctx, "modem"); err != nil {
+				t.Fatal(err)
+			}
+			f.clock.advance(DefaultActivityInterval)
+			if _, ok := call(t, r, dto.DataCOMMAND, "modem", "after revoke"); ok {
+				t.Fatal("Revoked connection is still open")
+			}
+			if f.login(t, "modem", newToken) != nil {
+				t.Fatal("Revoked token works")
+			}
+			code, _ = f.prov.IssueCode(ctx, "modem", time.Hour)
+			if resp, _ = call(t, f.mux.NewRouter(), dto.RegisterCOMMAND, "modem", code); f.login(t, "modem", string(resp.Data)) == nil {
+				t.Fatalf("Login after new registration failed: %+v %q", resp.MessageMetaInf, resp.Data)
+			}
+		})
+	}
+}
+
+func TestRegisterCodes(t *testing.T) {
+	f := newFleet(false)
+	ctx := context.Background()
+	named, _ := f.prov.IssueCode(ctx, "modem1", time.Hour)
+	expiring, _ := f.prov.IssueCode(ctx, "", time.Minute)
+	any, _ := f.prov.IssueCode(ctx, "", 0)
+	f.clock.advance(2 * time.Minute)
+	cases := []struct {
+		name, code string
+		err        error
+	}{
+		{"modem2", named, ErrInvalidCode},
+		{"modem2", expiring, ErrInvalidCode},
+		{"bad;name", any, ErrIncorrectName},
+		{"modem2", "unknown", ErrInvalidCode},
+		{"modem2", any, nil},
+	}
+	for _, tc := range cases {
+		if _, err := f.prov.Register(ctx, tc.name, tc.code); err != tc.err {
+			t.Errorf("Register %q: %v, want %v", tc.name, err, tc.err)
+		}
+	}
+	// Код тратится даже при ошибке
+	if _, err := f.prov.Register(ctx, "modem1", named); err != ErrInvalidCode {
+		t.Errorf("Spent code: %v, want ErrInvalidCode", err)
+	}
+}
+
+// barrierStore - хранилище, в котором GetClient возвращает результат, только когда его вызовут n раз (но не позже чем через секунду),
+// чтобы проверки существования клиента гарантированно пересекались
+type barrierStore struct {
+	*memstore.Store
+	arrived chan struct{}
+	all     chan struct{}
+}
+
+func newBarrierStore(s *memstore.Store, n int) *barrierStore {
+	b := &barrierStore{Store: s, arrived: make(chan struct{}, n), all: make(chan struct{})}
+	go func() {
+		for i := 0; i < n; i++ {
+			<-b.arrived
+		}
+		close(b.all)
+	}()
+	return b
+}
+
+func (b *barrierStore) GetClient(ctx context.Context, name string) (dto.ClientDescriptor, error) {
+	cl, err := b.Store.GetClient(ctx, name)
+	b.arrived <- struct{}{}
+	select {
+	case <-b.all:
+	case <-time.After(time.Second):
+	}
+	return cl, err
+}
+
+// TestRegisterRace - одновременная регистрация одного имени разными кодами успешна только один раз
+func TestRegisterRace(t *testing.T) {
+	const n = 20
+	f := newFleet(false)
+	f.prov.Clients = newBarrierStore(f.store, n)
+	ctx := context.Background()
+	codes := make([]string, n)
+	for i := range codes {
+		codes[i], _ = f.prov.IssueCode(ctx, "", 0)
+	}
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	tokens := make(chan string, n)
+	for _, code := range codes {
+		wg.Add(1)
+		go func(code string) {
+			defer wg.Done()
+			cl, err := f.prov.Register(ctx, "modem", code)
+			if err == nil {
+				tokens <- cl.Token
+			}
+			errs <- err
+		}(code)
+	}
+	wg.Wait()
+	close(errs)
+	close(tokens)
+	ok := 0
+	for err := range errs {
+		switch err {
+		case nil:
+			ok++
+		case ErrClientExists:
+		default:
+			t.Fatalf("Register error %v", err)
+		}
+	}
+	if ok != 1 {
+		t.Fatalf("%d registrations succeeded, want 1", ok)
+	}
+	if cl, _ := f.store.GetClient(ctx, "modem"); cl.Token != <-tokens {
+		t.Fatal("Stored token does not belong to the successful registration")
+	}
+}
+
+// pausedStore - хранилище, в котором первый GetClient возвращает результат только после release
+type pausedStore struct {
+	*memstore.Store
+	pause   chan struct{}
+	read    chan struct{}
+	release chan struct{}
+}
+
+func newPausedStore(s *memstore.Store) *pausedStore {
+	p := &pausedStore{Store: s, pause: make(chan struct{}, 1), read: make(chan struct{}), release: make(chan struct{})}
+	p.pause <- struct{}{}
+	return p
+}
+
+func (p *pausedStore) GetClient(ctx context.Context, name string) (dto.ClientDescriptor, error) {
+	cl, err := p.Store.GetClient(ctx, name)
+	select {
+	case <-p.pause:
+		close(p.read)
+		<-p.release
+	default:
+	}
+	return cl, err
+}
+
+// TestTouchDuringRevoke - запись активности, начатая до отзыва, не возвращает отозванные токены
+func TestTouchDuringRevoke(t *testing.T) {
+	f := newFleet(false)
+	ctx := context.Background()
+	code, _ := f.prov.IssueCode(ctx, "modem", 0)
+	if _, err := f.prov.Register(ctx, "modem", code); err != nil {
+		t.Fatal(err)
+	}
+	p := newPausedStore(f.store)
+	f.prov.Clients = p
+	touched := make(chan error, 1)
+	go func() { touched <- f.prov.Touch(ctx, "modem") }()
+	select {
+	case <-p.read:
+	case err := <-touched:
+		touched <- err
+	}
+	select {
+	case <-p.pause:
+	default:
+	}
+	if err := f.prov.Revoke(ctx, "modem"); err != nil {
+		t.Fatal(err)
+	}
+	close(p.release)
+	if err := <-touched; err != nil && err != ErrRevoked {
+		t.Fatal(err)
+	}
+	if cl, _ := f.store.GetClient(ctx, "modem"); !cl.Revoked || cl.Token != "" {
+		t.Fatalf("Client after revoke %+v", cl)
+	}
+}
@@ -0,0 +1,92 @@
+package provision
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/blabu/messagesLib/dto"
+	"github.com/blabu/messagesLib/router"
+)
+
+type activityKey struct{}
+
+// activity - время последнего сохранения активности соединения
+type activity struct {
+	mu   sync.Mutex
+	last time.Time
+}
+
+func reply(req *dto.Message, to string, data string) *dto.Message {
+	return &dto.Message{
+		MessageMetaInf: dto.MessageMetaInf{
+			Command: req.Command,
+			Proto:   req.Proto,
+			ID:      req.ID,
+			To:      to,
+		},
+		MessageContent: dto.MessageContent{ContentType: "text", Data: []byte(data)},
+	}
+}
+
+// errorInfo - ответ клиенту на ошибку операции
+func errorInfo(cmd uint16, err error) *dto.ErrorInfo {
+	code := dto.ErrCodeInternal
+	switch err {
+	case ErrInvalidCode, ErrClientExists, ErrRevoked:
+		code = dto.ErrCodeForbidden
+	case ErrIncorrectName:
+		code = dto.ErrCodeBadRequest
+	}
+	return &dto.ErrorInfo{Code: code, Command: cmd, Message: err.Error()}
+}
+
+// Middleware - регистрация по коду, ротация токена и учет активности авторизованного клиента (смотри описание пакета).
+// Соединение отозванного клиента закрывается
+func (s *Service) Middleware() router.Middleware {
+	return func(next router.HandlerFunc) router.HandlerFunc {
+		return func(ctx context.Context, r *router.Router, msg *dto.Message) error {
+			name := r.Name()
+			switch {
+			case msg.Command == dto.RegisterCOMMAND && len(msg.Data) != 0:
+				cl, err := s.Register(ctx, msg.From, string(msg.Data))
+				if err != nil {
+					return errorInfo(msg.Command, err)
+				}
+				return r.Reply(ctx, reply(msg, cl.Name, cl.Token))
+			case msg.Command == dto.GenerateCOMMAND && name != "":
+				cl, err := s.Rotate(ctx, name)
+				if err != nil {
+					return errorInfo(msg.Command, err)
+				}
+				return r.Reply(ctx, reply(msg, cl.Name, cl.Name+dto.DataDelimiter+cl.Token))
+			}
+			if name != "" {
+				if err := s.touch(ctx, r, name); errors.Is(err, ErrRevoked) {
+					r.Close()
+					return router.ErrClosed
+				}
+			}
+			return next(ctx, r, msg)
+		}
+	}
+}
+
+// touch - сохраняет активность не чаще ActivityInterval для одного соединения
+func (s *Service) touch(ctx context.Context, r *router.Router, name string) error {
+	a, ok := r.Value(activityKey{}).(*activity)
+	if !ok {
+		a = new(activity)
+		r.SetValue(activityKey{}, a)
+	}
+	now := s.now()
+	a.mu.Lock()
+	if !a.last.IsZero() && now.Sub(a.last) < s.activityInterval() {
+		a.mu.Unlock()
+		return nil
+	}
+	a.last = now
+	a.mu.Unlock()
+	return s.Touch(ctx, name)
+}
@@ -0,0 +1,144 @@
+// Package record - запись сессии клиента в файл и ее воспроизведение.
+// Каждое сообщение сохраняется как пакет протокола 1 с направлением и временем от начала сессии.
+//
+// Формат файла: "C2CREC", версия (1 байт), время начала сессии (unix nano, 8 байт little endian),
+// затем записи: направление (1 байт 'I' или 'O'), время от предыдущей записи в наносекундах (uvarint),
+// размер пакета (uvarint), пакет
+package record
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	magic   = "C2CREC"
+	version = 1
+	// MaxFrameSize - максимальный размер пакета в записи
+	MaxFrameSize = 1 << 24
+)
+
+// Direction - направление пакета относительно сервера
+type Direction byte
+
+const (
+	Inbound  Direction = 'I' // От клиента к бизнес логике
+	Outbound Direction = 'O' // От бизнес логики к клиенту
+)
+
+// ErrFormat - файл не является записью сессии или поврежден
+var ErrFormat = errors.New("Incorrect session record format")
+
+// Record - один пакет сессии
+type Record struct {
+	Dir   Direction
+	Time  time.Duration // Время от начала сессии
+	Frame []byte
+}
+
+// Writer - пишет записи сессии
+type Writer struct {
+	mu    sync.Mutex
+	w     *bufio.Writer
+	start time.Time
+	last  time.Duration
+}
+
+// NewWriter - пишет заголовок записи в w, время начала сессии - текущее
+func NewWriter(w io.Writer) (*Writer, error) {
+	rw := &Writer{w: bufio.NewWriter(w), start: time.Now()}
+	head := make([]byte, len(magic)+1+8)
+	copy(head, magic)
+	head[len(magic)] = version
+	binary.LittleEndian.PutUint64(head[len(magic)+1:], uint64(rw.start.UnixNano()))
+	if _, err := rw.w.Write(head); err != nil {
+		return nil, err
+	}
+	return rw, rw.w.Flush()
+}
+
+// Start - время начала сессии
+func (rw *Writer) Start() time.Time {
+	return rw.start
+}
+
+// Write - добавляет пакет frame с направлением dir и текущим временем
+func (rw *Writer) Write(dir Direction, frame []byte) error {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	now := time.Since(rw.start)
+	if now < rw.last {
+		now = rw.last
+	}
+	var buf [1 + 2*binary.MaxVarintLen64]byte
+	buf[0] = byte(dir)
+	n := 1 + binary.PutUvarint(buf[1:], uint64(now-rw.last))
+	n += binary.PutUvarint(buf[n:], uint64(len(frame)))
+	rw.last = now
+	if _, err := rw.w.Write(buf[:n]); err != nil {
+		return err
+	}
+	if _, err := rw.w.Write(frame); err != nil {
+		return err
+	}
+	return rw.w.Flush()
+}
+
+// Reader - читает записи сессии
+type Reader struct {
+	r     *bufio.Reader
+	start time.Time
+	last  time.Duration
+}
+
+// NewReader - читает и проверяет заголовок записи
+func NewReader(r io.Reader) (*Reader, error) {
+	rr := &Reader{r: bufio.NewReader(r)}
+	head := make([]byte, len(magic)+1+8)
+	if _, err := io.ReadFull(rr.r, head); err != nil {
+		return nil, ErrFormat
+	}
+	if string(head[:len(magic)]) != magic {
+		return nil, ErrFormat
+	}
+	if head[len(magic)] != version {
+		return nil, fmt.Errorf("Unsupported session record version %d", head[len(magic)])
+	}
+	rr.start = time.Unix(0, int64(binary.LittleEndian.Uint64(head[len(magic)+1:])))
+	return rr, nil
+}
+
+// Start - время начала сессии
+func (rr *Reader) Start() time.Time {
+	return rr.start
+}
+
+// Next - следующая запись, в конце файла возвращает io.EOF
+func (rr *Reader) Next() (Record, error) {
+	dir, err := rr.r.ReadByte()
+	if err != nil {
+		return Record{}, err
+	}
+	if Direction(dir) != Inbound && Direction(dir) != Outbound {
+		return Record{}, ErrFormat
+	}
+	delta, err := binary.ReadUvarint(rr.r)
+	if err != nil {
+		return Record{}, ErrFormat
+	}
+	size, err := binary.ReadUvarint(rr.r)
+	if err != nil || size > MaxFrameSize {
+		return Record{}, ErrFormat
+	}
+	frame := make([]byte, size)
+	if _, err = io.ReadFull(rr.r, frame); err != nil {
+		return Record{}, ErrFormat
+	}
+	rr.last += time.Duration(delta)
+	return Record{Dir: Direction(dir), Time: rr.last, Frame: frame}, nil
+}
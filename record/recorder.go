@@ -0,0 +1,66 @@
+package record
+
+import (
+	"context"
+
+	"github.com/blabu/messagesLib/dto"
+	"github.com/blabu/messagesLib/parser"
+)
+
+// Recorder - обертка бизнес логики, которая записывает все входящие (Write) и исходящие (Read) сообщения.
+// Например для записи сессий сервера:
+//
+//	srv.Handler = func(ctx context.Context, peer *server.Peer) (dto.ReadWriteCloser, error) {
+//		rwc, err := handler(ctx, peer)
+//		...
+//		return record.NewRecorder(rwc, w)
+//	}
+type Recorder struct {
+	rwc     dto.ReadWriteCloser
+	w       *Writer
+	parser  parser.IParser
+	OnError func(err error) // Вызывается при ошибке записи, сообщение все равно передается дальше (nil - ошибка игнорируется)
+}
+
+// emptyContentType - тип, с которым записывается сообщение без ContentType (в пакете тип обязателен)
+const emptyContentType = "binary"
+
+// NewRecorder - записывает сессию rwc в w
+func NewRecorder(rwc dto.ReadWriteCloser, w *Writer) *Recorder {
+	return &Recorder{rwc: rwc, w: w, parser: parser.CreateEmptyParser(MaxFrameSize)}
+}
+
+// record - записывает копию сообщения, сообщение бизнес логики не меняется
+func (r *Recorder) record(dir Direction, msg *dto.Message) {
+	m := *msg
+	if m.ContentType == "" {
+		m.ContentType = emptyContentType
+	}
+	frame, err := r.parser.FormMessage(&m)
+	if err == nil {
+		err = r.w.Write(dir, frame)
+	}
+	if err != nil && r.OnError != nil {
+		r.OnError(err)
+	}
+}
+
+// Write - записывает входящее сообщение и передает его бизнес логике
+func (r *Recorder) Write(ctx context.Context, msg *dto.Message) error {
+	r.record(Inbound, msg)
+	return r.rwc.Write(ctx, msg)
+}
+
+// Read - читает ответ бизнес логики и записывает его
+func (r *Recorder) Read(ctx context.Context, msg *dto.Message) error {
+	if err := r.rwc.Read(ctx, msg); err != nil {
+		return err
+	}
+	r.record(Outbound, msg)
+	return nil
+}
+
+// Close - закрывает бизнес логику
+func (r *Recorder) Close() error {
+	return r.rwc.Close()
+}
@@ -0,0 +1,78 @@
+package record
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/blabu/messagesLib/dto"
+)
+
+// echo - бизнес логика, которая отвечает на каждое сообщение его копией без ContentType
+type echo struct {
+	out chan dto.Message
+}
+
+func newEcho() *echo {
+	return &echo{out: make(chan dto.Message, 16)}
+}
+
+func (e *echo) Write(ctx context.Context, msg *dto.Message) error {
+	resp := *msg
+	resp.From, resp.To, resp.ContentType = msg.To, msg.From, ""
+	e.out <- resp
+	return nil
+}
+
+func (e *echo) Read(ctx context.Context, msg *dto.Message) error {
+	select {
+	case m := <-e.out:
+		*msg = m
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (e *echo) Close() error { return nil }
+
+// TestRecorderKeepsMessage - запись не меняет сообщения бизнес логики и не падает на пустом ContentType,
+// а записанная сессия воспроизводится без отличий
+func TestRecorderKeepsMessage(t *testing.T) {
+	var file bytes.Buffer
+	w, err := NewWriter(&file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := NewRecorder(newEcho(), w)
+	rec.OnError = func(err error) { t.Errorf("Record error: %v", err) }
+	ctx := context.Background()
+	inputs := []dto.Message{
+		{MessageMetaInf: dto.MessageMetaInf{Command: dto.DataCOMMAND, ID: 1, From: "modem", To: "server"}, MessageContent: dto.MessageContent{ContentType: "text", Data: []byte("hello")}},
+		{MessageMetaInf: dto.MessageMetaInf{Command: dto.PingCOMMAND, ID: 2, From: "modem"}},
+	}
+	for i := range inputs {
+		msg := inputs[i]
+		if err = rec.Write(ctx, &msg); err != nil {
+			t.Fatal(err)
+		}
+		if msg.Proto != 0 || msg.ContentType != inputs[i].ContentType {
+			t.Fatalf("Inbound message %d is changed: %+v", i, msg.MessageMetaInf)
+		}
+		var resp dto.Message
+		if err = rec.Read(ctx, &resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Proto != 0 || resp.ContentType != "" {
+			t.Fatalf("Outbound message %d is changed: %+v %q", i, resp.MessageMetaInf, resp.ContentType)
+		}
+	}
+	res, err := (&Replayer{Timeout: 100 * time.Millisecond}).Replay(ctx, &file, newEcho())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.OK() || res.Inbound != 2 || res.Matched != 2 {
+		t.Fatalf("Replay result %+v", res)
+	}
+}
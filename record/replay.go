@@ -0,0 +1,194 @@
+package record
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/blabu/messagesLib/dto"
+	"github.com/blabu/messagesLib/parser"
+)
+
+// DefaultReplayTimeout - сколько ждать ответ бизнес логики при воспроизведении
+const DefaultReplayTimeout = 5 * time.Second
+
+// Replayer - воспроизводит записанную сессию: входящие сообщения передаются в Write бизнес логики,
+// ответы, полученные через Read, сравниваются с записанными исходящими сообщениями по порядку
+type Replayer struct {
+	Speed   float64                           // Ускорение относительно записи: 1 - как в записи, 0 - без пауз
+	Timeout time.Duration                     // Ожидание каждого ответа (0 - DefaultReplayTimeout)
+	Compare func(want, got *dto.Message) bool // Сравнение ответов (nil - SameMessage)
+}
+
+// Mismatch - ответ бизнес логики, отличающийся от записанного
+type Mismatch struct {
+	Index int // Номер исходящего сообщения в записи (с нуля)
+	Want  dto.Message
+	Got   dto.Message
+}
+
+func (m *Mismatch) String() string {
+	return fmt.Sprintf("outbound #%d: want %s from %q to %q data %q, got %s from %q to %q data %q",
+		m.Index, dto.CommandName(m.Want.Command), m.Want.From, m.Want.To, m.Want.Data,
+		dto.CommandName(m.Got.Command), m.Got.From, m.Got.To, m.Got.Data)
+}
+
+// Result - итог воспроизведения
+type Result struct {
+	Inbound    int           // Передано входящих сообщений
+	Outbound   int           // Записано исходящих сообщений
+	Matched    int           // Совпавших ответов
+	Mismatches []Mismatch    // Отличающиеся ответы
+	Missing    int           // Записанные ответы, которых бизнес логика не вернула за Timeout
+	Unexpected []dto.Message // Ответы после конца записи
+}
+
+// OK - все ответы совпали с записью
+func (res *Result) OK() bool {
+	return len(res.Mismatches) == 0 && res.Missing == 0 && len(res.Unexpected) == 0
+}
+
+// SameMessage - сравнение по полям, которые передаются в пакете (пустой ContentType записывается как binary)
+func SameMessage(want, got *dto.Message) bool {
+	return want.Command == got.Command && want.ID == got.ID &&
+		want.From == got.From && want.To == got.To && want.Channel == got.Channel &&
+		contentType(want) == contentType(got) && bytes.Equal(want.Data, got.Data)
+}
+
+func contentType(msg *dto.Message) string {
+	if msg.ContentType == "" {
+		return emptyContentType
+	}
+	return msg.ContentType
+}
+
+func (rp *Replayer) timeout() time.Duration {
+	if rp.Timeout <= 0 {
+		return DefaultReplayTimeout
+	}
+	return rp.Timeout
+}
+
+type readResult struct {
+	msg dto.Message
+	err error
+}
+
+// Replay - воспроизводит сессию из r в бизнес логике rwc и закрывает ее.
+// Ошибка возвращается только если запись повреждена или бизнес логика вернула ошибку,
+// отличия ответов описываются в Result
+func (rp *Replayer) Replay(ctx context.Context, r io.Reader, rwc dto.ReadWriteCloser) (*Result, error) {
+	rr, err := NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	compare := rp.Compare
+	if compare == nil {
+		compare = SameMessage
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	outputs := make(chan readResult, 1)
+	go func() {
+		defer close(outputs)
+		for {
+			var msg dto.Message
+			err := rwc.Read(ctx, &msg)
+			select {
+			case outputs <- readResult{msg, err}:
+			case <-ctx.Done():
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	defer rwc.Close()
+
+	p := parser.CreateEmptyParser(MaxFrameSize)
+	res := &Result{}
+	start := time.Now()
+	for {
+		rec, err := rr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return res, err
+		}
+		msg, err := p.ParseMessage(rec.Frame)
+		if err != nil {
+			return res, fmt.Errorf("Record at %v: %v", rec.Time, err)
+		}
+		if rec.Dir == Outbound {
+			got, err := rp.next(ctx, outputs)
+			if err != nil {
+				return res, err
+			}
+			if got == nil {
+				res.Missing++
+			} else if !compare(&msg, got) {
+				res.Mismatches = append(res.Mismatches, Mismatch{Index: res.Outbound, Want: msg, Got: *got})
+			} else {
+				res.Matched++
+			}
+			res.Outbound++
+			continue
+		}
+		if rp.Speed > 0 {
+			wait := time.Duration(float64(rec.Time)/rp.Speed) - time.Since(start)
+			if err = sleep(ctx, wait); err != nil {
+				return res, err
+			}
+		}
+		if err = rwc.Write(ctx, &msg); err != nil {
+			return res, err
+		}
+		res.Inbound++
+	}
+	for {
+		got, err := rp.next(ctx, outputs)
+		if err != nil || got == nil {
+			return res, err
+		}
+		res.Unexpected = append(res.Unexpected, *got)
+	}
+}
+
+// next - следующий ответ бизнес логики, nil если его нет за Timeout или бизнес логика завершила работу (io.EOF)
+func (rp *Replayer) next(ctx context.Context, outputs chan readResult) (*dto.Message, error) {
+	t := time.NewTimer(rp.timeout())
+	defer t.Stop()
+	select {
+	case out, ok := <-outputs:
+		if !ok || errors.Is(out.err, io.EOF) {
+			return nil, nil
+		}
+		if out.err != nil {
+			return nil, out.err
+		}
+		return &out.msg, nil
+	case <-t.C:
+		return nil, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,25 @@
+package router
+
+import (
+	"context"
+
+	"github.com/blabu/messagesLib/dto"
+)
+
+// Identity - привязывает From сообщений к имени авторизованного соединения (Router.Name) по политике policy
+// (dto.IdentityReject или dto.IdentityRewrite). Боты и шлюзы могут писать от имени других клиентов
+// по делегированиям d (nil - без делегирования). До авторизации сообщения не проверяются.
+// Ставится после middleware авторизации
+func Identity(policy int, d dto.IBgDelegation) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, r *Router, msg *dto.Message) error {
+			if err := dto.BindIdentity(ctx, r.Name(), msg, policy, d); err != nil {
+				if err == dto.ErrIdentityMismatch {
+					return &dto.ErrorInfo{Code: dto.ErrCodeForbidden, Command: msg.Command, Message: err.Error()}
+				}
+				return err
+			}
+			return next(ctx, r, msg)
+		}
+	}
+}
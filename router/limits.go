@@ -0,0 +1,203 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/blabu/messagesLib/dto"
+)
+
+// DefaultLimitsRefresh - как часто Limiter перечитывает ограничения клиента из хранилища
+const DefaultLimitsRefresh = time.Minute
+
+// limiterIdle - через сколько неиспользования состояние клиента удаляется (за это время сутки квоты уже закончились)
+const limiterIdle = 25 * time.Hour
+
+// clientLimits - состояние ограничений одного клиента
+type clientLimits struct {
+	mu       sync.Mutex
+	limits   dto.ClientLimits
+	loaded   time.Time
+	total    tokenBucket
+	commands map[uint16]*tokenBucket
+	day      string // Сутки (UTC), к которым относится bytes
+	bytes    int64
+	used     time.Time // Защищено Limiter.mu
+}
+
+// Limiter - ограничения частоты сообщений и суточной квоты данных по имени клиента (Router.Name),
+// общие для всех соединений клиента. Ограничения берутся из ClientDescriptor.Limits,
+// если их нет - из Default. Сообщения до авторизации не ограничиваются (для них есть RateLimit)
+type Limiter struct {
+	Clients dto.IBgClientSaver // Источник ClientDescriptor.Limits (nil - для всех Default)
+	Default dto.ClientLimits
+	Refresh time.Duration    // 0 - DefaultLimitsRefresh
+	Now     func() time.Time // nil - time.Now
+
+	mu      sync.Mutex
+	clients map[string]*clientLimits
+	calls   int
+}
+
+func (l *Limiter) now() time.Time {
+	if l.Now == nil {
+		return time.Now()
+	}
+	return l.Now()
+}
+
+func (l *Limiter) refresh() time.Duration {
+	if l.Refresh <= 0 {
+		return DefaultLimitsRefresh
+	}
+	return l.Refresh
+}
+
+// state - состояние клиента name, при необходимости перечитывает его ограничения.
+// Хранилище читается без блокировок, чтобы медленный GetClient не задерживал проверки других клиентов
+func (l *Limiter) state(ctx context.Context, name string, now time.Time) *clientLimits {
+	l.mu.Lock()
+	if l.clients == nil {
+		l.clients = make(map[string]*clientLimits)
+	}
+	l.calls++
+	if l.calls%1024 == 0 {
+		for n, c := range l.clients {
+			if now.Sub(c.used) > limiterIdle {
+				delete(l.clients, n)
+			}
+		}
+	}
+	c, ok := l.clients[name]
+	if !ok {
+		c = &clientLimits{}
+		l.clients[name] = c
+	}
+	c.used = now
+	l.mu.Unlock()
+
+	c.mu.Lock()
+	stale := c.loaded.IsZero() || now.Sub(c.loaded) >= l.refresh()
+	c.mu.Unlock()
+	if !stale {
+		return c
+	}
+	limits := l.Default
+	if l.Clients != nil {
+		if cl, err := l.Clients.GetClient(ctx, name); err == nil && cl.Limits != nil {
+			limits = *cl.Limits
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !sameLimits(&limits, &c.limits) {
+		c.commands = nil // Новые ограничения команд начинаются с полного ведра
+	}
+	c.limits, c.loaded = limits, now
+	return c
+}
+
+// sameLimits - ограничения не изменились (тогда состояние ведер сохраняется при перечитывании)
+func sameLimits(a, b *dto.ClientLimits) bool {
+	if a.Rate != b.Rate || a.Burst != b.Burst || a.DailyBytes != b.DailyBytes || len(a.Commands) != len(b.Commands) {
+		return false
+	}
+	for i := range a.Commands {
+		if a.Commands[i] != b.Commands[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Allow - проверяет сообщение клиента name с командой cmd и size байт данных.
+// Возвращает nil или ошибку с кодом dto.ErrCodeRateLimited или dto.ErrCodeQuotaExceeded
+func (l *Limiter) Allow(ctx context.Context, name string, cmd uint16, size int) *dto.ErrorInfo {
+	now := l.now()
+	c := l.state(ctx, name, now)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	lim := &c.limits
+	if lim.DailyBytes > 0 {
+		if day := now.UTC().Format("2006-01-02"); day != c.day {
+			c.day, c.bytes = day, 0
+		}
+		if c.bytes+int64(size) > lim.DailyBytes {
+			return &dto.ErrorInfo{Code: dto.ErrCodeQuotaExceeded, Command: cmd,
+				Message: fmt.Sprintf("Daily quota of %d bytes exceeded", lim.DailyBytes)}
+		}
+	}
+	for i := range lim.Commands {
+		cl := &lim.Commands[i]
+		if cl.Command != cmd || cl.Rate <= 0 {
+			continue
+		}
+		if c.commands == nil {
+			c.commands = make(map[uint16]*tokenBucket)
+		}
+		b, ok := c.commands[cmd]
+		if !ok {
+			b = new(tokenBucket)
+			c.commands[cmd] = b
+		}
+		if !b.take(cl.Rate, burst(cl.Burst, cl.Rate), now) {
+			return &dto.ErrorInfo{Code: dto.ErrCodeRateLimited, Command: cmd,
+				Message: fmt.Sprintf("Too many %s messages", commandName(cmd))}
+		}
+	}
+	if lim.Rate > 0 && !c.total.take(lim.Rate, burst(lim.Burst, lim.Rate), now) {
+		return &dto.ErrorInfo{Code: dto.ErrCodeRateLimited, Command: cmd, Message: "Too many messages"}
+	}
+	c.bytes += int64(size)
+	return nil
+}
+
+// Usage - сколько байт данных клиент name отправил за текущие сутки
+func (l *Limiter) Usage(name string) int64 {
+	l.mu.Lock()
+	c, ok := l.clients[name]
+	l.mu.Unlock()
+	if !ok {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.day != l.now().UTC().Format("2006-01-02") {
+		return 0
+	}
+	return c.bytes
+}
+
+// burst - размер ведра, если не задан - одна секунда сообщений, но не меньше одного
+func burst(b int, rate float64) int {
+	if b > 0 {
+		return b
+	}
+	if rate < 1 {
+		return 1
+	}
+	return int(rate)
+}
+
+func commandName(cmd uint16) string {
+	if name := dto.CommandName(cmd); name != "" {
+		return name
+	}
+	return fmt.Sprintf("command %d", cmd)
+}
+
+// Middleware - отклоняет сообщения авторизованного клиента сверх его ограничений
+func (l *Limiter) Middleware() Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, r *Router, msg *dto.Message) error {
+			if name := r.Name(); name != "" {
+				if info := l.Allow(ctx, name, msg.Command, len(msg.Data)); info != nil {
+					return info
+				}
+			}
+			return next(ctx, r, msg)
+		}
+	}
+}
@@ -0,0 +1,147 @@
+package router
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/blabu/messagesLib/dto"
+)
+
+// testClients - хранилище клиентов для тестов
+type testClients map[string]dto.ClientDescriptor
+
+func (c testClients) GetClient(ctx context.Context, name string) (dto.ClientDescriptor, error) {
+	cl, ok := c[name]
+	if !ok {
+		return cl, errors.New("Not found")
+	}
+	return cl, nil
+}
+
+func (c testClients) SaveClient(ctx context.Context, cl *dto.ClientDescriptor) error {
+	c[cl.Name] = *cl
+	return nil
+}
+
+func (c testClients) GenerateClient(ctx context.Context, name string) (dto.ClientDescriptor, error) {
+	return dto.ClientDescriptor{}, errors.New("Not supported")
+}
+
+// testClock - управляемое время
+type testClock struct{ t time.Time }
+
+func (c *testClock) now() time.Time          { return c.t }
+func (c *testClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+// allowed - сколько из n сообщений пропускает Limiter
+func allowed(l *Limiter, name string, cmd uint16, size, n int) int {
+	res := 0
+	for i := 0; i < n; i++ {
+		if l.Allow(context.Background(), name, cmd, size) == nil {
+			res++
+		}
+	}
+	return res
+}
+
+func TestLimiterCommandRate(t *testing.T) {
+	clock := &testClock{t: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	clients := testClients{"modem": {Name: "modem", Limits: &dto.ClientLimits{
+		Commands: []dto.CommandLimit{{Command: dto.DataCOMMAND, Rate: 1, Burst: 2}},
+	}}}
+	l := &Limiter{Clients: clients, Refresh: time.Second, Now: clock.now}
+	if n := allowed(l, "modem", dto.DataCOMMAND, 0, 5); n != 2 {
+		t.Fatalf("Burst: %d allowed, want 2", n)
+	}
+	if n := allowed(l, "modem", dto.PingCOMMAND, 0, 5); n != 5 {
+		t.Fatalf("Other command: %d allowed, want 5", n)
+	}
+	// Перечитывание тех же ограничений не дает новый полный burst
+	for i := 0; i < 5; i++ {
+		clock.advance(time.Second)
+		if n := allowed(l, "modem", dto.DataCOMMAND, 0, 5); n != 1 {
+			t.Fatalf("Refresh %d: %d allowed, want 1", i, n)
+		}
+	}
+	// Новые ограничения начинаются с полного ведра
+	clients["modem"] = dto.ClientDescriptor{Name: "modem", Limits: &dto.ClientLimits{
+		Commands: []dto.CommandLimit{{Command: dto.DataCOMMAND, Rate: 1, Burst: 4}},
+	}}
+	clock.advance(time.Second)
+	if n := allowed(l, "modem", dto.DataCOMMAND, 0, 10); n != 4 {
+		t.Fatalf("Changed limits: %d allowed, want 4", n)
+	}
+}
+
+func TestLimiterTotalRateAndDefault(t *testing.T) {
+	clock := &testClock{t: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	l := &Limiter{Default: dto.ClientLimits{Rate: 2}, Refresh: time.Second, Now: clock.now}
+	if n := allowed(l, "a", dto.DataCOMMAND, 0, 10); n != 2 {
+		t.Fatalf("%d allowed, want 2", n)
+	}
+	if n := allowed(l, "b", dto.DataCOMMAND, 0, 10); n != 2 {
+		t.Fatalf("Other client: %d allowed, want 2", n)
+	}
+	clock.advance(5 * time.Second)
+	if n := allowed(l, "a", dto.DataCOMMAND, 0, 10); n != 2 {
+		t.Fatalf("After pause: %d allowed, want 2", n)
+	}
+}
+
+func TestLimiterDailyQuota(t *testing.T) {
+	clock := &testClock{t: time.Date(2024, 1, 1, 23, 0, 0, 0, time.UTC)}
+	l := &Limiter{Default: dto.ClientLimits{DailyBytes: 100}, Now: clock.now}
+	if n := allowed(l, "a", dto.DataCOMMAND, 30, 5); n != 3 {
+		t.Fatalf("%d allowed, want 3", n)
+	}
+	info := l.Allow(context.Background(), "a", dto.DataCOMMAND, 30)
+	if info == nil || info.Code != dto.ErrCodeQuotaExceeded {
+		t.Fatalf("Expected quota error, got %v", info)
+	}
+	if u := l.Usage("a"); u != 90 {
+		t.Fatalf("Usage %d, want 90", u)
+	}
+	clock.advance(2 * time.Hour) // Новые сутки UTC
+	if u := l.Usage("a"); u != 0 {
+		t.Fatalf("Usage after midnight %d, want 0", u)
+	}
+	if n := allowed(l, "a", dto.DataCOMMAND, 30, 5); n != 3 {
+		t.Fatalf("Next day: %d allowed, want 3", n)
+	}
+}
+
+// slowClients - хранилище, в котором GetClient клиента "slow" ждет release
+type slowClients struct {
+	testClients
+	reading chan struct{}
+	release chan struct{}
+}
+
+func (c slowClients) GetClient(ctx context.Context, name string) (dto.ClientDescriptor, error) {
+	if name == "slow" {
+		close(c.reading)
+		<-c.release
+	}
+	return c.testClients.GetClient(ctx, name)
+}
+
+// TestLimiterSlowStore - медленное чтение ограничений одного клиента не задерживает проверки других
+func TestLimiterSlowStore(t *testing.T) {
+	clients := slowClients{testClients: testClients{}, reading: make(chan struct{}), release: make(chan struct{})}
+	l := &Limiter{Clients: clients}
+	go l.Allow(context.Background(), "slow", dto.DataCOMMAND, 0)
+	<-clients.reading
+	done := make(chan int)
+	go func() { done <- allowed(l, "modem", dto.DataCOMMAND, 0, 3000) }()
+	select {
+	case n := <-done:
+		if n != 3000 {
+			t.Fatalf("%d allowed, want 3000", n)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Checks of other clients wait for the store")
+	}
+	close(clients.release)
+}
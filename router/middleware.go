@@ -0,0 +1,114 @@
+package router
+
+import (
+	"context"
+	"log"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/blabu/messagesLib/dto"
+)
+
+func logf(l *log.Logger, format string, args ...interface{}) {
+	if l != nil {
+		l.Printf(format, args...)
+		return
+	}
+	log.Printf(format, args...)
+}
+
+// Recovery - перехватывает панику обработчика, пишет ее в лог и отвечает клиенту ErrCodeInternal (l nil - стандартный логгер)
+func Recovery(l *log.Logger) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, r *Router, msg *dto.Message) (err error) {
+			defer func() {
+				if p := recover(); p != nil {
+					logf(l, "Panic in handler of %s from %s: %v\n%s", dto.CommandName(msg.Command), msg.From, p, debug.Stack())
+					err = &dto.ErrorInfo{Code: dto.ErrCodeInternal, Command: msg.Command, Message: "Internal error"}
+				}
+			}()
+			return next(ctx, r, msg)
+		}
+	}
+}
+
+// Logging - пишет в лог каждую команду, время ее обработки и ошибку (l nil - стандартный логгер)
+func Logging(l *log.Logger) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, r *Router, msg *dto.Message) error {
+			start := time.Now()
+			err := next(ctx, r, msg)
+			name := commandName(msg.Command)
+			if err != nil {
+				logf(l, "%s from %q (%s) %d bytes: %v in %v", name, msg.From, r.Name(), len(msg.Data), err, time.Since(start))
+			} else {
+				logf(l, "%s from %q (%s) %d bytes: ok in %v", name, msg.From, r.Name(), len(msg.Data), time.Since(start))
+			}
+			return err
+		}
+	}
+}
+
+// RequireAuth - пропускает команды только после авторизации (Router.Name не пустой), кроме команд public
+func RequireAuth(public ...uint16) Middleware {
+	allowed := make(map[uint16]bool, len(public))
+	for _, cmd := range public {
+		allowed[cmd] = true
+	}
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, r *Router, msg *dto.Message) error {
+			if allowed[msg.Command] || r.Name() != "" {
+				return next(ctx, r, msg)
+			}
+			return &dto.ErrorInfo{Code: dto.ErrCodeUnauthorized, Command: msg.Command, Message: "Authorization required"}
+		}
+	}
+}
+
+// tokenBucket - ограничение частоты, rate токенов в секунду, не больше burst
+type tokenBucket struct {
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func (b *tokenBucket) take(rate float64, burst int, now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.last.IsZero() {
+		b.tokens = float64(burst)
+	} else {
+		b.tokens += now.Sub(b.last).Seconds() * rate
+		if b.tokens > float64(burst) {
+			b.tokens = float64(burst)
+		}
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// RateLimit - не больше rate сообщений в секунду на соединение с накоплением до burst,
+// лишние сообщения отклоняются с кодом ErrCodeRateLimited
+func RateLimit(rate float64, burst int) Middleware {
+	key := new(int) // Уникальный ключ состояния этого middleware в Router
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, r *Router, msg *dto.Message) error {
+			r.mu.Lock()
+			b, ok := r.values[key].(*tokenBucket)
+			if !ok {
+				b = new(tokenBucket)
+				r.values[key] = b
+			}
+			r.mu.Unlock()
+			if !b.take(rate, burst, time.Now()) {
+				return &dto.ErrorInfo{Code: dto.ErrCodeRateLimited, Command: msg.Command, Message: "Too many messages"}
+			}
+			return next(ctx, r, msg)
+		}
+	}
+}
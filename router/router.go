@@ -0,0 +1,230 @@
+// Package router - бизнес логика соединения, собранная из обработчиков отдельных команд.
+// Mux хранит обработчики и middleware и создается один раз,
+// для каждого соединения Mux.NewRouter создает Router, который реализует dto.ReadWriteCloser:
+//
+//	mux := router.NewMux()
+//	mux.Use(router.Recovery(logger), router.Logging(logger))
+//	mux.HandleFunc(dto.PingCOMMAND, ping)
+//	srv.Handler = func(ctx context.Context, peer *server.Peer) (dto.ReadWriteCloser, error) {
+//		return mux.NewRouter(), nil
+//	}
+package router
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/blabu/messagesLib/dto"
+)
+
+// ReplyQueueSize - сколько ответов может ждать отправки, после этого Reply блокируется
+const ReplyQueueSize = 64
+
+// ErrClosed - соединение закрыто
+var ErrClosed = errors.New("Router closed")
+
+// HandlerFunc - обработчик команды. Ответы отправляются через r.Reply.
+// Возвращенная ошибка отправляется клиенту как ErrorCOMMAND (*dto.ErrorInfo как есть, остальные с кодом ErrCodeInternal)
+type HandlerFunc func(ctx context.Context, r *Router, msg *dto.Message) error
+
+// Middleware - обертка обработчика (авторизация, логирование, ограничения и т.д.)
+type Middleware func(next HandlerFunc) HandlerFunc
+
+// Mux - обработчики команд и общие для всех команд middleware
+type Mux struct {
+	mu       sync.RWMutex
+	handlers map[uint16]HandlerFunc
+	chain    []Middleware
+	notFound HandlerFunc
+	onClose  []func(r *Router)
+}
+
+// NewMux - создает пустой Mux, на неизвестные команды отвечает ErrorCOMMAND с кодом ErrCodeUnknownCommand
+func NewMux() *Mux {
+	return &Mux{handlers: make(map[uint16]HandlerFunc), notFound: unknownCommand}
+}
+
+func unknownCommand(ctx context.Context, r *Router, msg *dto.Message) error {
+	return &dto.ErrorInfo{Code: dto.ErrCodeUnknownCommand, Command: msg.Command, Message: "Unsupported command"}
+}
+
+// HandleFunc - регистрирует обработчик команды cmd, middleware mw применяются только к нему (после общих)
+func (m *Mux) HandleFunc(cmd uint16, h HandlerFunc, mw ...Middleware) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.handlers[cmd] = wrap(h, mw)
+}
+
+// NotFound - обработчик команд без зарегистрированного обработчика
+func (m *Mux) NotFound(h HandlerFunc) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.notFound = h
+}
+
+// Use - добавляет middleware для всех команд (включая неизвестные), первый добавленный вызывается первым
+func (m *Mux) Use(mw ...Middleware) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.chain = append(m.chain, mw...)
+}
+
+// OnClose - функция, которая вызывается при закрытии каждого Router (разрыв соединения)
+func (m *Mux) OnClose(f func(r *Router)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onClose = append(m.onClose, f)
+}
+
+func wrap(h HandlerFunc, mw []Middleware) HandlerFunc {
+	for i := len(mw) - 1; i >= 0; i-- {
+		h = mw[i](h)
+	}
+	return h
+}
+
+// handler - обработчик команды cmd вместе с общими middleware
+func (m *Mux) handler(cmd uint16) HandlerFunc {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	h, ok := m.handlers[cmd]
+	if !ok {
+		h = m.notFound
+	}
+	return wrap(h, m.chain)
+}
+
+// Serve - обрабатывает сообщение msg в соединении r
+func (m *Mux) Serve(ctx context.Context, r *Router, msg *dto.Message) error {
+	return m.handler(msg.Command)(ctx, r, msg)
+}
+
+// NewRouter - бизнес логика одного соединения
+func (m *Mux) NewRouter() *Router {
+	return &Router{
+		mux:    m,
+		out:    make(chan dto.Message, ReplyQueueSize),
+		closed: make(chan struct{}),
+		values: make(map[interface{}]interface{}),
+	}
+}
+
+// Router - состояние одного соединения, реализует dto.ReadWriteCloser.
+// Write вызывает обработчик команды, Read возвращает ответы обработчиков
+type Router struct {
+	mux       *Mux
+	out       chan dto.Message
+	closed    chan struct{}
+	closeOnce sync.Once
+
+	mu     sync.RWMutex
+	name   string
+	values map[interface{}]interface{}
+}
+
+// Name - имя авторизованного клиента, пусто до авторизации
+func (r *Router) Name() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.name
+}
+
+// SetName - запоминает имя клиента после успешной авторизации
+func (r *Router) SetName(name string) {
+	r.mu.Lock()
+	r.name = name
+	r.mu.Unlock()
+}
+
+// Value - значение, сохраненное обработчиками или middleware этого соединения
+func (r *Router) Value(key interface{}) interface{} {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.values[key]
+}
+
+// SetValue - сохраняет значение для этого соединения
+func (r *Router) SetValue(key, val interface{}) {
+	r.mu.Lock()
+	r.values[key] = val
+	r.mu.Unlock()
+}
+
+// Write - передает сообщение клиента обработчику его команды.
+// Ошибка обработчика отправляется клиенту как ErrorCOMMAND, соединение при этом не закрывается
+func (r *Router) Write(ctx context.Context, msg *dto.Message) error {
+	select {
+	case <-r.closed:
+		return ErrClosed
+	default:
+	}
+	err := r.mux.Serve(ctx, r, msg)
+	if err == nil {
+		return nil
+	}
+	if errors.Is(err, ErrClosed) {
+		return err
+	}
+	return r.ReplyError(ctx, msg, err)
+}
+
+// ReplyError - отвечает на msg ErrorCOMMAND. *dto.ErrorInfo отправляется как есть, остальные ошибки с кодом ErrCodeInternal
+func (r *Router) ReplyError(ctx context.Context, msg *dto.Message, err error) error {
+	var info *dto.ErrorInfo
+	if !errors.As(err, &info) {
+		info = &dto.ErrorInfo{Code: dto.ErrCodeInternal, Message: err.Error()}
+	}
+	return r.Reply(ctx, dto.NewErrorMessage(msg, info.Code, info.Message))
+}
+
+// Reply - ставит сообщение в очередь отправки клиенту
+func (r *Router) Reply(ctx context.Context, msg *dto.Message) error {
+	select {
+	case r.out <- *msg:
+		return nil
+	case <-r.closed:
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Read - следующий ответ для клиента, после Close и отправки всех ответов возвращает io.EOF
+func (r *Router) Read(ctx context.Context, msg *dto.Message) error {
+	select {
+	case m := <-r.out:
+		*msg = m
+		return nil
+	case <-r.closed:
+		select {
+		case m := <-r.out:
+			*msg = m
+			return nil
+		default:
+			return io.EOF
+		}
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Done - канал закрывается при Close (сервер по нему разрывает соединение)
+func (r *Router) Done() <-chan struct{} {
+	return r.closed
+}
+
+// Close - закрывает соединение, повторный вызов ничего не делает
+func (r *Router) Close() error {
+	r.closeOnce.Do(func() {
+		close(r.closed)
+		r.mux.mu.RLock()
+		hooks := r.mux.onClose
+		r.mux.mu.RUnlock()
+		for _, f := range hooks {
+			f(r)
+		}
+	})
+	return nil
+}
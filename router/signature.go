@@ -0,0 +1,32 @@
+package router
+
+import (
+	"context"
+
+	"github.com/blabu/messagesLib/dto"
+)
+
+// Signatures - проверяет подписи ed25519 сообщений клиенту (To) или в канал (Channel) ключами отправителя (From) из keys
+// до маршрутизации и сохранения. Если require - неподписанные сообщения отклоняются, иначе проверяются только подписанные.
+// Отказ отправляется клиенту с кодом dto.ErrCodeBadSignature.
+// Ставится после Identity, чтобы From был привязан к соединению.
+// Повтор ранее подписанного сообщения не обнаруживается (смотри описание подписи в dto)
+func Signatures(keys dto.IBgKeyDirectory, require bool) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(ctx context.Context, r *Router, msg *dto.Message) error {
+			if (msg.To == "" && msg.Channel == "") || (msg.Signature == "" && !require) {
+				return next(ctx, r, msg)
+			}
+			if err := dto.VerifyMessageFrom(ctx, keys, msg); err != nil {
+				text := err.Error()
+				switch err {
+				case dto.ErrNoSignature, dto.ErrBadSignature, dto.ErrUnknownSigner, dto.ErrBadPublicKey:
+				default:
+					text = "Can not verify message signature" // Ошибки хранилища клиенту не передаются
+				}
+				return &dto.ErrorInfo{Code: dto.ErrCodeBadSignature, Command: msg.Command, Message: text}
+			}
+			return next(ctx, r, msg)
+		}
+	}
+}
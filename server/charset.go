@@ -0,0 +1,34 @@
+package server
+
+import "github.com/blabu/messagesLib/parser"
+
+// CharsetSource - выбирает кодировку текстовых сообщений для нового соединения
+type CharsetSource func(peer *Peer) parser.Charset
+
+// FixedCharset - одна кодировка для всех соединений
+func FixedCharset(cs parser.Charset) CharsetSource {
+	return func(*Peer) parser.Charset {
+		return cs
+	}
+}
+
+// NameCharsets - кодировка по имени клиента, подтвержденному транспортом (Peer.Name), остальным def
+func NameCharsets(names map[string]parser.Charset, def parser.Charset) CharsetSource {
+	return func(peer *Peer) parser.Charset {
+		if cs, ok := names[peer.Name]; ok {
+			return cs
+		}
+		return def
+	}
+}
+
+// SetCharset - меняет кодировку текстовых сообщений соединения, например когда бизнес логика узнала клиента после авторизации.
+// Возвращает false, если в Server не задан Charset или парсер соединения еще не создан
+func (p *Peer) SetCharset(cs parser.Charset) bool {
+	cp, ok := p.charset.Load().(*parser.CharsetParser)
+	if !ok {
+		return false
+	}
+	cp.SetCharset(cs)
+	return true
+}
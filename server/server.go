@@ -0,0 +1,432 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/blabu/messagesLib/dto"
+	"github.com/blabu/messagesLib/parser"
+)
+
+// DefaultMaxPackageSize - максимальный размер принимаемого пакета, если он не задан в Server
+const DefaultMaxPackageSize = 1 << 20
+
+// DefaultFirstBytesTimeout - сколько ждать первые байты нового соединения, если HeaderLimits.Timeout не задан
+const DefaultFirstBytesTimeout = 30 * time.Second
+
+// ErrServerClosed - возвращается из Serve после вызова Shutdown или Close
+var ErrServerClosed = errors.New("Server closed")
+
+// MessageConn - соединение, по которому передаются уже разобранные сообщения.
+// Позволяет использовать один и тот же цикл обработки для разных транспортов (поток байт, websocket, датаграммы)
+type MessageConn interface {
+	ReadMessage(ctx context.Context, msg *dto.Message) error
+	WriteMessage(ctx context.Context, msg *dto.Message) error
+	Close() error
+}
+
+// Peer - информация о подключенном клиенте
+type Peer struct {
+	Transport  string // tcp, tls, unix, ws, udp
+	RemoteAddr net.Addr
+	LocalAddr  net.Addr
+	Name       string // Имя клиента, подтвержденное транспортом (например клиентским сертификатом), пусто если транспорт его не проверяет
+
+	charset atomic.Value // *parser.CharsetParser, если в Server задан Charset
+}
+
+// Handler - создает бизнес логику для нового соединения. Close бизнес логики вызывается при разрыве соединения.
+// Если бизнес логика реализует Done() <-chan struct{} (как router.Router), закрытие этого канала разрывает соединение
+type Handler func(ctx context.Context, peer *Peer) (dto.ReadWriteCloser, error)
+
+// Server - принимает соединения, выбирает парсер через parser.InitParser
+// и передает принятые сообщения бизнес логике (dto.ReadWriteCloser), а ее ответы обратно клиенту
+type Server struct {
+	Handler             Handler
+	MaxPackageSize      uint64              // Максимальный размер пакета (0 - DefaultMaxPackageSize)
+	HeaderLimits        parser.HeaderLimits // Ограничения на чтение заголовка пакета (Timeout 0 - DefaultFirstBytesTimeout для первых байт соединения)
+	WriteTimeout        time.Duration       // Максимальное время отправки одного ответа (0 - без ограничения)
+	TLSHandshakeTimeout time.Duration       // Максимальное время TLS рукопожатия (0 - DefaultTLSHandshakeTimeout)
+	TLSIdentity         IdentitySource      // Поле клиентского сертификата, из которого берется Peer.Name
+	UnixCredMapper      CredMapper          // Имя клиента unix сокета для Serve (nil - без аутентификации), у ServeUnix свой mapper
+	Charset             CharsetSource       // Кодировка текстовых сообщений клиента (nil - UTF-8 для всех)
+	ErrorLog            *log.Logger         // Лог ошибок соединений (nil - стандартный логгер)
+	IdentityPolicy      int                 // Что делать с чужим From при подтвержденном Peer.Name (dto.IdentityReject или dto.IdentityRewrite)
+	Delegations         dto.IBgDelegation   // От чьего имени могут писать клиенты с подтвержденным Peer.Name (nil - ни от чьего)
+
+	mu        sync.Mutex
+	ctx       context.Context
+	cancel    context.CancelFunc
+	listeners map[net.Listener]struct{}
+	conns     map[MessageConn]struct{}
+	wg        sync.WaitGroup
+	closed    bool
+}
+
+func (s *Server) logf(format string, args ...interface{}) {
+	if s.ErrorLog != nil {
+		s.ErrorLog.Printf(format, args...)
+		return
+	}
+	log.Printf(format, args...)
+}
+
+// firstBytesTimeout - время на первые байты соединения (и TLS рукопожатие), по которым выбирается парсер
+func (s *Server) firstBytesTimeout() time.Duration {
+	if s.HeaderLimits.Timeout <= 0 {
+		return DefaultFirstBytesTimeout
+	}
+	return s.HeaderLimits.Timeout
+}
+
+func (s *Server) maxPackageSize() uint64 {
+	if s.MaxPackageSize == 0 {
+		return DefaultMaxPackageSize
+	}
+	return s.MaxPackageSize
+}
+
+// baseContext - контекст сервера, отменяется при Shutdown
+func (s *Server) baseContext() context.Context {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.ctx == nil {
+		s.ctx, s.cancel = context.WithCancel(context.Background())
+	}
+	return s.ctx
+}
+
+// ListenAndServe - слушает адрес addr в сети network ("tcp", "unix") и обслуживает соединения
+func (s *Server) ListenAndServe(network, addr string) error {
+	l, err := net.Listen(network, addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve - принимает соединения из l пока не будет вызван Shutdown или Close. Всегда возвращает не nil ошибку
+func (s *Server) Serve(l net.Listener) error {
+	return s.serve(l, s.UnixCredMapper)
+}
+
+// serve - принимает соединения из l, mapper аутентифицирует клиентов unix сокета
+func (s *Server) serve(l net.Listener, mapper CredMapper) error {
+	if s.Handler == nil {
+		return errors.New("Server handler is nil")
+	}
+	ctx := s.baseContext()
+	if !s.trackListener(l, true) {
+		l.Close()
+		return ErrServerClosed
+	}
+	defer s.trackListener(l, false)
+	var delay time.Duration
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return ErrServerClosed
+			}
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				if delay == 0 {
+					delay = 5 * time.Millisecond
+				} else if delay *= 2; delay > time.Second {
+					delay = time.Second
+				}
+				s.logf("Accept error %v, retrying in %v", err, delay)
+				time.Sleep(delay)
+				continue
+			}
+			return err
+		}
+		delay = 0
+		if !s.startConn() {
+			c.Close()
+			return ErrServerClosed
+		}
+		go func() {
+			defer s.wg.Done()
+			s.serveNetConn(ctx, c, l.Addr().Network(), mapper)
+		}()
+	}
+}
+
+func (s *Server) trackListener(l net.Listener, add bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if add {
+		if s.closed {
+			return false
+		}
+		if s.listeners == nil {
+			s.listeners = make(map[net.Listener]struct{})
+		}
+		s.listeners[l] = struct{}{}
+	} else {
+		delete(s.listeners, l)
+	}
+	return true
+}
+
+// startConn - учитывает обработчик соединения для ожидания в Shutdown
+func (s *Server) startConn() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+	s.wg.Add(1)
+	return true
+}
+
+func (s *Server) trackConn(c MessageConn, add bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if add {
+		if s.closed {
+			return false
+		}
+		if s.conns == nil {
+			s.conns = make(map[MessageConn]struct{})
+		}
+		s.conns[c] = struct{}{}
+	} else {
+		delete(s.conns, c)
+	}
+	return true
+}
+
+// serveNetConn - выбирает парсер по первым принятым байтам и обслуживает потоковое соединение
+func (s *Server) serveNetConn(ctx context.Context, c net.Conn, transport string, mapper CredMapper) {
+	deadline := time.Now().Add(s.firstBytesTimeout()) // ограничивает и TLS рукопожатие
+	c.SetDeadline(deadline)
+	stop := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.Close()
+		case <-stop:
+		}
+	}()
+	var name string
+	var err error
+	switch tc := c.(type) {
+	case *tls.Conn:
+		transport = "tls"
+		if name, err = s.tlsPeerName(tc, deadline); err != nil {
+			close(stop)
+			s.logf("TLS handshake with %s: %v", c.RemoteAddr(), err)
+			c.Close()
+			return
+		}
+	case *net.UnixConn:
+		if name, err = unixPeerName(tc, mapper); err != nil {
+			close(stop)
+			s.logf("Reject unix socket peer: %v", err)
+			c.Close()
+			return
+		}
+	}
+	rec := make([]byte, len(parser.BeginHeader)+1)
+	n, err := io.ReadAtLeast(c, rec, len(rec))
+	close(stop)
+	c.SetDeadline(time.Time{})
+	if err != nil {
+		c.Close()
+		return
+	}
+	peer := &Peer{
+		Transport:  transport,
+		RemoteAddr: c.RemoteAddr(),
+		LocalAddr:  c.LocalAddr(),
+		Name:       name,
+	}
+	p, err := s.newParser(rec[:n], peer)
+	if err != nil {
+		s.logf("Can not init parser for %s: %v", c.RemoteAddr(), err)
+		c.Close()
+		return
+	}
+	mc := NewStreamConn(c, p)
+	mc.reader.Unread(rec[:n])
+	s.ServeConn(ctx, mc, peer)
+}
+
+// newParser - выбирает парсер по началу пакета rec и настраивает его для клиента peer
+func (s *Server) newParser(rec []byte, peer *Peer) (parser.IParser, error) {
+	p, err := parser.InitParser(rec, s.maxPackageSize())
+	if err != nil {
+		return nil, err
+	}
+	if lp, ok := p.(interface{ SetHeaderLimits(parser.HeaderLimits) }); ok {
+		lp.SetHeaderLimits(s.HeaderLimits)
+	}
+	if s.Charset != nil {
+		cp := parser.WithCharset(p, s.Charset(peer))
+		peer.charset.Store(cp)
+		return cp, nil
+	}
+	return p, nil
+}
+
+// ServeConn - обслуживает одно соединение до его разрыва или отмены ctx.
+// Принятые сообщения передаются в Write бизнес логики, все что вернет Read отправляется клиенту.
+// Используется транспортами, которые сами принимают соединения (например websocket)
+func (s *Server) ServeConn(ctx context.Context, mc MessageConn, peer *Peer) {
+	if !s.startConn() {
+		mc.Close()
+		return
+	}
+	defer s.wg.Done()
+	if !s.trackConn(mc, true) {
+		mc.Close()
+		return
+	}
+	defer s.trackConn(mc, false)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	rwc, err := s.Handler(ctx, peer)
+	if err != nil {
+		s.logf("Handler for %s refused connection: %v", peer.RemoteAddr, err)
+		mc.Close()
+		return
+	}
+	var closed <-chan struct{} // Закрытие соединения со стороны бизнес логики
+	if dn, ok := rwc.(interface{ Done() <-chan struct{} }); ok {
+		closed = dn.Done()
+	}
+	done := make(chan struct{})
+	writerDone := make(chan struct{})
+	go func() { // Отмена контекста (Shutdown) или закрытие бизнес логики прерывает блокирующее чтение
+		select {
+		case <-ctx.Done():
+			mc.Close()
+		case <-closed:
+			<-writerDone // Сначала отправляем клиенту оставшиеся ответы
+			cancel()
+			mc.Close()
+		case <-done:
+		}
+	}()
+	go func() {
+		defer close(writerDone)
+		s.writeLoop(ctx, cancel, mc, rwc, peer)
+	}()
+	s.readLoop(ctx, mc, rwc, peer)
+	cancel()
+	mc.Close()
+	<-writerDone
+	close(done)
+	if err := rwc.Close(); err != nil {
+		s.logf("Close business logic for %s: %v", peer.RemoteAddr, err)
+	}
+}
+
+func (s *Server) readLoop(ctx context.Context, mc MessageConn, rwc dto.ReadWriteCloser, peer *Peer) {
+	for {
+		var msg dto.Message
+		if err := mc.ReadMessage(ctx, &msg); err != nil {
+			var fe *parser.FrameError
+			if errors.As(err, &fe) {
+				s.logf("Skip invalid frame from %s: %v", peer.RemoteAddr, err)
+				continue
+			}
+			if ctx.Err() == nil && !errors.Is(err, io.EOF) {
+				s.logf("Read from %s: %v", peer.RemoteAddr, err)
+			}
+			return
+		}
+		if err := dto.BindIdentity(ctx, peer.Name, &msg, s.IdentityPolicy, s.Delegations); err != nil {
+			s.logf("Reject message from %s: From %s, identity %s: %v", peer.RemoteAddr, msg.From, peer.Name, err)
+			if err := mc.WriteMessage(ctx, dto.NewErrorMessage(&msg, dto.ErrCodeForbidden, err.Error())); err != nil {
+				return
+			}
+			continue
+		}
+		if err := rwc.Write(ctx, &msg); err != nil {
+			if ctx.Err() == nil {
+				s.logf("Business logic rejected message from %s: %v", peer.RemoteAddr, err)
+			}
+			return
+		}
+	}
+}
+
+// writeLoop - отправляет ответы бизнес логики. io.EOF из Read означает, что ответов больше не будет
+func (s *Server) writeLoop(ctx context.Context, cancel context.CancelFunc, mc MessageConn, rwc dto.ReadWriteCloser, peer *Peer) {
+	for {
+		var msg dto.Message
+		if err := rwc.Read(ctx, &msg); err != nil {
+			if !errors.Is(err, io.EOF) && ctx.Err() == nil {
+				s.logf("Read answer for %s: %v", peer.RemoteAddr, err)
+				cancel()
+			}
+			return
+		}
+		wctx, wcancel := ctx, context.CancelFunc(func() {})
+		if s.WriteTimeout > 0 {
+			wctx, wcancel = context.WithTimeout(ctx, s.WriteTimeout)
+		}
+		err := mc.WriteMessage(wctx, &msg)
+		wcancel()
+		if err != nil {
+			if ctx.Err() == nil {
+				s.logf("Write to %s: %v", peer.RemoteAddr, err)
+				cancel()
+			}
+			return
+		}
+	}
+}
+
+// Shutdown - перестает принимать новые соединения, закрывает открытые соединения (с уведомлением бизнес логики через Close)
+// и ждет завершения их обработки или отмены ctx
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.baseContext()
+	s.mu.Lock()
+	s.closed = true
+	s.cancel()
+	var err error
+	for l := range s.listeners {
+		if e := l.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	s.mu.Unlock()
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return err
+	case <-ctx.Done():
+		s.mu.Lock()
+		for c := range s.conns {
+			c.Close()
+		}
+		s.mu.Unlock()
+		return fmt.Errorf("Shutdown interrupted: %w", ctx.Err())
+	}
+}
+
+// Close - немедленно закрывает все слушатели и соединения
+func (s *Server) Close() error {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := s.Shutdown(ctx)
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
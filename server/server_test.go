@@ -0,0 +1,44 @@
+package server
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/blabu/messagesLib/parser"
+)
+
+// TestSilentConnClosed - соединение, которое ничего не отправляет, закрывается по таймауту первых байт
+func TestSilentConnClosed(t *testing.T) {
+	for _, tc := range []struct {
+		timeout, want time.Duration
+	}{
+		{0, DefaultFirstBytesTimeout},
+		{100 * time.Millisecond, 100 * time.Millisecond},
+	} {
+		srv := &Server{Handler: newEchoLogic().handler, HeaderLimits: parser.HeaderLimits{Timeout: tc.timeout}, ErrorLog: quietLog()}
+		if d := srv.firstBytesTimeout(); d != tc.want {
+			t.Fatalf("Timeout %v: first bytes timeout %v, want %v", tc.timeout, d, tc.want)
+		}
+	}
+
+	srv := &Server{Handler: newEchoLogic().handler, HeaderLimits: parser.HeaderLimits{Timeout: 100 * time.Millisecond}, ErrorLog: quietLog()}
+	t.Cleanup(func() { srv.Close() })
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.Serve(l)
+	c, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err = c.Read(make([]byte, 1)); err == nil {
+		t.Fatal("Silent connection got data")
+	}
+	if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		t.Fatal("Silent connection is not closed by the server")
+	}
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/blabu/messagesLib/dto"
+	"github.com/blabu/messagesLib/parser"
+)
+
+// StreamConn - MessageConn поверх потокового соединения (tcp, tls, unix)
+type StreamConn struct {
+	conn   net.Conn
+	parser parser.IParser
+	reader *parser.Reader
+	wMutex sync.Mutex
+}
+
+// NewStreamConn - создает MessageConn, который разбирает и формирует пакеты парсером p
+func NewStreamConn(c net.Conn, p parser.IParser) *StreamConn {
+	return &StreamConn{conn: c, parser: p, reader: parser.NewReader(c, p)}
+}
+
+// Conn - исходное соединение
+func (sc *StreamConn) Conn() net.Conn {
+	return sc.conn
+}
+
+// ReadMessage - читает следующий пакет
+func (sc *StreamConn) ReadMessage(ctx context.Context, msg *dto.Message) error {
+	m, err := sc.reader.ReadMessage(ctx)
+	if err != nil {
+		return err
+	}
+	*msg = m
+	return nil
+}
+
+// WriteMessage - формирует пакет и отправляет его, дедлайн ctx ограничивает время записи
+func (sc *StreamConn) WriteMessage(ctx context.Context, msg *dto.Message) error {
+	data, err := sc.parser.FormMessage(msg)
+	if err != nil {
+		return err
+	}
+	sc.wMutex.Lock()
+	defer sc.wMutex.Unlock()
+	if d, ok := ctx.Deadline(); ok {
+		sc.conn.SetWriteDeadline(d)
+		defer sc.conn.SetWriteDeadline(time.Time{})
+	}
+	_, err = sc.conn.Write(data)
+	return err
+}
+
+// Close - закрывает соединение
+func (sc *StreamConn) Close() error {
+	return sc.conn.Close()
+}
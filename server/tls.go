@@ -0,0 +1,137 @@
+package server
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"time"
+)
+
+// DefaultTLSHandshakeTimeout - максимальное время TLS рукопожатия, если оно не задано в Server
+const DefaultTLSHandshakeTimeout = 10 * time.Second
+
+// IdentitySource - поле клиентского сертификата, из которого берется имя клиента
+type IdentitySource int
+
+const (
+	IdentityCommonName IdentitySource = iota // Subject CommonName
+	IdentityDNSName                          // Первое DNS имя из SAN
+	IdentityURI                              // Первый URI из SAN
+	IdentityEmail                            // Первый email из SAN
+)
+
+// ErrNoIdentity - в сертификате клиента нет поля, из которого берется имя
+var ErrNoIdentity = errors.New("Client certificate has no identity")
+
+// CertIdentity - имя клиента из сертификата
+func CertIdentity(cert *x509.Certificate, src IdentitySource) (string, error) {
+	var name string
+	switch src {
+	case IdentityCommonName:
+		name = cert.Subject.CommonName
+	case IdentityDNSName:
+		if len(cert.DNSNames) > 0 {
+			name = cert.DNSNames[0]
+		}
+	case IdentityURI:
+		if len(cert.URIs) > 0 {
+			name = cert.URIs[0].String()
+		}
+	case IdentityEmail:
+		if len(cert.EmailAddresses) > 0 {
+			name = cert.EmailAddresses[0]
+		}
+	}
+	if name == "" {
+		return "", ErrNoIdentity
+	}
+	return name, nil
+}
+
+// CAFingerprint - отпечаток сертификата для PinnedCAs
+func CAFingerprint(cert *x509.Certificate) [32]byte {
+	return sha256.Sum256(cert.Raw)
+}
+
+// MutualTLS - настройки взаимной TLS аутентификации.
+// Клиент обязан предъявить сертификат подписанный одним из ClientCAs,
+// если задан PinnedCAs то цепочка должна заканчиваться одним из закрепленных CA (для парка устройств с собственным CA)
+type MutualTLS struct {
+	Certificates []tls.Certificate // Сертификаты сервера
+	ClientCAs    *x509.CertPool    // CA, которыми подписаны сертификаты клиентов
+	PinnedCAs    [][32]byte        // SHA256 отпечатки разрешенных корневых CA (смотри CAFingerprint)
+}
+
+// Config - конфигурация TLS для ServeTLS
+func (m *MutualTLS) Config() (*tls.Config, error) {
+	if len(m.Certificates) == 0 {
+		return nil, errors.New("Server certificate is required")
+	}
+	if m.ClientCAs == nil {
+		return nil, errors.New("Client CA pool is required")
+	}
+	conf := &tls.Config{
+		Certificates: m.Certificates,
+		ClientCAs:    m.ClientCAs,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS12,
+	}
+	if len(m.PinnedCAs) > 0 {
+		pins := make(map[[32]byte]struct{}, len(m.PinnedCAs))
+		for _, p := range m.PinnedCAs {
+			pins[p] = struct{}{}
+		}
+		conf.VerifyPeerCertificate = func(_ [][]byte, chains [][]*x509.Certificate) error {
+			for _, chain := range chains {
+				if _, ok := pins[CAFingerprint(chain[len(chain)-1])]; ok {
+					return nil
+				}
+			}
+			return errors.New("Client certificate is not issued by a pinned CA")
+		}
+	}
+	return conf, nil
+}
+
+// ListenAndServeTLS - слушает tcp адрес addr и обслуживает TLS соединения
+func (s *Server) ListenAndServeTLS(addr string, conf *tls.Config) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return s.ServeTLS(l, conf)
+}
+
+// ServeTLS - обслуживает TLS соединения из l. Если клиент предъявил сертификат,
+// его имя (смотри Server.TLSIdentity) становится Peer.Name и пакеты с другим From отклоняются
+func (s *Server) ServeTLS(l net.Listener, conf *tls.Config) error {
+	return s.Serve(tls.NewListener(l, conf))
+}
+
+func (s *Server) tlsHandshakeTimeout() time.Duration {
+	if s.TLSHandshakeTimeout <= 0 {
+		return DefaultTLSHandshakeTimeout
+	}
+	return s.TLSHandshakeTimeout
+}
+
+// tlsPeerName - завершает рукопожатие и возвращает имя клиента из его сертификата (пусто если сертификата нет).
+// Рукопожатие ограничено TLSHandshakeTimeout, после него восстанавливается дедлайн соединения deadline
+func (s *Server) tlsPeerName(c *tls.Conn, deadline time.Time) (string, error) {
+	hd := time.Now().Add(s.tlsHandshakeTimeout())
+	if !deadline.IsZero() && deadline.Before(hd) {
+		hd = deadline
+	}
+	c.SetDeadline(hd)
+	defer c.SetDeadline(deadline)
+	if err := c.Handshake(); err != nil {
+		return "", err
+	}
+	certs := c.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return "", nil
+	}
+	return CertIdentity(certs[0], s.TLSIdentity)
+}
@@ -0,0 +1,271 @@
+package server
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/blabu/messagesLib/client"
+	"github.com/blabu/messagesLib/dto"
+	"github.com/blabu/messagesLib/parser"
+)
+
+// testCA - самоподписанный CA для тестов
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T, name string) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{cert: cert, key: key}
+}
+
+func (ca *testCA) pool() *x509.CertPool {
+	p := x509.NewCertPool()
+	p.AddCert(ca.cert)
+	return p
+}
+
+// issue - выпускает сертификат, tmpl задает имена и назначение
+func (ca *testCA) issue(t *testing.T, tmpl *x509.Certificate) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl.SerialNumber = big.NewInt(time.Now().UnixNano())
+	tmpl.NotBefore = time.Now().Add(-time.Hour)
+	tmpl.NotAfter = time.Now().Add(time.Hour)
+	tmpl.KeyUsage = x509.KeyUsageDigitalSignature
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func (ca *testCA) serverCert(t *testing.T) tls.Certificate {
+	return ca.issue(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "server"},
+		IPAddresses: []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+}
+
+func (ca *testCA) clientCert(t *testing.T, name string) tls.Certificate {
+	return ca.issue(t, &x509.Certificate{
+		Subject:     pkix.Name{CommonName: name},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+}
+
+// namedLogic - бизнес логика, которая запоминает Peer.Name соединения
+type namedLogic struct {
+	*echoLogic
+	names chan string
+}
+
+func (n *namedLogic) handler(ctx context.Context, peer *Peer) (dto.ReadWriteCloser, error) {
+	n.names <- peer.Name
+	return n.echoLogic, nil
+}
+
+func startTLS(t *testing.T, m *MutualTLS, srv *Server) string {
+	t.Helper()
+	conf, err := m.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.ServeTLS(l, conf)
+	t.Cleanup(func() { srv.Close() })
+	return l.Addr().String()
+}
+
+func dialTLS(addr string, ca *testCA, certs ...tls.Certificate) (*client.Conn, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	c, err := client.Dial(ctx, "tls", addr, &tls.Config{RootCAs: ca.pool(), Certificates: certs, MinVersion: tls.VersionTLS12})
+	if err != nil {
+		return nil, err
+	}
+	// Ошибка проверки клиентского сертификата в TLS 1.3 приходит только при первом чтении
+	err = c.Send(ctx, &dto.Message{
+		MessageMetaInf: dto.MessageMetaInf{Command: dto.PingCOMMAND},
+		MessageContent: dto.MessageContent{ContentType: "binary"},
+	})
+	if err == nil {
+		var msg dto.Message
+		rctx, rcancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		err = c.Receive(rctx, &msg)
+		rcancel()
+		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, parser.ErrHeaderTimeout) {
+			err = nil // Сервер не отвечает на ping, соединение живо
+		}
+	}
+	if err != nil {
+		c.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func TestMutualTLSIdentity(t *testing.T) {
+	ca := newTestCA(t, "fleet CA")
+	logic := &namedLogic{echoLogic: newEchoLogic(), names: make(chan string, 4)}
+	srv := &Server{Handler: logic.handler, ErrorLog: quietLog()}
+	addr := startTLS(t, &MutualTLS{Certificates: []tls.Certificate{ca.serverCert(t)}, ClientCAs: ca.pool()}, srv)
+
+	c, err := dialTLS(addr, ca, ca.clientCert(t, "modem1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if name := <-logic.names; name != "modem1" {
+		t.Fatalf("Peer.Name %q, want modem1", name)
+	}
+	logic.wait(t) // ping из dialTLS, From заполнен именем из сертификата
+
+	ctx := context.Background()
+	msg := dto.Message{
+		MessageMetaInf: dto.MessageMetaInf{Command: dto.DataCOMMAND, From: "modem2", To: "server"},
+		MessageContent: dto.MessageContent{ContentType: "text", Data: []byte("spoofed")},
+	}
+	if err = c.Send(ctx, &msg); err != nil {
+		t.Fatal(err)
+	}
+	var resp dto.Message
+	if err = c.Receive(ctx, &resp); err != nil {
+		t.Fatal(err)
+	}
+	if info := dto.ParseErrorInfo(resp.Data); resp.Command != dto.ErrorCOMMAND || info.Code != dto.ErrCodeForbidden {
+		t.Fatalf("Spoofed From answer %+v %v", resp.MessageMetaInf, info)
+	}
+	logic.nothing(t)
+	msg.From, msg.Data = "modem1", []byte("own")
+	if err = c.Send(ctx, &msg); err != nil {
+		t.Fatal(err)
+	}
+	if m := logic.wait(t); string(m.Data) != "own" || m.From != "modem1" {
+		t.Fatalf("Received %+v", m)
+	}
+}
+
+func TestMutualTLSRejects(t *testing.T) {
+	ca := newTestCA(t, "fleet CA")
+	other := newTestCA(t, "other CA")
+	pinnedOther := &MutualTLS{Certificates: []tls.Certificate{ca.serverCert(t)}, ClientCAs: ca.pool(), PinnedCAs: [][32]byte{CAFingerprint(other.cert)}}
+	pinnedOwn := &MutualTLS{Certificates: []tls.Certificate{ca.serverCert(t)}, ClientCAs: ca.pool(), PinnedCAs: [][32]byte{CAFingerprint(ca.cert)}}
+	plain := &MutualTLS{Certificates: []tls.Certificate{ca.serverCert(t)}, ClientCAs: ca.pool()}
+	cases := []struct {
+		name  string
+		m     *MutualTLS
+		certs []tls.Certificate
+		ok    bool
+	}{
+		{"no_certificate", plain, nil, false},
+		{"other_ca", plain, []tls.Certificate{other.clientCert(t, "modem1")}, false},
+		{"not_pinned", pinnedOther, []tls.Certificate{ca.clientCert(t, "modem1")}, false},
+		{"pinned", pinnedOwn, []tls.Certificate{ca.clientCert(t, "modem1")}, true},
+	}
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			logic := newEchoLogic()
+			addr := startTLS(t, tc.m, &Server{Handler: logic.handler, ErrorLog: quietLog()})
+			c, err := dialTLS(addr, ca, tc.certs...)
+			if (err == nil) != tc.ok {
+				t.Fatalf("Dial error %v, want ok=%v", err, tc.ok)
+			}
+			if c != nil {
+				c.Close()
+			}
+		})
+	}
+}
+
+// TestTLSHandshakeTimeout - клиент, который не начинает рукопожатие, отключается
+func TestTLSHandshakeTimeout(t *testing.T) {
+	ca := newTestCA(t, "fleet CA")
+	logic := newEchoLogic()
+	srv := &Server{Handler: logic.handler, ErrorLog: quietLog(), TLSHandshakeTimeout: 100 * time.Millisecond}
+	addr := startTLS(t, &MutualTLS{Certificates: []tls.Certificate{ca.serverCert(t)}, ClientCAs: ca.pool()}, srv)
+	c, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.SetReadDeadline(time.Now().Add(2 * time.Second))
+	start := time.Now()
+	if _, err = c.Read(make([]byte, 1)); err == nil {
+		t.Fatal("Server sent data without handshake")
+	}
+	if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		t.Fatal("Server did not close stalled connection")
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("Connection closed after %v", d)
+	}
+}
+
+func TestCertIdentity(t *testing.T) {
+	u, _ := url.Parse("spiffe://fleet/modem3")
+	cert := &x509.Certificate{
+		Subject:        pkix.Name{CommonName: "modem1"},
+		DNSNames:       []string{"modem2.fleet"},
+		URIs:           []*url.URL{u},
+		EmailAddresses: []string{"modem4@fleet"},
+	}
+	cases := []struct {
+		src  IdentitySource
+		want string
+	}{
+		{IdentityCommonName, "modem1"},
+		{IdentityDNSName, "modem2.fleet"},
+		{IdentityURI, "spiffe://fleet/modem3"},
+		{IdentityEmail, "modem4@fleet"},
+	}
+	for _, tc := range cases {
+		if got, err := CertIdentity(cert, tc.src); err != nil || got != tc.want {
+			t.Errorf("Source %d: %q %v, want %q", tc.src, got, err, tc.want)
+		}
+	}
+	if _, err := CertIdentity(&x509.Certificate{}, IdentityDNSName); err != ErrNoIdentity {
+		t.Errorf("Empty certificate: %v, want ErrNoIdentity", err)
+	}
+}
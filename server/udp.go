@@ -0,0 +1,352 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"hash/crc32"
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/blabu/messagesLib/dto"
+	"github.com/blabu/messagesLib/parser"
+)
+
+// Значения по умолчанию для UDPServer
+const (
+	DefaultUDPIdleTimeout   = 2 * time.Minute
+	DefaultUDPRetryInterval = time.Second
+	DefaultUDPMaxRetries    = 5
+	maxDatagramSize         = 64 * 1024
+	udpQueueSize            = 64
+	udpRecentIDs            = 32
+)
+
+// ErrNotAcknowledged - сообщение с ненулевым ID не было подтверждено клиентом после всех повторов
+var ErrNotAcknowledged = errors.New("Message is not acknowledged")
+
+// UDPServer - транспорт поверх UDP, каждая датаграмма содержит ровно один пакет.
+// Клиенты различаются по адресу и отключаются после IdleTimeout без входящих датаграмм.
+// Доставка сообщений с ненулевым ID подтверждается AckCOMMAND с тем же ID:
+// входящие такие сообщения подтверждаются автоматически (повторы не передаются бизнес логике),
+// исходящие отправляются повторно пока не придет подтверждение
+type UDPServer struct {
+	Server        *Server
+	IdleTimeout   time.Duration // 0 - DefaultUDPIdleTimeout
+	RetryInterval time.Duration // 0 - DefaultUDPRetryInterval
+	MaxRetries    int           // 0 - DefaultUDPMaxRetries
+
+	mu    sync.Mutex
+	peers map[string]*udpPeer
+}
+
+func (u *UDPServer) idleTimeout() time.Duration {
+	if u.IdleTimeout <= 0 {
+		return DefaultUDPIdleTimeout
+	}
+	return u.IdleTimeout
+}
+
+func (u *UDPServer) retryInterval() time.Duration {
+	if u.RetryInterval <= 0 {
+		return DefaultUDPRetryInterval
+	}
+	return u.RetryInterval
+}
+
+func (u *UDPServer) maxRetries() int {
+	if u.MaxRetries <= 0 {
+		return DefaultUDPMaxRetries
+	}
+	return u.MaxRetries
+}
+
+// ListenAndServe - слушает udp адрес addr
+func (u *UDPServer) ListenAndServe(addr string) error {
+	pc, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return err
+	}
+	return u.Serve(pc)
+}
+
+// Serve - принимает датаграммы из pc пока не будет вызван Shutdown сервера. Всегда возвращает не nil ошибку
+func (u *UDPServer) Serve(pc net.PacketConn) error {
+	if u.Server == nil || u.Server.Handler == nil {
+		return errors.New("Server handler is nil")
+	}
+	ctx := u.Server.baseContext()
+	if !u.Server.startConn() {
+		pc.Close()
+		return ErrServerClosed
+	}
+	defer u.Server.wg.Done()
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		t := time.NewTicker(u.idleTimeout() / 2)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				pc.Close()
+				return
+			case <-stop:
+				return
+			case <-t.C:
+				u.expire()
+			}
+		}
+	}()
+	defer u.closePeers()
+	buf := make([]byte, maxDatagramSize)
+	for {
+		n, addr, err := pc.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ErrServerClosed
+			}
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				continue
+			}
+			return err
+		}
+		data := make([]byte, n)
+		copy(data, buf[:n])
+		u.receive(ctx, pc, addr, data)
+	}
+}
+
+// receive - передает датаграмму клиенту addr. Новый клиент регистрируется только после первого правильного пакета,
+// поэтому мусор и чужие протоколы не создают соединений
+func (u *UDPServer) receive(ctx context.Context, pc net.PacketConn, addr net.Addr, data []byte) {
+	u.mu.Lock()
+	p, ok := u.peers[addr.String()]
+	u.mu.Unlock()
+	if ok {
+		p.receive(data)
+		return
+	}
+	peer := &Peer{Transport: "udp", RemoteAddr: addr, LocalAddr: pc.LocalAddr()}
+	prs, err := u.Server.newParser(data, peer)
+	if err != nil {
+		u.Server.logf("Can not init parser for %s: %v", addr, err)
+		return
+	}
+	msg, err := prs.ParseMessage(data)
+	if err != nil {
+		u.Server.logf("Skip invalid datagram from %s: %v", addr, err)
+		return
+	}
+	p = &udpPeer{
+		srv:    u,
+		pc:     pc,
+		addr:   addr,
+		parser: prs,
+		in:     make(chan dto.Message, udpQueueSize),
+		closed: make(chan struct{}),
+		acks:   make(map[uint8]chan struct{}),
+	}
+	u.mu.Lock()
+	if u.peers == nil {
+		u.peers = make(map[string]*udpPeer)
+	}
+	u.peers[addr.String()] = p
+	u.mu.Unlock()
+	go u.Server.ServeConn(ctx, p, peer)
+	p.handle(msg, data)
+}
+
+func (u *UDPServer) expire() {
+	deadline := time.Now().Add(-u.idleTimeout()).UnixNano()
+	u.mu.Lock()
+	var idle []*udpPeer
+	for _, p := range u.peers {
+		if atomic.LoadInt64(&p.lastSeen) < deadline {
+			idle = append(idle, p)
+		}
+	}
+	u.mu.Unlock()
+	for _, p := range idle {
+		p.Close()
+	}
+}
+
+func (u *UDPServer) closePeers() {
+	u.mu.Lock()
+	peers := make([]*udpPeer, 0, len(u.peers))
+	for _, p := range u.peers {
+		peers = append(peers, p)
+	}
+	u.mu.Unlock()
+	for _, p := range peers {
+		p.Close()
+	}
+}
+
+func (u *UDPServer) remove(p *udpPeer) {
+	u.mu.Lock()
+	if u.peers[p.addr.String()] == p {
+		delete(u.peers, p.addr.String())
+	}
+	u.mu.Unlock()
+}
+
+// udpRecent - принятое сообщение с подтверждением. ID повторяется каждые 256 сообщений,
+// поэтому повтор определяется по ID вместе с контрольной суммой датаграммы (повтор отправляется теми же байтами)
+type udpRecent struct {
+	id  uint8
+	sum uint32
+}
+
+// udpPeer - MessageConn одного UDP клиента
+type udpPeer struct {
+	srv      *UDPServer
+	pc       net.PacketConn
+	addr     net.Addr
+	parser   parser.IParser
+	in       chan dto.Message
+	lastSeen int64 // unix nano последней датаграммы
+
+	closeOnce sync.Once
+	closed    chan struct{}
+
+	mu     sync.Mutex
+	acks   map[uint8]chan struct{} // Ожидающие подтверждения исходящие сообщения
+	recent [udpRecentIDs]udpRecent // Последние принятые сообщения для отбрасывания повторов
+	next   int
+	wMutex sync.Mutex // Отправка с подтверждением по одному сообщению
+}
+
+// receive - разбирает датаграмму
+func (p *udpPeer) receive(data []byte) {
+	msg, err := p.parser.ParseMessage(data)
+	if err != nil {
+		p.srv.Server.logf("Skip invalid datagram from %s: %v", p.addr, err)
+		return
+	}
+	p.handle(msg, data)
+}
+
+// handle - обрабатывает подтверждения и повторы сообщения msg, разобранного из датаграммы data
+func (p *udpPeer) handle(msg dto.Message, data []byte) {
+	atomic.StoreInt64(&p.lastSeen, time.Now().UnixNano())
+	if msg.Command == dto.AckCOMMAND {
+		p.mu.Lock()
+		if ack, ok := p.acks[msg.ID]; ok {
+			close(ack)
+			delete(p.acks, msg.ID)
+		}
+		p.mu.Unlock()
+		return
+	}
+	rec := udpRecent{id: msg.ID, sum: crc32.ChecksumIEEE(data)}
+	if msg.ID != 0 && p.isDuplicate(rec) {
+		p.sendAck(&msg)
+		return
+	}
+	select {
+	case p.in <- msg:
+		if msg.ID != 0 {
+			p.remember(rec)
+			p.sendAck(&msg)
+		}
+	case <-p.closed:
+	default:
+		p.srv.Server.logf("Drop datagram from %s: queue is full", p.addr)
+	}
+}
+
+func (p *udpPeer) isDuplicate(rec udpRecent) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, r := range p.recent {
+		if r == rec {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *udpPeer) remember(rec udpRecent) {
+	p.mu.Lock()
+	p.recent[p.next] = rec
+	p.next = (p.next + 1) % len(p.recent)
+	p.mu.Unlock()
+}
+
+func (p *udpPeer) sendAck(msg *dto.Message) {
+	ack := dto.Message{
+		MessageMetaInf: dto.MessageMetaInf{Command: dto.AckCOMMAND, Proto: msg.Proto, ID: msg.ID, To: msg.From},
+		MessageContent: dto.MessageContent{ContentType: "binary"},
+	}
+	if data, err := p.parser.FormMessage(&ack); err == nil {
+		p.pc.WriteTo(data, p.addr)
+	}
+}
+
+func (p *udpPeer) ReadMessage(ctx context.Context, msg *dto.Message) error {
+	select {
+	case m := <-p.in:
+		*msg = m
+		return nil
+	case <-p.closed:
+		return io.EOF
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// WriteMessage - отправляет сообщение одной датаграммой. Сообщение с ненулевым ID отправляется повторно
+// пока не придет подтверждение, иначе возвращается ErrNotAcknowledged
+func (p *udpPeer) WriteMessage(ctx context.Context, msg *dto.Message) error {
+	data, err := p.parser.FormMessage(msg)
+	if err != nil {
+		return err
+	}
+	if msg.ID == 0 {
+		_, err = p.pc.WriteTo(data, p.addr)
+		return err
+	}
+	p.wMutex.Lock()
+	defer p.wMutex.Unlock()
+	ack := make(chan struct{})
+	p.mu.Lock()
+	p.acks[msg.ID] = ack
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		if p.acks[msg.ID] == ack {
+			delete(p.acks, msg.ID)
+		}
+		p.mu.Unlock()
+	}()
+	t := time.NewTimer(p.srv.retryInterval())
+	defer t.Stop()
+	for i := 0; i < p.srv.maxRetries(); i++ {
+		if _, err = p.pc.WriteTo(data, p.addr); err != nil {
+			return err
+		}
+		select {
+		case <-ack:
+			return nil
+		case <-t.C:
+			t.Reset(p.srv.retryInterval())
+		case <-p.closed:
+			return io.ErrClosedPipe
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return ErrNotAcknowledged
+}
+
+func (p *udpPeer) Close() error {
+	p.closeOnce.Do(func() {
+		close(p.closed)
+		p.srv.remove(p)
+	})
+	return nil
+}
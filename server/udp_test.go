@@ -0,0 +1,172 @@
+package server
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/blabu/messagesLib/dto"
+	"github.com/blabu/messagesLib/parser"
+)
+
+// echoLogic - бизнес логика для тестов: принятые сообщения складываются в got, ответы берутся из out
+type echoLogic struct {
+	got chan dto.Message
+	out chan dto.Message
+}
+
+func newEchoLogic() *echoLogic {
+	return &echoLogic{got: make(chan dto.Message, 16), out: make(chan dto.Message, 16)}
+}
+
+func (e *echoLogic) Write(ctx context.Context, msg *dto.Message) error {
+	e.got <- *msg
+	return nil
+}
+
+func (e *echoLogic) Read(ctx context.Context, msg *dto.Message) error {
+	select {
+	case m := <-e.out:
+		*msg = m
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (e *echoLogic) Close() error { return nil }
+
+func (e *echoLogic) handler(ctx context.Context, peer *Peer) (dto.ReadWriteCloser, error) {
+	return e, nil
+}
+
+// wait - следующее принятое бизнес логикой сообщение
+func (e *echoLogic) wait(t *testing.T) dto.Message {
+	t.Helper()
+	select {
+	case m := <-e.got:
+		return m
+	case <-time.After(2 * time.Second):
+		t.Fatal("Message is not received")
+	}
+	return dto.Message{}
+}
+
+// nothing - бизнес логика не получила сообщений
+func (e *echoLogic) nothing(t *testing.T) {
+	t.Helper()
+	select {
+	case m := <-e.got:
+		t.Fatalf("Unexpected message %+v", m)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func quietLog() *log.Logger {
+	return log.New(ioutil.Discard, "", 0)
+}
+
+func startUDP(t *testing.T, logic *echoLogic) (*UDPServer, net.Conn) {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := &UDPServer{Server: &Server{Handler: logic.handler, ErrorLog: quietLog()}, RetryInterval: 50 * time.Millisecond}
+	go u.Serve(pc)
+	t.Cleanup(func() { u.Server.Close() })
+	c, err := net.Dial("udp", pc.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return u, c
+}
+
+func (u *UDPServer) peerCount() int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return len(u.peers)
+}
+
+func udpFrame(t *testing.T, id uint8, data string) []byte {
+	t.Helper()
+	msg := dto.Message{
+		MessageMetaInf: dto.MessageMetaInf{Command: dto.DataCOMMAND, ID: id, From: "modem", To: "server"},
+		MessageContent: dto.MessageContent{ContentType: "text", Data: []byte(data)},
+	}
+	frame, err := parser.CreateEmptyParser(1024).FormMessage(&msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return frame
+}
+
+// TestUDPInvalidDatagram - неверные датаграммы не роняют сервер и не регистрируют клиента
+func TestUDPInvalidDatagram(t *testing.T) {
+	logic := newEchoLogic()
+	u, c := startUDP(t, logic)
+	for _, d := range []string{"$V1;a;b;5;T;;0;0###", "$V1;a;b;5;T;;0;3###abc", "garbage", "$V1;a;b;5;T;;0;4###\x00\x00\x00\x00"} {
+		if _, err := c.Write([]byte(d)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	logic.nothing(t)
+	if n := u.peerCount(); n != 0 {
+		t.Fatalf("%d peers registered by invalid datagrams", n)
+	}
+	if _, err := c.Write(udpFrame(t, 0, "hello")); err != nil {
+		t.Fatal(err)
+	}
+	if m := logic.wait(t); string(m.Data) != "hello" {
+		t.Fatalf("Received %q", m.Data)
+	}
+	if n := u.peerCount(); n != 1 {
+		t.Fatalf("%d peers after valid datagram", n)
+	}
+}
+
+func readAck(t *testing.T, c net.Conn, id uint8) {
+	t.Helper()
+	buf := make([]byte, 1024)
+	c.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, err := c.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ack, err := parser.CreateEmptyParser(1024).ParseMessage(buf[:n])
+	if err != nil || ack.Command != dto.AckCOMMAND || ack.ID != id {
+		t.Fatalf("Expected ack %d, got %+v (%v)", id, ack.MessageMetaInf, err)
+	}
+}
+
+// TestUDPDuplicate - отбрасываются только повторы той же датаграммы, новое сообщение с уже использованным ID доставляется
+func TestUDPDuplicate(t *testing.T) {
+	logic := newEchoLogic()
+	_, c := startUDP(t, logic)
+	steps := []struct {
+		data      string
+		delivered bool
+	}{
+		{"first", true},
+		{"first", false}, // Повтор
+		{"second", true}, // Тот же ID после переполнения счетчика
+		{"second", false},
+	}
+	for i, s := range steps {
+		if _, err := c.Write(udpFrame(t, 5, s.data)); err != nil {
+			t.Fatal(err)
+		}
+		readAck(t, c, 5)
+		if !s.delivered {
+			logic.nothing(t)
+			continue
+		}
+		if m := logic.wait(t); string(m.Data) != s.data {
+			t.Fatalf("Step %d: received %q, want %q", i, m.Data, s.data)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os/user"
+	"strconv"
+)
+
+// ErrPeerCredUnsupported - получение учетных данных процесса собеседника не поддерживается на этой платформе
+var ErrPeerCredUnsupported = errors.New("Peer credentials are not supported on this platform")
+
+// PeerCred - учетные данные процесса на другой стороне unix сокета (SO_PEERCRED)
+type PeerCred struct {
+	PID int32
+	UID uint32
+	GID uint32
+}
+
+// CredMapper - сопоставляет процессу собеседника имя клиента. Ошибка означает, что соединение должно быть отклонено
+type CredMapper func(cred PeerCred) (string, error)
+
+// UIDMapper - имя клиента по uid процесса из таблицы names, процессы других пользователей отклоняются
+func UIDMapper(names map[uint32]string) CredMapper {
+	return func(cred PeerCred) (string, error) {
+		if name, ok := names[cred.UID]; ok {
+			return name, nil
+		}
+		return "", fmt.Errorf("Uid %d is not allowed", cred.UID)
+	}
+}
+
+// UserNameMapper - имя клиента это имя пользователя, от которого запущен процесс собеседника
+func UserNameMapper(cred PeerCred) (string, error) {
+	u, err := user.LookupId(strconv.FormatUint(uint64(cred.UID), 10))
+	if err != nil {
+		return "", err
+	}
+	return u.Username, nil
+}
+
+// ListenAndServeUnix - слушает unix сокет path. Если mapper не nil,
+// клиент аутентифицируется по учетным данным процесса и его имя становится Peer.Name
+func (s *Server) ListenAndServeUnix(path string, mapper CredMapper) error {
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	return s.ServeUnix(l, mapper)
+}
+
+// ServeUnix - обслуживает соединения unix сокета l, смотри ListenAndServeUnix.
+// mapper действует только для этого сокета, у каждого слушателя может быть свой
+func (s *Server) ServeUnix(l net.Listener, mapper CredMapper) error {
+	return s.serve(l, mapper)
+}
+
+// unixPeerName - имя клиента unix сокета по учетным данным его процесса (пусто если mapper не задан)
+func unixPeerName(c *net.UnixConn, mapper CredMapper) (string, error) {
+	if mapper == nil {
+		return "", nil
+	}
+	cred, err := peerCred(c)
+	if err != nil {
+		return "", err
+	}
+	return mapper(cred)
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+// +build !linux
+
+package server
+
+import "net"
+
+func peerCred(c *net.UnixConn) (PeerCred, error) {
+	return PeerCred{}, ErrPeerCredUnsupported
+}
@@ -0,0 +1,83 @@
+//go:build linux
+// +build linux
+
+package server
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/blabu/messagesLib/client"
+	"github.com/blabu/messagesLib/dto"
+	"github.com/blabu/messagesLib/parser"
+)
+
+// TestServeUnixMappers - у каждого unix сокета свой CredMapper
+func TestServeUnixMappers(t *testing.T) {
+	logic := &namedLogic{echoLogic: newEchoLogic(), names: make(chan string, 4)}
+	srv := &Server{Handler: logic.handler, ErrorLog: quietLog()}
+	t.Cleanup(func() { srv.Close() })
+	uid := uint32(os.Getuid())
+	dir := t.TempDir()
+	paths := []string{filepath.Join(dir, "first.sock"), filepath.Join(dir, "second.sock")}
+	names := []string{"first", "second"}
+	for i, p := range paths {
+		l, err := net.Listen("unix", p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		go srv.ServeUnix(l, UIDMapper(map[uint32]string{uid: names[i]}))
+	}
+	for i, p := range paths {
+		c, err := net.Dial("unix", p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn := client.NewConn(c, parser.CreateEmptyParser(1024))
+		err = conn.Send(context.Background(), &dto.Message{
+			MessageMetaInf: dto.MessageMetaInf{Command: dto.PingCOMMAND},
+			MessageContent: dto.MessageContent{ContentType: "binary"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case name := <-logic.names:
+			if name != names[i] {
+				t.Fatalf("%s: Peer.Name %q, want %q", p, name, names[i])
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("%s: connection is not served", p)
+		}
+		conn.Close()
+	}
+}
+
+// TestServeUnixRejected - процесс, которого нет в таблице UIDMapper, не обслуживается
+func TestServeUnixRejected(t *testing.T) {
+	logic := newEchoLogic()
+	srv := &Server{Handler: logic.handler, ErrorLog: quietLog()}
+	t.Cleanup(func() { srv.Close() })
+	p := filepath.Join(t.TempDir(), "s.sock")
+	l, err := net.Listen("unix", p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go srv.ServeUnix(l, UIDMapper(map[uint32]string{uint32(os.Getuid()) + 1: "other"}))
+	c, err := net.Dial("unix", p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, err = c.Read(make([]byte, 1)); err == nil {
+		t.Fatal("Rejected peer got data")
+	}
+	if ne, ok := err.(net.Error); ok && ne.Timeout() {
+		t.Fatal("Rejected peer is not disconnected")
+	}
+}
@@ -0,0 +1,159 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/blabu/messagesLib/dto"
+	"github.com/blabu/messagesLib/parser"
+	"github.com/blabu/messagesLib/websocket"
+)
+
+// DefaultPingInterval - период отправки ping клиенту WebSocket по умолчанию
+const DefaultPingInterval = 30 * time.Second
+
+// WebSocket - http.Handler, который принимает WebSocket соединения и обслуживает их сервером.
+// Бинарные сообщения содержат пакеты парсера (парсер выбирается через parser.InitParser по первому сообщению),
+// текстовые - сообщение dto.Message в JSON. Ответы отправляются в том же виде, что и последнее принятое сообщение.
+// Ping от клиента передается бизнес логике как PingCOMMAND, сервер сам периодически отправляет ping
+// и закрывает соединение если клиент не отвечает
+type WebSocket struct {
+	Server       *Server
+	PingInterval time.Duration              // Период отправки ping (0 - DefaultPingInterval, меньше 0 - не отправлять)
+	CheckOrigin  func(r *http.Request) bool // Проверка Origin запроса (nil - websocket.SameOrigin, любой Origin - websocket.AllowAnyOrigin)
+}
+
+func (ws *WebSocket) pingInterval() time.Duration {
+	if ws.PingInterval == 0 {
+		return DefaultPingInterval
+	}
+	return ws.PingInterval
+}
+
+// ServeHTTP - переводит запрос в WebSocket и обслуживает соединение до его закрытия
+func (ws *WebSocket) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c, err := websocket.Upgrade(w, r, ws.CheckOrigin)
+	if err != nil {
+		ws.Server.logf("WebSocket upgrade from %s: %v", r.RemoteAddr, err)
+		return
+	}
+	c.MaxMessageSize = int64(ws.Server.maxPackageSize())
+	peer := &Peer{
+		Transport:  "ws",
+		RemoteAddr: c.RemoteAddr(),
+		LocalAddr:  c.LocalAddr(),
+	}
+	mc := &wsConn{conn: c, srv: ws.Server, peer: peer, mode: websocket.BinaryMessage}
+	interval := ws.pingInterval()
+	if interval > 0 {
+		mc.idle = 2 * interval
+		c.PongHandler = func([]byte) error {
+			return c.SetReadDeadline(time.Now().Add(mc.idle))
+		}
+		stop := make(chan struct{})
+		defer close(stop)
+		go mc.keepAlive(interval, stop)
+	}
+	ws.Server.ServeConn(ws.Server.baseContext(), mc, peer)
+}
+
+// wsConn - MessageConn поверх WebSocket
+type wsConn struct {
+	conn *websocket.Conn
+	srv  *Server
+	peer *Peer
+	idle time.Duration // Максимальное время без входящих кадров (0 - без ограничения)
+
+	mu     sync.Mutex
+	mode   int // Тип последнего принятого сообщения, в нем же отправляются ответы
+	parser parser.IParser
+}
+
+func (wc *wsConn) keepAlive(interval time.Duration, stop chan struct{}) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			if err := wc.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(interval)); err != nil {
+				wc.conn.Close()
+				return
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+func (wc *wsConn) ReadMessage(ctx context.Context, msg *dto.Message) error {
+	if wc.idle > 0 {
+		wc.conn.SetReadDeadline(time.Now().Add(wc.idle))
+	}
+	mType, data, err := wc.conn.ReadMessage()
+	if err != nil {
+		return err
+	}
+	switch mType {
+	case websocket.PingMessage:
+		*msg = dto.Message{
+			MessageMetaInf: dto.MessageMetaInf{Command: dto.PingCOMMAND, Proto: 1},
+			MessageContent: dto.MessageContent{ContentType: "binary", Data: data},
+		}
+		return nil
+	case websocket.TextMessage:
+		wc.mu.Lock()
+		wc.mode = mType
+		wc.mu.Unlock()
+		var m dto.Message
+		if err = m.UnmarshalJSON(data); err != nil {
+			return &parser.FrameError{Err: err}
+		}
+		*msg = m
+		return nil
+	}
+	wc.mu.Lock()
+	wc.mode = mType
+	if wc.parser == nil {
+		if wc.parser, err = wc.srv.newParser(data, wc.peer); err != nil {
+			wc.mu.Unlock()
+			return err
+		}
+	}
+	p := wc.parser
+	wc.mu.Unlock()
+	m, err := p.ParseMessage(data)
+	if err != nil {
+		return &parser.FrameError{Err: err}
+	}
+	*msg = m
+	return nil
+}
+
+func (wc *wsConn) WriteMessage(ctx context.Context, msg *dto.Message) error {
+	wc.mu.Lock()
+	mode, p := wc.mode, wc.parser
+	wc.mu.Unlock()
+	var data []byte
+	var err error
+	if mode == websocket.TextMessage {
+		data, err = msg.MarshalJSON()
+	} else {
+		if p == nil {
+			p, err = wc.srv.newParser(nil, wc.peer)
+		}
+		if err == nil {
+			data, err = p.FormMessage(msg)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	deadline, _ := ctx.Deadline()
+	return wc.conn.WriteMessageDeadline(mode, data, deadline)
+}
+
+func (wc *wsConn) Close() error {
+	return wc.conn.Close()
+}
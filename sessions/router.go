@@ -0,0 +1,41 @@
+package sessions
+
+import (
+	"context"
+
+	"github.com/blabu/messagesLib/dto"
+	"github.com/blabu/messagesLib/router"
+)
+
+// remoteKey - ключ адреса клиента в router.Router (смотри SetRemote)
+type remoteKey struct{}
+
+// SetRemote - запоминает адрес клиента соединения r для Info.Remote (например из server.Peer в Handler)
+func SetRemote(r *router.Router, remote string) {
+	r.SetValue(remoteKey{}, remote)
+}
+
+// Admit - добавляет соединение r авторизованного клиента name (подходит для auth.Service.Admit).
+// Если соединение было авторизовано под другим именем, старая сессия удаляется.
+// Отказ возвращается как *dto.ErrorInfo с кодом dto.ErrCodeSessionLimit
+func (reg *Registry) Admit(ctx context.Context, r *router.Router, name string) error {
+	reg.mu.Lock()
+	e, ok := reg.byConn[r]
+	reg.mu.Unlock()
+	if ok {
+		if e.Name == name {
+			return nil
+		}
+		reg.Remove(r)
+	}
+	remote, _ := r.Value(remoteKey{}).(string)
+	if _, err := reg.Add(name, remote, r); err != nil {
+		return &dto.ErrorInfo{Code: dto.ErrCodeSessionLimit, Command: dto.AuthCOMMAND, Message: err.Error()}
+	}
+	return nil
+}
+
+// RouterClosed - удаляет сессию закрытого соединения (подходит для router.Mux.OnClose)
+func (reg *Registry) RouterClosed(r *router.Router) {
+	reg.Remove(r)
+}
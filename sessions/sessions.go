@@ -0,0 +1,228 @@
+// Package sessions - реестр активных соединений авторизованных клиентов: список сессий клиента,
+// ограничение количества одновременных сессий, принудительное отключение и события подключения и отключения.
+//
+// С пакетами router и auth реестр подключается так:
+//
+//	reg := &sessions.Registry{MaxSessions: 2, Policy: sessions.EvictOldest}
+//	authSvc.Admit = reg.Admit
+//	mux.OnClose(reg.RouterClosed)
+package sessions
+
+import (
+	"errors"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Policy - что делать с новой сессией, если у клиента уже MaxSessions сессий
+type Policy int
+
+const (
+	RejectNew   Policy = iota // Отказать новой сессии
+	EvictOldest               // Закрыть самую старую сессию клиента
+)
+
+// EventType - тип события реестра
+type EventType int
+
+const (
+	EventConnect    EventType = iota // Сессия добавлена
+	EventDisconnect                  // Сессия удалена
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventConnect:
+		return "connect"
+	case EventDisconnect:
+		return "disconnect"
+	}
+	return "unknown"
+}
+
+// Причины отключения в Event.Reason
+const (
+	ReasonClosed  = "closed"  // Соединение закрыто клиентом или сервером
+	ReasonEvicted = "evicted" // Закрыта политикой EvictOldest
+	ReasonKicked  = "kicked"  // Закрыта вызовом Kick или KickAll
+)
+
+// ErrTooManySessions - у клиента уже MaxSessions сессий (политика RejectNew)
+var ErrTooManySessions = errors.New("Too many sessions")
+
+// ErrNotFound - сессии с таким ID нет
+var ErrNotFound = errors.New("Session not found")
+
+// Info - описание сессии
+type Info struct {
+	ID      uint64
+	Name    string
+	Started time.Time
+	Remote  string // Адрес клиента, если известен
+}
+
+// Event - событие подключения или отключения сессии
+type Event struct {
+	Type    EventType
+	Session Info
+	Reason  string // Причина отключения, для EventConnect пусто
+}
+
+type entry struct {
+	Info
+	conn io.Closer
+}
+
+// Registry - реестр сессий. Нулевое значение готово к использованию и не ограничивает количество сессий
+type Registry struct {
+	MaxSessions int         // Максимум одновременных сессий одного клиента (0 - без ограничения)
+	Policy      Policy      // Что делать при превышении MaxSessions
+	OnEvent     func(Event) // Вызывается для каждого события вне блокировки реестра (nil - без событий)
+
+	mu     sync.Mutex
+	lastID uint64
+	byName map[string][]*entry // Сессии клиента в порядке подключения
+	byConn map[io.Closer]*entry
+}
+
+func (reg *Registry) emit(ev Event) {
+	if reg.OnEvent != nil {
+		reg.OnEvent(ev)
+	}
+}
+
+// Add - добавляет сессию клиента name с соединением conn (Close закрывает соединение).
+// Соединение должно вызвать Remove при закрытии. При превышении MaxSessions возвращает ErrTooManySessions
+// или закрывает самую старую сессию клиента, в зависимости от Policy
+func (reg *Registry) Add(name, remote string, conn io.Closer) (Info, error) {
+	reg.mu.Lock()
+	if reg.byName == nil {
+		reg.byName = make(map[string][]*entry)
+		reg.byConn = make(map[io.Closer]*entry)
+	}
+	if e, ok := reg.byConn[conn]; ok {
+		reg.mu.Unlock()
+		return e.Info, nil
+	}
+	var evict []*entry
+	if list := reg.byName[name]; reg.MaxSessions > 0 && len(list) >= reg.MaxSessions {
+		if reg.Policy == RejectNew {
+			reg.mu.Unlock()
+			return Info{}, ErrTooManySessions
+		}
+		evict = append(evict, list[:len(list)-reg.MaxSessions+1]...)
+		for _, e := range evict {
+			reg.removeLocked(e)
+		}
+	}
+	reg.lastID++
+	e := &entry{Info: Info{ID: reg.lastID, Name: name, Started: time.Now(), Remote: remote}, conn: conn}
+	reg.byName[name] = append(reg.byName[name], e)
+	reg.byConn[conn] = e
+	reg.mu.Unlock()
+
+	for _, old := range evict {
+		reg.emit(Event{Type: EventDisconnect, Session: old.Info, Reason: ReasonEvicted})
+		old.conn.Close()
+	}
+	reg.emit(Event{Type: EventConnect, Session: e.Info})
+	return e.Info, nil
+}
+
+// Remove - удаляет сессию соединения conn (повторный вызов ничего не делает)
+func (reg *Registry) Remove(conn io.Closer) {
+	reg.mu.Lock()
+	e, ok := reg.byConn[conn]
+	if !ok {
+		reg.mu.Unlock()
+		return
+	}
+	reg.removeLocked(e)
+	reg.mu.Unlock()
+	reg.emit(Event{Type: EventDisconnect, Session: e.Info, Reason: ReasonClosed})
+}
+
+// removeLocked - удаляет сессию e из реестра, вызывается под reg.mu
+func (reg *Registry) removeLocked(e *entry) {
+	delete(reg.byConn, e.conn)
+	list := reg.byName[e.Name]
+	for i := range list {
+		if list[i] == e {
+			list = append(list[:i:i], list[i+1:]...)
+			break
+		}
+	}
+	if len(list) == 0 {
+		delete(reg.byName, e.Name)
+	} else {
+		reg.byName[e.Name] = list
+	}
+}
+
+// Sessions - сессии клиента name в порядке подключения
+func (reg *Registry) Sessions(name string) []Info {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	list := reg.byName[name]
+	res := make([]Info, len(list))
+	for i, e := range list {
+		res[i] = e.Info
+	}
+	return res
+}
+
+// All - все сессии, отсортированные по ID
+func (reg *Registry) All() []Info {
+	reg.mu.Lock()
+	res := make([]Info, 0, len(reg.byConn))
+	for _, e := range reg.byConn {
+		res = append(res, e.Info)
+	}
+	reg.mu.Unlock()
+	sort.Slice(res, func(i, j int) bool { return res[i].ID < res[j].ID })
+	return res
+}
+
+// Count - количество сессий клиента name
+func (reg *Registry) Count(name string) int {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	return len(reg.byName[name])
+}
+
+// Kick - принудительно закрывает сессию с номером id
+func (reg *Registry) Kick(id uint64) error {
+	reg.mu.Lock()
+	var found *entry
+	for _, e := range reg.byConn {
+		if e.ID == id {
+			found = e
+			break
+		}
+	}
+	if found == nil {
+		reg.mu.Unlock()
+		return ErrNotFound
+	}
+	reg.removeLocked(found)
+	reg.mu.Unlock()
+	reg.emit(Event{Type: EventDisconnect, Session: found.Info, Reason: ReasonKicked})
+	return found.conn.Close()
+}
+
+// KickAll - закрывает все сессии клиента name, возвращает их количество
+func (reg *Registry) KickAll(name string) int {
+	reg.mu.Lock()
+	list := append([]*entry(nil), reg.byName[name]...)
+	for _, e := range list {
+		reg.removeLocked(e)
+	}
+	reg.mu.Unlock()
+	for _, e := range list {
+		reg.emit(Event{Type: EventDisconnect, Session: e.Info, Reason: ReasonKicked})
+		e.conn.Close()
+	}
+	return len(list)
+}
@@ -0,0 +1,170 @@
+package sessions
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// testConn - соединение, которое считает вызовы Close
+type testConn struct{ closed int }
+
+func (c *testConn) Close() error {
+	c.closed++
+	return nil
+}
+
+// events - записывает события реестра в виде "тип:ID:причина"
+type events []string
+
+func (e *events) add(ev Event) {
+	*e = append(*e, fmt.Sprintf("%s:%d:%s", ev.Type, ev.Session.ID, ev.Reason))
+}
+
+func TestRegistryLimit(t *testing.T) {
+	cases := []struct {
+		name     string
+		max      int
+		policy   Policy
+		conns    int
+		rejected int
+		closed   []int // Количество вызовов Close по соединениям
+		ids      []uint64
+		events   string
+	}{
+		{"unlimited", 0, RejectNew, 3, 0, []int{0, 0, 0}, []uint64{1, 2, 3}, "connect:1: connect:2: connect:3:"},
+		{"reject_new", 2, RejectNew, 3, 1, []int{0, 0, 0}, []uint64{1, 2}, "connect:1: connect:2:"},
+		{"evict_oldest", 2, EvictOldest, 3, 0, []int{1, 0, 0}, []uint64{2, 3}, "connect:1: connect:2: disconnect:1:evicted connect:3:"},
+		{"evict_single", 1, EvictOldest, 3, 0, []int{1, 1, 0}, []uint64{3}, "connect:1: disconnect:1:evicted connect:2: disconnect:2:evicted connect:3:"},
+	}
+	for _, tc := range cases {
+		var ev events
+		reg := &Registry{MaxSessions: tc.max, Policy: tc.policy, OnEvent: ev.add}
+		conns := make([]*testConn, tc.conns)
+		rejected := 0
+		for i := range conns {
+			conns[i] = &testConn{}
+			if _, err := reg.Add("modem", "remote", conns[i]); err == ErrTooManySessions {
+				rejected++
+			} else if err != nil {
+				t.Fatalf("%s: %v", tc.name, err)
+			}
+		}
+		closed := make([]int, len(conns))
+		for i, c := range conns {
+			closed[i] = c.closed
+		}
+		var ids []uint64
+		for _, s := range reg.Sessions("modem") {
+			ids = append(ids, s.ID)
+		}
+		if rejected != tc.rejected || !reflect.DeepEqual(closed, tc.closed) || !reflect.DeepEqual(ids, tc.ids) {
+			t.Errorf("%s: rejected %d, closed %v, sessions %v", tc.name, rejected, closed, ids)
+		}
+		if got := strings.Join(ev, " "); got != tc.events {
+			t.Errorf("%s: events %q, want %q", tc.name, got, tc.events)
+		}
+		if reg.Count("other") != 0 || reg.Count("modem") != len(tc.ids) {
+			t.Errorf("%s: count %d", tc.name, reg.Count("modem"))
+		}
+	}
+}
+
+func TestRegistryKick(t *testing.T) {
+	var ev events
+	reg := &Registry{OnEvent: ev.add}
+	a, b, c := &testConn{}, &testConn{}, &testConn{}
+	reg.Add("modem", "", a)
+	reg.Add("modem", "", b)
+	info, _ := reg.Add("bot", "", c)
+	if again, _ := reg.Add("bot", "", c); again != info {
+		t.Fatalf("Second Add of the same conn: %+v, want %+v", again, info)
+	}
+
+	if err := reg.Kick(info.ID); err != nil || c.closed != 1 {
+		t.Fatalf("Kick: %v, closed %d", err, c.closed)
+	}
+	if err := reg.Kick(info.ID); err != ErrNotFound {
+		t.Fatalf("Second Kick: %v, want ErrNotFound", err)
+	}
+	if n := reg.KickAll("modem"); n != 2 || a.closed != 1 || b.closed != 1 {
+		t.Fatalf("KickAll %d, closed %d %d", n, a.closed, b.closed)
+	}
+	// Соединение сообщает о закрытии уже после Kick
+	reg.Remove(a)
+	if len(reg.All()) != 0 {
+		t.Fatalf("Sessions left %+v", reg.All())
+	}
+
+	d := &testConn{}
+	reg.Add("modem", "", d)
+	reg.Remove(d)
+	want := "connect:1: connect:2: connect:3: disconnect:3:kicked disconnect:1:kicked disconnect:2:kicked connect:4: disconnect:4:closed"
+	if got := strings.Join(ev, " "); got != want {
+		t.Fatalf("Events %q, want %q", got, want)
+	}
+}
+
+// TestRegistryConcurrentEvict - одновременные подключения одного клиента оставляют ровно MaxSessions сессий
+func TestRegistryConcurrentEvict(t *testing.T) {
+	const n, max = 50, 3
+	var mu sync.Mutex
+	evicted := 0
+	reg := &Registry{MaxSessions: max, Policy: EvictOldest, OnEvent: func(ev Event) {
+		if ev.Reason == ReasonEvicted {
+			mu.Lock()
+			evicted++
+			mu.Unlock()
+		}
+	}}
+	conns := make([]*lockedConn, n)
+	var wg sync.WaitGroup
+	for i := range conns {
+		conns[i] = new(lockedConn)
+		wg.Add(1)
+		go func(c *lockedConn) {
+			defer wg.Done()
+			reg.Add("modem", "", c)
+		}(conns[i])
+	}
+	wg.Wait()
+	closed := 0
+	for _, c := range conns {
+		switch c.count() {
+		case 0:
+		case 1:
+			closed++
+		default:
+			t.Fatalf("Connection closed %d times", c.count())
+		}
+	}
+	if reg.Count("modem") != max || closed != n-max || evicted != n-max {
+		t.Fatalf("%d sessions, %d closed, %d evicted", reg.Count("modem"), closed, evicted)
+	}
+	for c, e := range reg.byConn {
+		if c.(*lockedConn).count() != 0 {
+			t.Fatalf("Session %d of a closed connection is kept", e.ID)
+		}
+	}
+}
+
+// lockedConn - testConn для одновременных вызовов Close
+type lockedConn struct {
+	mu     sync.Mutex
+	closed int
+}
+
+func (c *lockedConn) Close() error {
+	c.mu.Lock()
+	c.closed++
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *lockedConn) count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closed
+}
@@ -0,0 +1,332 @@
+// Package websocket - минимальная реализация протокола WebSocket (RFC 6455) поверх net/http без внешних зависимостей.
+// Поддерживает только то, что нужно для передачи пакетов: текстовые и бинарные сообщения, фрагментацию, ping/pong и close
+package websocket
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Типы сообщений (opcode)
+const (
+	continuationFrame = 0
+	TextMessage       = 1
+	BinaryMessage     = 2
+	CloseMessage      = 8
+	PingMessage       = 9
+	PongMessage       = 10
+)
+
+// Коды закрытия соединения
+const (
+	CloseNormal          = 1000
+	CloseGoingAway       = 1001
+	CloseProtocolError   = 1002
+	CloseInvalidPayload  = 1007
+	CloseMessageTooBig   = 1009
+	closeNoStatus        = 1005
+	maxControlPayload    = 125
+	defaultMaxMessageLen = 1 << 20
+)
+
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+var (
+	// ErrProtocol - собеседник нарушил протокол WebSocket
+	ErrProtocol = errors.New("WebSocket protocol error")
+	// ErrMessageTooBig - сообщение больше Conn.MaxMessageSize
+	ErrMessageTooBig = errors.New("WebSocket message is too big")
+)
+
+// CloseError - соединение закрыто собеседником
+type CloseError struct {
+	Code int
+	Text string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("WebSocket closed with code %d %s", e.Code, e.Text)
+}
+
+// Conn - WebSocket соединение
+type Conn struct {
+	conn   net.Conn
+	br     *bufio.Reader
+	client bool // клиент маскирует отправляемые кадры и не принимает маскированные
+
+	MaxMessageSize int64                   // Максимальный размер сообщения (0 - 1Мб)
+	PingHandler    func(data []byte) error // Вызывается при приеме ping, по умолчанию отвечает pong
+	PongHandler    func(data []byte) error // Вызывается при приеме pong
+
+	wMutex    sync.Mutex
+	closeOnce sync.Once
+	closeErr  error
+}
+
+func newConn(c net.Conn, br *bufio.Reader, client bool) *Conn {
+	if br == nil {
+		br = bufio.NewReader(c)
+	}
+	ws := &Conn{conn: c, br: br, client: client}
+	ws.PingHandler = func(data []byte) error {
+		return ws.WriteControl(PongMessage, data, time.Now().Add(time.Second))
+	}
+	ws.PongHandler = func([]byte) error { return nil }
+	return ws
+}
+
+func acceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// LocalAddr - локальный адрес соединения
+func (c *Conn) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+// RemoteAddr - адрес собеседника
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// SetReadDeadline - дедлайн чтения исходного соединения
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+// SetWriteDeadline - дедлайн записи исходного соединения
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	return c.conn.SetWriteDeadline(t)
+}
+
+func (c *Conn) maxMessageSize() int64 {
+	if c.MaxMessageSize <= 0 {
+		return defaultMaxMessageLen
+	}
+	return c.MaxMessageSize
+}
+
+type frame struct {
+	fin     bool
+	opcode  int
+	payload []byte
+}
+
+func (c *Conn) readFrame(limit int64) (frame, error) {
+	var f frame
+	var head [2]byte
+	if _, err := io.ReadFull(c.br, head[:]); err != nil {
+		return f, err
+	}
+	f.fin = head[0]&0x80 != 0
+	f.opcode = int(head[0] & 0x0F)
+	if head[0]&0x70 != 0 {
+		return f, ErrProtocol
+	}
+	masked := head[1]&0x80 != 0
+	if masked == c.client { // от клиента кадры всегда маскированы, от сервера никогда
+		return f, ErrProtocol
+	}
+	size := int64(head[1] & 0x7F)
+	switch size {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return f, err
+		}
+		size = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return f, err
+		}
+		size = int64(binary.BigEndian.Uint64(ext[:]))
+		if size < 0 {
+			return f, ErrProtocol
+		}
+	}
+	if f.opcode >= CloseMessage && (size > maxControlPayload || !f.fin) {
+		return f, ErrProtocol
+	}
+	if size > limit {
+		return f, ErrMessageTooBig
+	}
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(c.br, mask[:]); err != nil {
+			return f, err
+		}
+	}
+	f.payload = make([]byte, size)
+	if _, err := io.ReadFull(c.br, f.payload); err != nil {
+		return f, err
+	}
+	if masked {
+		maskBytes(mask, f.payload)
+	}
+	return f, nil
+}
+
+func maskBytes(mask [4]byte, data []byte) {
+	for i := range data {
+		data[i] ^= mask[i%4]
+	}
+}
+
+// ReadMessage - читает следующее сообщение. Возвращает текстовые и бинарные сообщения целиком (собирая фрагменты),
+// а также PingMessage после того как PingHandler обработал ping. Ping между фрагментами сообщения только обрабатывается
+// PingHandler и не прерывает сборку. Pong обрабатывается PongHandler и не возвращается.
+// При приеме close отвечает close и возвращает *CloseError
+func (c *Conn) ReadMessage() (int, []byte, error) {
+	var msgType int
+	var msg []byte
+	for {
+		f, err := c.readFrame(c.maxMessageSize() - int64(len(msg)))
+		if err != nil {
+			switch err {
+			case ErrProtocol:
+				c.closeWithCode(CloseProtocolError)
+			case ErrMessageTooBig:
+				c.closeWithCode(CloseMessageTooBig)
+			}
+			return 0, nil, err
+		}
+		switch f.opcode {
+		case PingMessage:
+			if err = c.PingHandler(f.payload); err != nil {
+				return 0, nil, err
+			}
+			if msgType != 0 { // Управляющие кадры могут приходить между фрагментами, продолжаем собирать сообщение
+				continue
+			}
+			return PingMessage, f.payload, nil
+		case PongMessage:
+			if err = c.PongHandler(f.payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case CloseMessage:
+			ce := &CloseError{Code: closeNoStatus}
+			if len(f.payload) >= 2 {
+				ce.Code = int(binary.BigEndian.Uint16(f.payload))
+				ce.Text = string(f.payload[2:])
+			}
+			c.closeWithCode(CloseNormal)
+			return 0, nil, ce
+		case TextMessage, BinaryMessage:
+			if msgType != 0 {
+				c.closeWithCode(CloseProtocolError)
+				return 0, nil, ErrProtocol
+			}
+			msgType = f.opcode
+		case continuationFrame:
+			if msgType == 0 {
+				c.closeWithCode(CloseProtocolError)
+				return 0, nil, ErrProtocol
+			}
+		default:
+			c.closeWithCode(CloseProtocolError)
+			return 0, nil, ErrProtocol
+		}
+		msg = append(msg, f.payload...)
+		if !f.fin {
+			continue
+		}
+		if msgType == TextMessage && !utf8.Valid(msg) {
+			c.closeWithCode(CloseInvalidPayload)
+			return 0, nil, ErrProtocol
+		}
+		return msgType, msg, nil
+	}
+}
+
+func (c *Conn) writeFrame(opcode int, data []byte) error {
+	head := make([]byte, 0, 14)
+	head = append(head, 0x80|byte(opcode))
+	var maskBit byte
+	if c.client {
+		maskBit = 0x80
+	}
+	switch size := len(data); {
+	case size < 126:
+		head = append(head, maskBit|byte(size))
+	case size <= 0xFFFF:
+		head = append(head, maskBit|126, byte(size>>8), byte(size))
+	default:
+		var ext [8]byte
+		binary.BigEndian.PutUint64(ext[:], uint64(size))
+		head = append(head, maskBit|127)
+		head = append(head, ext[:]...)
+	}
+	buf := append(head, data...)
+	if c.client {
+		var mask [4]byte
+		if _, err := rand.Read(mask[:]); err != nil {
+			return err
+		}
+		payload := buf[len(head):]
+		maskBytes(mask, payload)
+		buf = append(append(head[:len(head):len(head)], mask[:]...), payload...)
+	}
+	_, err := c.conn.Write(buf)
+	return err
+}
+
+// WriteMessage - отправляет текстовое или бинарное сообщение одним кадром
+func (c *Conn) WriteMessage(messageType int, data []byte) error {
+	return c.WriteMessageDeadline(messageType, data, time.Time{})
+}
+
+// WriteMessageDeadline - отправляет сообщение с дедлайном записи (нулевое время - без дедлайна)
+func (c *Conn) WriteMessageDeadline(messageType int, data []byte, deadline time.Time) error {
+	if messageType != TextMessage && messageType != BinaryMessage {
+		return fmt.Errorf("Unsupported message type %d", messageType)
+	}
+	c.wMutex.Lock()
+	defer c.wMutex.Unlock()
+	if !deadline.IsZero() {
+		c.conn.SetWriteDeadline(deadline)
+		defer c.conn.SetWriteDeadline(time.Time{})
+	}
+	return c.writeFrame(messageType, data)
+}
+
+// WriteControl - отправляет управляющий кадр (ping, pong, close) с дедлайном записи
+func (c *Conn) WriteControl(messageType int, data []byte, deadline time.Time) error {
+	if messageType < CloseMessage || len(data) > maxControlPayload {
+		return ErrProtocol
+	}
+	c.wMutex.Lock()
+	defer c.wMutex.Unlock()
+	c.conn.SetWriteDeadline(deadline)
+	defer c.conn.SetWriteDeadline(time.Time{})
+	return c.writeFrame(messageType, data)
+}
+
+func (c *Conn) closeWithCode(code int) error {
+	c.closeOnce.Do(func() {
+		var payload [2]byte
+		binary.BigEndian.PutUint16(payload[:], uint16(code))
+		c.conn.SetWriteDeadline(time.Now().Add(time.Second)) // прерывает зависшую запись, чтобы освободить wMutex
+		c.WriteControl(CloseMessage, payload[:], time.Now().Add(time.Second))
+		c.closeErr = c.conn.Close()
+	})
+	return c.closeErr
+}
+
+// Close - отправляет close (если еще не отправлен) и закрывает соединение
+func (c *Conn) Close() error {
+	return c.closeWithCode(CloseNormal)
+}
@@ -0,0 +1,276 @@
+package websocket
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// result - что прочитал сервер
+type result struct {
+	msgType int
+	data    []byte
+	err     error
+}
+
+// startServer - httptest сервер, который читает сообщения и отправляет их в канал (setup настраивает соединение)
+func startServer(t *testing.T, setup func(c *Conn)) (string, <-chan result) {
+	t.Helper()
+	res := make(chan result, 16)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		if setup != nil {
+			setup(c)
+		}
+		for {
+			mt, data, err := c.ReadMessage()
+			res <- result{mt, data, err}
+			if err != nil {
+				return
+			}
+			if mt == TextMessage || mt == BinaryMessage {
+				if err = c.WriteMessage(mt, data); err != nil {
+					return
+				}
+			}
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return "ws" + strings.TrimPrefix(srv.URL, "http"), res
+}
+
+func dial(t *testing.T, url string) *Conn {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	c, err := Dial(ctx, url, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func next(t *testing.T, res <-chan result) result {
+	t.Helper()
+	select {
+	case r := <-res:
+		return r
+	case <-time.After(2 * time.Second):
+		t.Fatal("Server did not read a message")
+	}
+	return result{}
+}
+
+// writeRaw - отправляет кадр с произвольным флагом fin (для проверки фрагментации)
+func writeRaw(t *testing.T, c *Conn, fin bool, opcode int, data []byte) {
+	t.Helper()
+	head := []byte{byte(opcode), 0x80 | byte(len(data))}
+	if fin {
+		head[0] |= 0x80
+	}
+	var mask [4]byte
+	rand.Read(mask[:])
+	payload := append([]byte(nil), data...)
+	maskBytes(mask, payload)
+	if _, err := c.conn.Write(append(append(head, mask[:]...), payload...)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEcho(t *testing.T) {
+	url, res := startServer(t, nil)
+	c := dial(t, url)
+	big := make([]byte, 70000) // 64 битный размер кадра
+	rand.Read(big)
+	cases := []struct {
+		msgType int
+		data    []byte
+	}{
+		{TextMessage, []byte("hello")},
+		{BinaryMessage, []byte{0, 1, 2, 0xFF}},
+		{BinaryMessage, bytes.Repeat([]byte{7}, 300)}, // 16 битный размер кадра
+		{BinaryMessage, big},
+		{TextMessage, nil},
+	}
+	for i, tc := range cases {
+		if err := c.WriteMessage(tc.msgType, tc.data); err != nil {
+			t.Fatal(err)
+		}
+		if r := next(t, res); r.err != nil || r.msgType != tc.msgType || !bytes.Equal(r.data, tc.data) {
+			t.Fatalf("#%d: server read %d, %d bytes, %v", i, r.msgType, len(r.data), r.err)
+		}
+		mt, data, err := c.ReadMessage()
+		if err != nil || mt != tc.msgType || !bytes.Equal(data, tc.data) {
+			t.Fatalf("#%d: echo %d, %d bytes, %v", i, mt, len(data), err)
+		}
+	}
+}
+
+// TestPingBetweenFragments - ping между фрагментами обрабатывается, а сообщение собирается целиком
+func TestPingBetweenFragments(t *testing.T) {
+	url, res := startServer(t, nil)
+	c := dial(t, url)
+	pong := make(chan []byte, 1)
+	c.PongHandler = func(data []byte) error {
+		pong <- data
+		return nil
+	}
+	writeRaw(t, c, false, TextMessage, []byte("hel"))
+	writeRaw(t, c, true, PingMessage, []byte("ping"))
+	writeRaw(t, c, false, continuationFrame, []byte("lo, "))
+	writeRaw(t, c, true, continuationFrame, []byte("world"))
+	if r := next(t, res); r.err != nil || r.msgType != TextMessage || string(r.data) != "hello, world" {
+		t.Fatalf("Server read %d %q %v", r.msgType, r.data, r.err)
+	}
+	mt, data, err := c.ReadMessage() // Pong обрабатывается внутри, возвращается эхо
+	if err != nil || mt != TextMessage || string(data) != "hello, world" {
+		t.Fatalf("Echo %d %q %v", mt, data, err)
+	}
+	select {
+	case p := <-pong:
+		if string(p) != "ping" {
+			t.Fatalf("Pong payload %q", p)
+		}
+	default:
+		t.Fatal("Pong is not received")
+	}
+}
+
+func TestPingMessage(t *testing.T) {
+	url, res := startServer(t, nil)
+	c := dial(t, url)
+	if err := c.WriteControl(PingMessage, []byte("p"), time.Now().Add(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if r := next(t, res); r.err != nil || r.msgType != PingMessage || string(r.data) != "p" {
+		t.Fatalf("Server read %d %q %v", r.msgType, r.data, r.err)
+	}
+}
+
+func TestProtocolErrors(t *testing.T) {
+	cases := []struct {
+		name  string
+		write func(t *testing.T, c *Conn)
+		err   error
+	}{
+		{"continuation_without_start", func(t *testing.T, c *Conn) { writeRaw(t, c, true, continuationFrame, []byte("x")) }, ErrProtocol},
+		{"new_message_inside_fragments", func(t *testing.T, c *Conn) {
+			writeRaw(t, c, false, TextMessage, []byte("a"))
+			writeRaw(t, c, true, BinaryMessage, []byte("b"))
+		}, ErrProtocol},
+		{"fragmented_ping", func(t *testing.T, c *Conn) { writeRaw(t, c, false, PingMessage, nil) }, ErrProtocol},
+		{"unknown_opcode", func(t *testing.T, c *Conn) { writeRaw(t, c, true, 3, nil) }, ErrProtocol},
+		{"invalid_utf8", func(t *testing.T, c *Conn) { writeRaw(t, c, true, TextMessage, []byte{0xFF, 0xFE}) }, ErrProtocol},
+		{"unmasked", func(t *testing.T, c *Conn) {
+			c.client = false
+			c.WriteMessage(BinaryMessage, []byte("x"))
+			c.client = true
+		}, ErrProtocol},
+		{"too_big", func(t *testing.T, c *Conn) { c.WriteMessage(BinaryMessage, make([]byte, 100)) }, ErrMessageTooBig},
+	}
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			url, res := startServer(t, func(c *Conn) { c.MaxMessageSize = 50 })
+			c := dial(t, url)
+			tc.write(t, c)
+			if r := next(t, res); r.err != tc.err {
+				t.Fatalf("Server read error %v, want %v", r.err, tc.err)
+			}
+		})
+	}
+}
+
+func TestClose(t *testing.T) {
+	url, res := startServer(t, nil)
+	c := dial(t, url)
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	r := next(t, res)
+	ce, ok := r.err.(*CloseError)
+	if !ok || ce.Code != CloseNormal {
+		t.Fatalf("Server read error %v, want close %d", r.err, CloseNormal)
+	}
+}
+
+func TestUpgradeRejects(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c, err := Upgrade(w, r, func(r *http.Request) bool { return r.Header.Get("Origin") != "http://evil" }); err == nil {
+			c.Close()
+		}
+	}))
+	defer srv.Close()
+	valid := map[string]string{
+		"Connection":            "Upgrade",
+		"Upgrade":               "websocket",
+		"Sec-WebSocket-Version": "13",
+		"Sec-WebSocket-Key":     "dGhlIHNhbXBsZSBub25jZQ==",
+	}
+	cases := []struct {
+		name   string
+		method string
+		change map[string]string
+		status int
+	}{
+		{"post", http.MethodPost, nil, http.StatusMethodNotAllowed},
+		{"no_upgrade", http.MethodGet, map[string]string{"Upgrade": ""}, http.StatusBadRequest},
+		{"version", http.MethodGet, map[string]string{"Sec-WebSocket-Version": "8"}, http.StatusUpgradeRequired},
+		{"key", http.MethodGet, map[string]string{"Sec-WebSocket-Key": "short"}, http.StatusBadRequest},
+		{"origin", http.MethodGet, map[string]string{"Origin": "http://evil"}, http.StatusForbidden},
+	}
+	for _, tc := range cases {
+		req, err := http.NewRequest(tc.method, srv.URL, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for k, v := range valid {
+			req.Header.Set(k, v)
+		}
+		for k, v := range tc.change {
+			req.Header.Set(k, v)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != tc.status {
+			t.Errorf("%s: status %d, want %d", tc.name, resp.StatusCode, tc.status)
+		}
+	}
+}
+
+func TestSameOrigin(t *testing.T) {
+	cases := []struct {
+		origin string
+		ok     bool
+	}{
+		{"", true},
+		{"http://example.com:8080", true},
+		{"https://EXAMPLE.com:8080", true},
+		{"http://example.com", false},
+		{"http://evil.com:8080", false},
+		{"null", false},
+		{"http://%zz", false},
+	}
+	for _, tc := range cases {
+		r := httptest.NewRequest(http.MethodGet, "http://example.com:8080/ws", nil)
+		if tc.origin != "" {
+			r.Header.Set("Origin", tc.origin)
+		}
+		if ok := SameOrigin(r); ok != tc.ok {
+			t.Errorf("Origin %q: %v, want %v", tc.origin, ok, tc.ok)
+		}
+	}
+}